@@ -88,6 +88,41 @@ export class BlockchainService implements OnModuleInit {
     throw new Error('Fabric 트랜잭션 제출 미구현');
   }
 
+  /**
+   * 비공개 데이터 체인코드 호출 (Transaction Submit + Transient Data)
+   * transientData는 블록에 기록되지 않으므로 로그에도 키 이름만 남긴다.
+   */
+  async submitPrivateTransaction(
+    chaincodeName: string,
+    functionName: string,
+    transientData: Record<string, string>,
+    ...args: string[]
+  ): Promise<string> {
+    const transientKeys = Object.keys(transientData);
+
+    if (!this.connected) {
+      this.logger.warn(
+        `[MOCK] submitPrivateTransaction: ${chaincodeName}.${functionName}(${args.join(', ')}) transient=[${transientKeys.join(', ')}]`,
+      );
+      return JSON.stringify({
+        mock: true,
+        chaincode: chaincodeName,
+        function: functionName,
+        args,
+        transientKeys,
+        txId: `mock-tx-${Date.now()}`,
+      });
+    }
+
+    // TODO: 실제 Fabric Gateway를 통한 트랜잭션 제출
+    // const transient = Object.fromEntries(
+    //   Object.entries(transientData).map(([k, v]) => [k, Buffer.from(v)]),
+    // );
+    // const result = await contract.submit(functionName, { arguments: args, transientData: transient });
+
+    throw new Error('Fabric 트랜잭션 제출 미구현');
+  }
+
   /**
    * 체인코드 조회 (Evaluate Transaction)
    */
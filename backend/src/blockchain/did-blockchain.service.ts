@@ -39,14 +39,14 @@ export class DIDBlockchainService {
     // DID 식별자 생성
     const did = `did:etp:${uuidv4()}`;
 
-    // 블록체인에 DID 등록
-    await this.blockchainService.submitTransaction(
+    // 블록체인에 DID 등록 (사용자 ID·역할은 비공개 컬렉션에 저장, 공개 문서에는 솔트 해시만 기록)
+    const salt = crypto.randomBytes(32).toString('hex');
+    await this.blockchainService.submitPrivateTransaction(
       this.chaincodeName,
       'CreateDID',
+      { did: JSON.stringify({ userId, role, salt }) },
       did,
-      userId,
       publicKeyHex,
-      role,
       organization,
     );
 
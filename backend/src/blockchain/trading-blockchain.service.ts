@@ -1,6 +1,7 @@
 import { Injectable, Logger } from '@nestjs/common';
 import { ConfigService } from '@nestjs/config';
 import { BlockchainService } from './blockchain.service';
+import * as crypto from 'crypto';
 
 @Injectable()
 export class TradingBlockchainService {
@@ -107,8 +108,8 @@ export class TradingBlockchainService {
     buyerId: string,
     sellerId: string,
     amount: number,
-    fee: number,
   ): Promise<string> {
+    // 수수료는 체인코드가 온체인 수수료 일정(GetFeeSchedule)으로 계산한다
     const result = await this.blockchainService.submitTransaction(
       this.settlementChaincode,
       'CreateSettlement',
@@ -117,13 +118,38 @@ export class TradingBlockchainService {
       buyerId,
       sellerId,
       amount.toString(),
-      fee.toString(),
     );
 
     this.logger.log(`정산 블록체인 기록: ${settlementId}`);
     return result;
   }
 
+  /**
+   * 정산 실행 (settlement 체인코드 SettleTrade)
+   *
+   * 구매 주문 에스크로의 잠금 EPC에서 판매자에게 순액, 수수료 계정에 수수료를 지급하고
+   * 거래를 SETTLED로 변경한다.
+   */
+  async settleTradeOnChain(settlementId: string): Promise<string> {
+    const result = await this.blockchainService.submitTransaction(
+      this.settlementChaincode,
+      'SettleTrade',
+      settlementId,
+    );
+
+    this.logger.log(`정산 실행: ${settlementId}`);
+    return result;
+  }
+
+  async getSettlementFromChain(settlementId: string) {
+    const result = await this.blockchainService.evaluateTransaction(
+      this.settlementChaincode,
+      'GetSettlement',
+      settlementId,
+    );
+    return JSON.parse(result);
+  }
+
   async confirmSettlementOnChain(settlementId: string): Promise<void> {
     await this.blockchainService.submitTransaction(
       this.settlementChaincode,
@@ -143,18 +169,25 @@ export class TradingBlockchainService {
     source: string,
     timestamp: string,
     dataHash: string,
+    signature: string,
   ): Promise<string> {
-    const result = await this.blockchainService.submitTransaction(
-      this.meteringChaincode,
-      'RecordMeter',
+    // 측정값은 비공개 컬렉션에 저장되고 공개 상태에는 솔트 해시만 기록된다
+    const meter = {
       recordId,
       userId,
       deviceId,
-      production.toString(),
-      consumption.toString(),
+      production,
+      consumption,
       source,
       timestamp,
-      dataHash,
+      hash: dataHash,
+      signature,
+      salt: crypto.randomBytes(32).toString('hex'),
+    };
+    const result = await this.blockchainService.submitPrivateTransaction(
+      this.meteringChaincode,
+      'RecordMeter',
+      { meter: JSON.stringify(meter) },
     );
 
     this.logger.log(`미터링 블록체인 기록: ${recordId} (device: ${deviceId})`);
@@ -4,9 +4,10 @@ import { SettlementController } from './settlement.controller';
 import { TokenModule } from '../token/token.module';
 import { OracleModule } from '../oracle/oracle.module';
 import { AuthModule } from '../auth/auth.module';
+import { BlockchainModule } from '../blockchain/blockchain.module';
 
 @Module({
-  imports: [TokenModule, OracleModule, AuthModule],
+  imports: [TokenModule, OracleModule, AuthModule, BlockchainModule],
   controllers: [SettlementController],
   providers: [SettlementService],
   exports: [SettlementService],
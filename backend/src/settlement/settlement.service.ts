@@ -1,8 +1,10 @@
 import { Injectable, NotFoundException, Inject, Optional, Logger } from '@nestjs/common';
+import { randomUUID } from 'crypto';
 import { PrismaService } from '../prisma/prisma.service';
 import { PaymentCurrency, SettlementStatus, TradeStatus } from '@prisma/client';
 import { TokenService } from '../token/token.service';
 import { OracleService } from '../oracle/oracle.service';
+import { TradingBlockchainService } from '../blockchain/trading-blockchain.service';
 import { EventsGateway } from '../common/gateways/events.gateway';
 
 const PLATFORM_FEE_RATE = 0.02; // 2% 수수료
@@ -16,6 +18,7 @@ export class SettlementService {
     private readonly eventsGateway: EventsGateway,
     @Optional() @Inject(TokenService) private readonly tokenService?: TokenService,
     @Optional() @Inject(OracleService) private readonly oracleService?: OracleService,
+    @Optional() @Inject(TradingBlockchainService) private readonly tradingBlockchain?: TradingBlockchainService,
   ) {}
 
   async createSettlement(tradeId: string) {
@@ -26,25 +29,51 @@ export class SettlementService {
       throw new NotFoundException('거래를 찾을 수 없습니다');
     }
 
-    const fee = trade.totalAmount * PLATFORM_FEE_RATE;
-    const netAmount = trade.totalAmount - fee;
-
-    // EPC 정산: buyer→seller 이체 + 수수료 소각
-    if (trade.paymentCurrency === PaymentCurrency.EPC && this.tokenService) {
+    const settlementId = randomUUID();
+    let fee = trade.totalAmount * PLATFORM_FEE_RATE;
+    let netAmount = trade.totalAmount - fee;
+    let txHash: string | null = null;
+
+    // EPC 정산: settlement 체인코드 SettleTrade가 구매 주문 에스크로에서 판매자·수수료 계정으로 지급한다.
+    // 구매자 명의 Transfer는 보유자 본인만 호출할 수 있어 백엔드 신원으로는 실패한다.
+    let settledOnChain = false;
+    if (
+      trade.paymentCurrency === PaymentCurrency.EPC &&
+      this.tokenService &&
+      this.tradingBlockchain
+    ) {
       try {
-        await this.tokenService.transfer(
+        await this.tradingBlockchain.recordSettlement(
+          settlementId,
+          trade.id,
           trade.buyerId,
           trade.sellerId,
-          netAmount,
-          'settlement',
-          tradeId,
+          trade.totalAmount,
+        );
+        txHash = await this.tradingBlockchain.settleTradeOnChain(settlementId);
+
+        // 수수료는 체인코드의 온체인 수수료 일정으로 계산되므로 원장 값을 따른다
+        const onChain = await this.tradingBlockchain.getSettlementFromChain(settlementId);
+        if (typeof onChain.fee === 'number') {
+          fee = onChain.fee;
+          netAmount = trade.totalAmount - fee;
+        }
+
+        await this.tokenService.applyLockedSettlement(
+          trade.buyerId,
+          trade.sellerId,
+          trade.totalAmount,
+          fee,
+          settlementId,
+          txHash,
         );
-        await this.tokenService.burnForSettlement(trade.buyerId, fee, tradeId);
+        settledOnChain = true;
       } catch (error) {
         this.logger.error(`EPC 정산 처리 실패 (거래 ${tradeId}): ${error.message}`);
         // 정산 실패 시 FAILED 상태로 기록
         const failedSettlement = await this.prisma.settlement.create({
           data: {
+            id: settlementId,
             tradeId: trade.id,
             buyerId: trade.buyerId,
             sellerId: trade.sellerId,
@@ -78,6 +107,7 @@ export class SettlementService {
 
     const settlement = await this.prisma.settlement.create({
       data: {
+        id: settlementId,
         tradeId: trade.id,
         buyerId: trade.buyerId,
         sellerId: trade.sellerId,
@@ -86,9 +116,20 @@ export class SettlementService {
         netAmount,
         paymentCurrency: trade.paymentCurrency,
         epcPrice,
+        // 온체인 정산은 지급과 거래 확정이 한 트랜잭션으로 끝난다
+        ...(settledOnChain
+          ? { status: SettlementStatus.COMPLETED, txHash, settledAt: new Date() }
+          : {}),
       },
     });
 
+    if (settledOnChain) {
+      await this.prisma.trade.update({
+        where: { id: trade.id },
+        data: { status: TradeStatus.SETTLED },
+      });
+    }
+
     this.eventsGateway.emitSettlementCompleted({
       action: 'created',
       settlementId: settlement.id,
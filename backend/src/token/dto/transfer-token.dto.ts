@@ -1,5 +1,13 @@
 import { ApiProperty, ApiPropertyOptional } from '@nestjs/swagger';
-import { IsNumber, IsOptional, IsString, Min } from 'class-validator';
+import {
+  IsIn,
+  IsInt,
+  IsNumber,
+  IsOptional,
+  IsString,
+  Max,
+  Min,
+} from 'class-validator';
 
 export class TransferTokenDto {
   @ApiProperty({ description: '수신자 ID' })
@@ -37,3 +45,26 @@ export class TransferRECDto {
   @IsString()
   toUserId: string;
 }
+
+export class RetireRECDto {
+  @ApiProperty({ description: '수혜자 (환경 속성을 주장하는 법인)' })
+  @IsString()
+  beneficiary: string;
+
+  @ApiProperty({ description: '보고 연도', example: 2026 })
+  @IsInt()
+  @Min(2000)
+  @Max(9999)
+  reportingYear: number;
+
+  @ApiProperty({
+    description: '소멸 목적',
+    enum: ['RE100', 'GHG_SCOPE2', 'VOLUNTARY'],
+  })
+  @IsIn(['RE100', 'GHG_SCOPE2', 'VOLUNTARY'])
+  purpose: string;
+
+  @ApiProperty({ description: '전력 소비(청구) 위치' })
+  @IsString()
+  consumptionLocation: string;
+}
@@ -2,10 +2,32 @@ import { Injectable } from '@nestjs/common';
 import { ConfigService } from '@nestjs/config';
 import { BlockchainService } from '../blockchain/blockchain.service';
 
+/** 거래 대기 잠금 기본 만료 시간 (24시간) */
+const ESCROW_TTL_MS = 24 * 60 * 60 * 1000;
+
+/** EPC 금액 소수 자릿수 (epc 체인코드 EPCDecimals) */
+const EPC_DECIMALS = 6;
+
+/**
+ * EPC 금액을 체인코드 ParseAmount 형식의 십진 문자열로 변환
+ *
+ * number.toString()은 1e-7, 1e+21 같은 지수 표기나 소수 7자리 이상을 만들어 체인코드가 거부하므로
+ * 소수 6자리로 반올림하고 끝의 0을 제거한다 (예: 12.5 → "12.5", 0.1 + 0.2 → "0.3").
+ */
+export function formatEPCAmount(amount: number): string {
+  if (!Number.isFinite(amount) || Math.abs(amount) >= 1e21) {
+    throw new Error(`잘못된 EPC 금액: ${amount}`);
+  }
+
+  const formatted = amount.toFixed(EPC_DECIMALS).replace(/\.?0+$/, '');
+  return formatted === '-0' ? '0' : formatted;
+}
+
 @Injectable()
 export class EPCBlockchainService {
   private readonly epcChaincode: string;
   private readonly recTokenChaincode: string;
+  private readonly meteringChaincode: string;
 
   constructor(
     private readonly blockchainService: BlockchainService,
@@ -19,6 +41,10 @@ export class EPCBlockchainService {
       'FABRIC_CHAINCODE_REC_TOKEN',
       'rec-token-cc',
     );
+    this.meteringChaincode = this.configService.get(
+      'FABRIC_CHAINCODE_METERING',
+      'metering-cc',
+    );
   }
 
   // ========== EPC 토큰 ==========
@@ -33,12 +59,31 @@ export class EPCBlockchainService {
       this.epcChaincode,
       'Mint',
       userId,
-      amount.toString(),
+      formatEPCAmount(amount),
       reason,
       refId,
     );
   }
 
+  /**
+   * 검증된 발전 측정값에 대한 보상 발행
+   * 측정값은 metering 체인코드에 청구 표시가 남아 다시 청구할 수 없다.
+   * 측정값 원문은 트랜지언트 데이터로 보증 피어에만 전달되고 공개 약정과 대조된다.
+   */
+  async claimGenerationReward(
+    userId: string,
+    meterRecordIds: string[],
+  ): Promise<string> {
+    const disclosures = await this.discloseMeterRecords(meterRecordIds);
+    return this.blockchainService.submitPrivateTransaction(
+      this.epcChaincode,
+      'ClaimGenerationReward',
+      { meterRecords: disclosures },
+      userId,
+      JSON.stringify(meterRecordIds),
+    );
+  }
+
   async burn(
     userId: string,
     amount: number,
@@ -49,7 +94,7 @@ export class EPCBlockchainService {
       this.epcChaincode,
       'Burn',
       userId,
-      amount.toString(),
+      formatEPCAmount(amount),
       reason,
       refId,
     );
@@ -67,19 +112,28 @@ export class EPCBlockchainService {
       'Transfer',
       from,
       to,
-      amount.toString(),
+      formatEPCAmount(amount),
       reason,
       refId,
     );
   }
 
-  async lock(userId: string, amount: number, refId: string): Promise<string> {
+  /** 에스크로 잠금 (만료 후 소유자가 ReclaimExpired로 회수 가능) */
+  async lock(
+    userId: string,
+    amount: number,
+    refId: string,
+    expiresAt: Date = new Date(Date.now() + ESCROW_TTL_MS),
+    beneficiaryId = '',
+  ): Promise<string> {
     return this.blockchainService.submitTransaction(
       this.epcChaincode,
       'Lock',
       userId,
-      amount.toString(),
+      formatEPCAmount(amount),
       refId,
+      beneficiaryId,
+      expiresAt.toISOString(),
     );
   }
 
@@ -92,7 +146,7 @@ export class EPCBlockchainService {
       this.epcChaincode,
       'Unlock',
       userId,
-      amount.toString(),
+      formatEPCAmount(amount),
       refId,
     );
   }
@@ -127,59 +181,108 @@ export class EPCBlockchainService {
 
   // ========== REC 토큰 ==========
 
+  /**
+   * 검증된 발전량으로 REC 토큰 발행 (운영 기관 관리자 신원 필요)
+   *
+   * metering 체인코드의 이월 발전량(GetRECGenerationCarry)과 meterRecordIds 측정값(같은 디바이스·발전 연도의
+   * REC 미사용 VALID 측정값)에서 quantityMWh(1 MWh 단위)만큼 사용하며, 에너지원·위치·근거 측정값은 체인코드가 채운다.
+   */
   async issueRECToken(
     tokenId: string,
     certId: string,
-    tradeId: string,
-    issuerId: string,
-    ownerId: string,
-    energySource: string,
-    quantity: number,
+    userId: string,
+    deviceId: string,
     vintage: string,
-    location: string,
+    quantityMWh: number,
     validUntil: string,
     metadataHash: string,
+    meterRecordIds: string[],
   ): Promise<string> {
-    return this.blockchainService.submitTransaction(
+    const disclosures = await this.discloseMeterRecords(meterRecordIds);
+    return this.blockchainService.submitPrivateTransaction(
       this.recTokenChaincode,
       'IssueREC',
+      { meterRecords: disclosures },
       tokenId,
       certId,
-      tradeId,
-      issuerId,
-      ownerId,
-      energySource,
-      quantity.toString(),
+      userId,
+      deviceId,
       vintage,
-      location,
+      quantityMWh.toString(),
       validUntil,
       metadataHash,
     );
   }
 
-  async transferRECToken(
-    tokenId: string,
-    fromId: string,
-    toId: string,
-  ): Promise<string> {
+  /** 양도인은 체인코드가 현재 소유자로 결정 */
+  async transferRECToken(tokenId: string, toId: string): Promise<string> {
     return this.blockchainService.submitTransaction(
       this.recTokenChaincode,
       'TransferREC',
       tokenId,
-      fromId,
       toId,
     );
   }
 
+  /** 하위 토큰 ID는 {tokenId}-{순번(1부터)} */
+  async splitRECToken(tokenId: string, quantities: number[]): Promise<string> {
+    return this.blockchainService.submitTransaction(
+      this.recTokenChaincode,
+      'SplitREC',
+      tokenId,
+      JSON.stringify(quantities),
+    );
+  }
+
+  /** 병합된 토큰 ID는 트랜잭션 ID */
+  async mergeRECTokens(tokenIds: string[]): Promise<string> {
+    return this.blockchainService.submitTransaction(
+      this.recTokenChaincode,
+      'MergeREC',
+      JSON.stringify(tokenIds),
+    );
+  }
+
+  /**
+   * REC 토큰 소멸 (quantityMWh가 토큰 수량보다 작으면 나머지는 {tokenId}-2 토큰으로 남음)
+   * @returns 소멸 증명서 JSON (retirementId로 GetRetirementCertificate 조회)
+   */
   async retireRECToken(
     tokenId: string,
-    retiredBy: string,
+    quantityMWh: number,
+    beneficiary: string,
+    reportingYear: number,
+    purpose: string,
+    consumptionLocation: string,
   ): Promise<string> {
     return this.blockchainService.submitTransaction(
       this.recTokenChaincode,
       'RetireREC',
       tokenId,
-      retiredBy,
+      quantityMWh.toString(),
+      beneficiary,
+      reportingYear.toString(),
+      purpose,
+      consumptionLocation,
+    );
+  }
+
+  // ========== 내부 헬퍼 ==========
+
+  /**
+   * 근거 측정값 원문(GetMeterRecord 결과, salt 포함)의 JSON 배열
+   * 체인코드가 공개 약정과 해시를 대조하므로 조회 결과 문자열을 다시 직렬화하지 않고 그대로 잇는다.
+   */
+  private async discloseMeterRecords(recordIds: string[]): Promise<string> {
+    const records = await Promise.all(
+      recordIds.map((recordId) =>
+        this.blockchainService.evaluateTransaction(
+          this.meteringChaincode,
+          'GetMeterRecord',
+          recordId,
+        ),
+      ),
     );
+    return `[${records.join(',')}]`;
   }
 }
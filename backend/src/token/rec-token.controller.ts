@@ -10,7 +10,7 @@ import {
 } from '@nestjs/common';
 import { ApiTags, ApiBearerAuth, ApiOperation } from '@nestjs/swagger';
 import { RECTokenService } from './rec-token.service';
-import { TransferRECDto } from './dto/transfer-token.dto';
+import { RetireRECDto, TransferRECDto } from './dto/transfer-token.dto';
 import { JwtAuthGuard } from '../auth/guards/jwt-auth.guard';
 import { EnergySource } from '@prisma/client';
 
@@ -60,8 +60,12 @@ export class RECTokenController {
 
   @Post(':id/retire')
   @ApiOperation({ summary: 'REC 토큰 소멸 (RE100)' })
-  async retire(@Param('id') id: string, @Request() req: any) {
-    return this.recTokenService.retire(id, req.user.id);
+  async retire(
+    @Param('id') id: string,
+    @Request() req: any,
+    @Body() dto: RetireRECDto,
+  ) {
+    return this.recTokenService.retire(id, req.user.id, dto);
   }
 
   @Post('issue/:certId')
@@ -9,6 +9,7 @@ import { EPCBlockchainService } from './epc-blockchain.service';
 import { EventsGateway } from '../common/gateways/events.gateway';
 import { RECTokenStatus, EnergySource } from '@prisma/client';
 import { createHash } from 'crypto';
+import { RetireRECDto } from './dto/transfer-token.dto';
 
 @Injectable()
 export class RECTokenService {
@@ -69,29 +70,8 @@ export class RECTokenService {
       },
     });
 
-    // 블록체인에 기록
-    try {
-      const txHash = await this.epcBlockchain.issueRECToken(
-        recToken.id,
-        certId,
-        cert.tradeId,
-        cert.supplierId,
-        cert.consumerId,
-        cert.energySource,
-        cert.quantity,
-        vintage,
-        '',
-        cert.validUntil.toISOString(),
-        metadataHash,
-      );
-
-      await this.prisma.rECToken.update({
-        where: { id: recToken.id },
-        data: { txHash },
-      });
-    } catch (error) {
-      this.logger.error(`블록체인 REC 발행 실패: ${error.message}`);
-    }
+    // 거래 기반 인증서는 trading 체인코드(IssueREC)에 기록되어 있다. 온체인 REC 토큰은
+    // 검증된 발전량으로만 발행되므로(EPCBlockchainService.issueRECToken) 여기서는 기록하지 않는다.
 
     this.eventsGateway.emitRECTokenUpdate({
       action: 'issued',
@@ -117,7 +97,7 @@ export class RECTokenService {
     }
 
     try {
-      await this.epcBlockchain.transferRECToken(tokenId, fromUserId, toUserId);
+      await this.epcBlockchain.transferRECToken(tokenId, toUserId);
     } catch (error) {
       this.logger.error(`블록체인 REC 양도 실패: ${error.message}`);
     }
@@ -136,7 +116,7 @@ export class RECTokenService {
   }
 
   /** REC 토큰 소멸 (RE100 달성 처리) */
-  async retire(tokenId: string, userId: string) {
+  async retire(tokenId: string, userId: string, claim: RetireRECDto) {
     const token = await this.prisma.rECToken.findUnique({
       where: { id: tokenId },
     });
@@ -151,7 +131,14 @@ export class RECTokenService {
     }
 
     try {
-      await this.epcBlockchain.retireRECToken(tokenId, userId);
+      await this.epcBlockchain.retireRECToken(
+        tokenId,
+        token.quantity,
+        claim.beneficiary,
+        claim.reportingYear,
+        claim.purpose,
+        claim.consumptionLocation,
+      );
     } catch (error) {
       this.logger.error(`블록체인 REC 소멸 실패: ${error.message}`);
     }
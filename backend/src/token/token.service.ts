@@ -95,39 +95,52 @@ export class TokenService {
     return tx;
   }
 
-  /** 정산 수수료 소각 */
-  async burnForSettlement(
-    userId: string,
+  /**
+   * 체인코드 SettleTrade 결과 반영
+   *
+   * 온체인 지급은 settlement 체인코드가 구매 주문 에스크로에서 수행하므로
+   * 여기서는 구매자 잠금 잔액 차감과 판매자 순액 입금만 기록한다.
+   * 수수료는 수수료 계정(사용자 아님)으로 지급되어 toId 없이 기록한다.
+   */
+  async applyLockedSettlement(
+    buyerId: string,
+    sellerId: string,
     amount: number,
+    fee: number,
     settlementId: string,
+    txHash: string | null,
   ) {
-    const wallet = await this.getOrCreateWallet(userId);
-    if (wallet.balance < amount) {
-      throw new BadRequestException('EPC 잔액이 부족합니다');
-    }
+    const netAmount = amount - fee;
 
-    let txHash: string | null = null;
-    try {
-      txHash = await this.epcBlockchain.burn(
-        userId,
-        amount,
-        'settlement_fee',
-        settlementId,
-      );
-    } catch (error) {
-      this.logger.error(`블록체인 burn 실패: ${error.message}`);
-    }
-
-    const [updated, tx] = await this.prisma.$transaction([
+    const [buyerUpdated, sellerUpdated] = await this.prisma.$transaction([
       this.prisma.tokenBalance.update({
-        where: { userId },
-        data: { balance: { decrement: amount } },
+        where: { userId: buyerId },
+        data: {
+          balance: { decrement: amount },
+          lockedBalance: { decrement: amount },
+        },
+      }),
+      this.prisma.tokenBalance.upsert({
+        where: { userId: sellerId },
+        update: { balance: { increment: netAmount } },
+        create: { userId: sellerId, balance: netAmount, lockedBalance: 0 },
       }),
       this.prisma.tokenTransaction.create({
         data: {
-          type: TokenTxType.BURN,
-          fromId: userId,
-          amount,
+          type: TokenTxType.TRANSFER,
+          fromId: buyerId,
+          toId: sellerId,
+          amount: netAmount,
+          reason: 'settlement',
+          refId: settlementId,
+          txHash,
+        },
+      }),
+      this.prisma.tokenTransaction.create({
+        data: {
+          type: TokenTxType.TRANSFER,
+          fromId: buyerId,
+          amount: fee,
           reason: 'settlement_fee',
           refId: settlementId,
           txHash,
@@ -136,12 +149,15 @@ export class TokenService {
     ]);
 
     this.eventsGateway.emitTokenBalanceUpdate({
-      userId,
-      balance: updated.balance,
-      lockedBalance: updated.lockedBalance,
+      userId: buyerId,
+      balance: buyerUpdated.balance,
+      lockedBalance: buyerUpdated.lockedBalance,
+    });
+    this.eventsGateway.emitTokenBalanceUpdate({
+      userId: sellerId,
+      balance: sellerUpdated.balance,
+      lockedBalance: sellerUpdated.lockedBalance,
     });
-
-    return tx;
   }
 
   /** EPC 이체 */
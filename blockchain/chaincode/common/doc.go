@@ -0,0 +1,7 @@
+// Package common - ETP 체인코드 공통 원장 헬퍼
+//
+// JSON 상태 조회/저장, 비공개 데이터와 트랜지언트 입력, 솔트 해시, 복합키, 이력/범위 순회와
+// 페이지 조회, CouchDB 선택자 쿼리, 트랜잭션 타임스탬프, 호출자 신원 조회를 제공한다.
+// 각 체인코드 모듈은 go.mod의 replace 지시어로 ../common을 참조하므로, 체인코드 패키징 전에
+// 모듈 디렉터리에서 `go mod vendor`를 실행해 의존성을 포함시켜야 한다.
+package common
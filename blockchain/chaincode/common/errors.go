@@ -0,0 +1,52 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound - 원장에 상태가 없을 때의 기준 오류 (errors.Is로 판별)
+var ErrNotFound = errors.New("not found")
+
+// ErrStop - 순회 콜백이 반환하면 오류 없이 순회를 중단한다
+var ErrStop = errors.New("stop iteration")
+
+var errPageSize = errors.New("pageSize는 0보다 커야 합니다")
+
+// NotFound - "<대상>을(를) 찾을 수 없습니다: <id>" 오류 생성
+func NotFound(label string, id string) error {
+	return &notFoundError{label: label, id: id}
+}
+
+// Failed - "<작업> 실패: <원인>" 형식으로 오류 래핑
+func Failed(op string, err error) error {
+	return fmt.Errorf("%s 실패: %w", op, err)
+}
+
+// objectParticle - 대상 라벨의 마지막 글자 받침에 따른 목적격 조사(을/를)
+func objectParticle(label string) string {
+	runes := []rune(label)
+	if len(runes) == 0 {
+		return "를"
+	}
+
+	last := runes[len(runes)-1]
+	if last >= 0xAC00 && last <= 0xD7A3 && (last-0xAC00)%28 != 0 {
+		return "을"
+	}
+
+	return "를"
+}
+
+type notFoundError struct {
+	label string
+	id    string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("%s%s 찾을 수 없습니다: %s", e.label, objectParticle(e.label), e.id)
+}
+
+func (e *notFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 등록 인증서 기본 속성명
+const (
+	DefaultRoleAttribute   = "role"
+	DefaultUserIDAttribute = "userId"
+)
+
+// CallerIdentity - 호출자 신원 정보
+type CallerIdentity struct {
+	ID     string `json:"id"`
+	MSPID  string `json:"mspId"`
+	Role   string `json:"role"`
+	UserID string `json:"userId"`
+}
+
+// GetCallerIdentity - 호출자 인증서에서 ID, MSP, 역할/사용자 ID 속성 조회
+func GetCallerIdentity(ctx contractapi.TransactionContextInterface, roleAttribute string, userIDAttribute string) (*CallerIdentity, error) {
+	identity := ctx.GetClientIdentity()
+
+	id, err := identity.GetID()
+	if err != nil {
+		return nil, Failed("호출자 ID 조회", err)
+	}
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, Failed("호출자 MSP 조회", err)
+	}
+	role, _, err := identity.GetAttributeValue(roleAttribute)
+	if err != nil {
+		return nil, Failed("호출자 역할 속성 조회", err)
+	}
+	userID, _, err := identity.GetAttributeValue(userIDAttribute)
+	if err != nil {
+		return nil, Failed("호출자 사용자 ID 속성 조회", err)
+	}
+
+	return &CallerIdentity{ID: id, MSPID: mspID, Role: role, UserID: userID}, nil
+}
+
+// GetCaller - 기본 속성명으로 호출자 신원 조회
+func GetCaller(ctx contractapi.TransactionContextInterface) (*CallerIdentity, error) {
+	return GetCallerIdentity(ctx, DefaultRoleAttribute, DefaultUserIDAttribute)
+}
+
+// RequireMSP - 호출자 MSP가 허용 목록에 있는지 검사
+func RequireMSP(ctx contractapi.TransactionContextInterface, allowed ...string) (*CallerIdentity, error) {
+	caller, err := GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !Contains(allowed, caller.MSPID) {
+		return nil, fmt.Errorf("권한 없음: 허용되지 않은 MSP입니다 (MSP: %s, 허용: %v)", caller.MSPID, allowed)
+	}
+
+	return caller, nil
+}
+
+// Contains - 문자열 목록 포함 여부
+func Contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
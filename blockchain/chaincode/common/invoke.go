@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// InvokeChaincode - 같은 채널의 다른 체인코드 함수 호출
+//
+// 호출된 체인코드의 읽기/쓰기는 현재 트랜잭션에 포함되므로 어느 한 단계라도 실패하면
+// 전체 트랜잭션이 무효가 된다. 호출 대상은 원 트랜잭션 제출자의 신원으로 권한을 검사한다.
+func InvokeChaincode(ctx contractapi.TransactionContextInterface, chaincodeName string, function string, args ...string) ([]byte, error) {
+	invokeArgs := make([][]byte, 0, len(args)+1)
+	invokeArgs = append(invokeArgs, []byte(function))
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := ctx.GetStub().InvokeChaincode(chaincodeName, invokeArgs, "")
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return nil, fmt.Errorf("%s.%s 호출 실패: %s", chaincodeName, function, response.Message)
+	}
+
+	return response.Payload, nil
+}
+
+// ProposalChaincode - 트랜잭션 제안이 호출한 체인코드 이름
+//
+// 체인코드 간 호출에도 원 제안이 그대로 전달되므로, 호출된 체인코드는 이 값으로
+// 어느 체인코드의 트랜잭션 안에서 실행되는지 확인할 수 있다.
+func ProposalChaincode(ctx contractapi.TransactionContextInterface) (string, error) {
+	signed, err := ctx.GetStub().GetSignedProposal()
+	if err != nil {
+		return "", Failed("트랜잭션 제안 조회", err)
+	}
+	if signed == nil {
+		return "", fmt.Errorf("트랜잭션 제안이 없습니다")
+	}
+
+	var proposal pb.Proposal
+	if err := proto.Unmarshal(signed.ProposalBytes, &proposal); err != nil {
+		return "", Failed("트랜잭션 제안 역직렬화", err)
+	}
+	var header cb.Header
+	if err := proto.Unmarshal(proposal.Header, &header); err != nil {
+		return "", Failed("제안 헤더 역직렬화", err)
+	}
+	var channelHeader cb.ChannelHeader
+	if err := proto.Unmarshal(header.ChannelHeader, &channelHeader); err != nil {
+		return "", Failed("채널 헤더 역직렬화", err)
+	}
+	var extension pb.ChaincodeHeaderExtension
+	if err := proto.Unmarshal(channelHeader.Extension, &extension); err != nil {
+		return "", Failed("체인코드 헤더 역직렬화", err)
+	}
+	if extension.ChaincodeId == nil || extension.ChaincodeId.Name == "" {
+		return "", fmt.Errorf("트랜잭션 제안에 체인코드 이름이 없습니다")
+	}
+
+	return extension.ChaincodeId.Name, nil
+}
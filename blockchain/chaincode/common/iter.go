@@ -0,0 +1,250 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Page - 북마크 기반 페이지 조회 결과
+//
+// contractapi 메타데이터가 제네릭 타입명을 처리하지 못하므로 컨트랙트 반환 타입으로는
+// 각 체인코드의 구체 페이지 타입(예: TradeRecordPage)으로 옮겨 담아 사용한다.
+type Page[T any] struct {
+	Records      []T    `json:"records"`
+	Bookmark     string `json:"bookmark"`
+	FetchedCount int32  `json:"fetchedCount"`
+}
+
+// GetHistory - 키의 변경 이력을 T 목록으로 조회 (삭제/역직렬화 불가 항목은 건너뜀)
+func GetHistory[T any](ctx contractapi.TransactionContextInterface, key string, label string) ([]T, error) {
+	historyIter, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, Failed(label+" 이력 조회", err)
+	}
+	defer historyIter.Close()
+
+	var records []T
+	for historyIter.HasNext() {
+		modification, err := historyIter.Next()
+		if err != nil {
+			return nil, Failed("이력 순회", err)
+		}
+		if modification.IsDelete {
+			continue
+		}
+
+		var record T
+		if err := json.Unmarshal(modification.Value, &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// ForEachInRange - [startKey, endKey) 범위의 키/값 순회
+func ForEachInRange(ctx contractapi.TransactionContextInterface, startKey string, endKey string, fn func(key string, value []byte) error) error {
+	resultsIter, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return Failed("범위 조회", err)
+	}
+
+	return forEach(resultsIter, fn)
+}
+
+// ForEachByPartialKey - 부분 복합키에 해당하는 키/값 순회
+func ForEachByPartialKey(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, fn func(key string, value []byte) error) error {
+	resultsIter, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return Failed("복합키 조회", err)
+	}
+
+	return forEach(resultsIter, fn)
+}
+
+// GetRange - [startKey, endKey) 범위의 JSON 상태를 T 목록으로 조회
+func GetRange[T any](ctx contractapi.TransactionContextInterface, startKey string, endKey string, label string) ([]T, error) {
+	var records []T
+	err := ForEachInRange(ctx, startKey, endKey, func(key string, value []byte) error {
+		var record T
+		if err := json.Unmarshal(value, &record); err != nil {
+			return Failed(label+" 역직렬화", err)
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetHistoryPage - 키 변경 이력의 페이지 조회
+//
+// GetHistoryForKey는 페이지 조회를 지원하지 않으므로 북마크는 다음 이력 항목의 위치(오프셋)다.
+func GetHistoryPage[T any](ctx contractapi.TransactionContextInterface, key string, pageSize int32, bookmark string, label string) (*Page[T], error) {
+	if pageSize <= 0 {
+		return nil, Failed(label+" 이력 페이지 조회", errPageSize)
+	}
+
+	offset := 0
+	if bookmark != "" {
+		parsed, err := strconv.Atoi(bookmark)
+		if err != nil || parsed < 0 {
+			return nil, Failed(label+" 이력 페이지 조회", fmt.Errorf("잘못된 북마크: %s", bookmark))
+		}
+		offset = parsed
+	}
+
+	historyIter, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, Failed(label+" 이력 조회", err)
+	}
+	defer historyIter.Close()
+
+	page := &Page[T]{Records: []T{}}
+	for position := 0; historyIter.HasNext(); position++ {
+		modification, err := historyIter.Next()
+		if err != nil {
+			return nil, Failed("이력 순회", err)
+		}
+		if position < offset || modification.IsDelete {
+			continue
+		}
+
+		var record T
+		if err := json.Unmarshal(modification.Value, &record); err != nil {
+			continue
+		}
+		page.Records = append(page.Records, record)
+
+		if int32(len(page.Records)) == pageSize {
+			if historyIter.HasNext() {
+				page.Bookmark = strconv.Itoa(position + 1)
+			}
+			break
+		}
+	}
+	page.FetchedCount = int32(len(page.Records))
+
+	return page, nil
+}
+
+// ForEachInRangePage - 범위 조회의 한 페이지 키/값 순회, 다음 북마크와 조회 건수 반환
+//
+// 페이지 조회 API는 읽기 전용(evaluate) 트랜잭션에서만 사용할 수 있다.
+func ForEachInRangePage(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int32, bookmark string, fn func(key string, value []byte) error) (string, int32, error) {
+	if pageSize <= 0 {
+		return "", 0, Failed("범위 페이지 조회", errPageSize)
+	}
+
+	resultsIter, metadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err != nil {
+		return "", 0, Failed("범위 페이지 조회", err)
+	}
+
+	if err := forEach(resultsIter, fn); err != nil {
+		return "", 0, err
+	}
+
+	return metadata.GetBookmark(), metadata.GetFetchedRecordsCount(), nil
+}
+
+// ForEachByPartialKeyPage - 부분 복합키 조회의 한 페이지 키/값 순회, 다음 북마크와 조회 건수 반환
+//
+// 북마크는 다음 페이지의 시작 키이므로 같은 부분 복합키 아래의 임의 키로 시작 위치를 지정할 수 있다.
+// 페이지 조회 API는 읽기 전용(evaluate) 트랜잭션에서만 사용할 수 있다.
+func ForEachByPartialKeyPage(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, pageSize int32, bookmark string, fn func(key string, value []byte) error) (string, int32, error) {
+	if pageSize <= 0 {
+		return "", 0, Failed("복합키 페이지 조회", errPageSize)
+	}
+
+	resultsIter, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, attributes, pageSize, bookmark)
+	if err != nil {
+		return "", 0, Failed("복합키 페이지 조회", err)
+	}
+
+	if err := forEach(resultsIter, fn); err != nil {
+		return "", 0, err
+	}
+
+	return metadata.GetBookmark(), metadata.GetFetchedRecordsCount(), nil
+}
+
+// GetRangePage - 범위 조회의 JSON 상태 페이지 조회
+func GetRangePage[T any](ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int32, bookmark string, label string) (*Page[T], error) {
+	page := &Page[T]{Records: []T{}}
+
+	next, fetched, err := ForEachInRangePage(ctx, startKey, endKey, pageSize, bookmark, func(key string, value []byte) error {
+		var record T
+		if err := json.Unmarshal(value, &record); err != nil {
+			return Failed(label+" 역직렬화", err)
+		}
+		page.Records = append(page.Records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.Bookmark = next
+	page.FetchedCount = fetched
+
+	return page, nil
+}
+
+// QueryPage - CouchDB 선택자 쿼리의 페이지 조회 (읽기 전용 트랜잭션 전용)
+func QueryPage[T any](ctx contractapi.TransactionContextInterface, query string, pageSize int32, bookmark string, label string) (*Page[T], error) {
+	if pageSize <= 0 {
+		return nil, Failed(label+" 쿼리", errPageSize)
+	}
+
+	resultsIter, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, Failed(label+" 쿼리", err)
+	}
+
+	page := &Page[T]{Records: []T{}}
+	err = forEach(resultsIter, func(key string, value []byte) error {
+		var record T
+		if err := json.Unmarshal(value, &record); err != nil {
+			return Failed(label+" 역직렬화", err)
+		}
+		page.Records = append(page.Records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.Bookmark = metadata.GetBookmark()
+	page.FetchedCount = metadata.GetFetchedRecordsCount()
+
+	return page, nil
+}
+
+func forEach(resultsIter shim.StateQueryIteratorInterface, fn func(key string, value []byte) error) error {
+	defer resultsIter.Close()
+
+	for resultsIter.HasNext() {
+		result, err := resultsIter.Next()
+		if err != nil {
+			return Failed("순회", err)
+		}
+		if err := fn(result.Key, result.Value); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CompositeKey - objectType과 속성으로 복합키 생성
+func CompositeKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", Failed("복합키 생성", err)
+	}
+
+	return key, nil
+}
+
+// SplitCompositeKey - 복합키를 objectType과 속성으로 분해
+func SplitCompositeKey(ctx contractapi.TransactionContextInterface, key string) (string, []string, error) {
+	objectType, attributes, err := ctx.GetStub().SplitCompositeKey(key)
+	if err != nil {
+		return "", nil, Failed("복합키 분해", err)
+	}
+
+	return objectType, attributes, nil
+}
+
+// PrefixRange - 단순 접두사 키("BAL_" 등)의 범위 조회용 시작/끝 키
+func PrefixRange(prefix string) (string, string) {
+	return prefix, prefix + "~"
+}
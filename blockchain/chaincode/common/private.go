@@ -0,0 +1,241 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MinSaltLength - 공개 상태에 남기는 해시의 솔트 최소 길이(바이트)
+//
+// 측정값·역할처럼 값의 범위가 좁은 데이터는 솔트 없이 해시하면 전수 대입으로 복원되므로
+// 클라이언트가 기록마다 임의의 솔트를 생성해 비공개 데이터와 함께 전달한다.
+const MinSaltLength = 16
+
+// GetTransient - 트랜지언트 맵의 JSON 값을 T로 역직렬화
+//
+// 트랜지언트 데이터는 트랜잭션 제안에만 포함되고 블록에는 기록되지 않으므로
+// 비공개 데이터는 함수 인자 대신 트랜지언트 맵으로 전달받는다.
+func GetTransient[T any](ctx contractapi.TransactionContextInterface, key string, label string) (*T, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, Failed("트랜지언트 데이터 조회", err)
+	}
+	data, ok := transient[key]
+	if !ok || len(data) == 0 {
+		return nil, fmt.Errorf("트랜지언트 데이터에 %s 이(가) 없습니다 (키: %s)", label, key)
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, Failed(label+" 역직렬화", err)
+	}
+
+	return &value, nil
+}
+
+// GetPrivateState - 비공개 데이터 컬렉션의 JSON 상태를 T로 역직렬화 (키가 없으면 nil, nil)
+func GetPrivateState[T any](ctx contractapi.TransactionContextInterface, collection string, key string, label string) (*T, error) {
+	data, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, Failed(label+" 비공개 데이터 조회", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, Failed(label+" 역직렬화", err)
+	}
+
+	return &value, nil
+}
+
+// MustGetPrivateState - GetPrivateState와 같으나 키가 없으면 NotFound 오류 반환
+func MustGetPrivateState[T any](ctx contractapi.TransactionContextInterface, collection string, key string, label string, id string) (*T, error) {
+	value, err := GetPrivateState[T](ctx, collection, key, label)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, NotFound(label, id)
+	}
+
+	return value, nil
+}
+
+// PutPrivateState - 값을 JSON으로 직렬화하여 비공개 데이터 컬렉션에 저장
+func PutPrivateState(ctx contractapi.TransactionContextInterface, collection string, key string, value interface{}, label string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Failed(label+" 직렬화", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData(collection, key, data); err != nil {
+		return Failed(label+" 비공개 데이터 저장", err)
+	}
+
+	return nil
+}
+
+// PrivateKey - 비공개 데이터 인덱스 키 (복합키에서 맨 앞 \x00을 뺀 objectType\x00속성1\x00...\x00)
+//
+// GetPrivateDataByRange는 \x00으로 시작하는 복합키를 받지 않으므로, 북마크부터 범위 조회할 수 있도록
+// 비공개 컬렉션의 인덱스는 이 형식으로 저장한다.
+func PrivateKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	key, err := CompositeKey(ctx, objectType, attributes...)
+	if err != nil {
+		return "", err
+	}
+
+	return key[1:], nil
+}
+
+// SplitPrivateKey - PrivateKey를 objectType과 속성으로 분해
+func SplitPrivateKey(ctx contractapi.TransactionContextInterface, key string) (string, []string, error) {
+	return SplitCompositeKey(ctx, "\x00"+key)
+}
+
+// ForEachPrivateByPartialKey - 비공개 데이터 컬렉션에서 objectType과 앞쪽 속성이 일치하는 PrivateKey 키/값 순회
+func ForEachPrivateByPartialKey(ctx contractapi.TransactionContextInterface, collection string, objectType string, attributes []string, fn func(key string, value []byte) error) error {
+	startKey, endKey, err := privateKeyRange(ctx, objectType, attributes)
+	if err != nil {
+		return err
+	}
+
+	return ForEachPrivateInRange(ctx, collection, startKey, endKey, fn)
+}
+
+// ForEachPrivateInRange - 비공개 데이터 컬렉션의 [startKey, endKey) 범위 키/값 순회
+func ForEachPrivateInRange(ctx contractapi.TransactionContextInterface, collection string, startKey string, endKey string, fn func(key string, value []byte) error) error {
+	resultsIter, err := ctx.GetStub().GetPrivateDataByRange(collection, startKey, endKey)
+	if err != nil {
+		return Failed("비공개 범위 조회", err)
+	}
+
+	return forEach(resultsIter, fn)
+}
+
+// ForEachPrivateByPartialKeyPage - 비공개 데이터의 부분 키 조회를 페이지 단위로 순회
+//
+// 비공개 데이터는 페이지 조회 API가 없으므로 ForEachByPartialKeyPage와 같은 의미(북마크 = 다음 페이지의
+// 시작 키)가 되도록 북마크부터 범위 조회하고 pageSize건 이후의 첫 키를 다음 북마크로 반환한다.
+func ForEachPrivateByPartialKeyPage(ctx contractapi.TransactionContextInterface, collection string, objectType string, attributes []string, pageSize int32, bookmark string, fn func(key string, value []byte) error) (string, int32, error) {
+	if pageSize <= 0 {
+		return "", 0, Failed("비공개 부분 키 페이지 조회", errPageSize)
+	}
+
+	startKey, endKey, err := privateKeyRange(ctx, objectType, attributes)
+	if err != nil {
+		return "", 0, err
+	}
+	if bookmark != "" {
+		if bookmark < startKey || bookmark >= endKey {
+			return "", 0, Failed("비공개 부분 키 페이지 조회", fmt.Errorf("잘못된 북마크: %q", bookmark))
+		}
+		startKey = bookmark
+	}
+
+	var next string
+	var fetched int32
+	err = ForEachPrivateInRange(ctx, collection, startKey, endKey, func(key string, value []byte) error {
+		if fetched == pageSize {
+			next = key
+			return ErrStop
+		}
+
+		fetched++
+		return fn(key, value)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return next, fetched, nil
+}
+
+// CheckSalt - 솔트 길이 검사
+func CheckSalt(salt string) error {
+	if len(salt) < MinSaltLength {
+		return fmt.Errorf("솔트는 최소 %d바이트여야 합니다 (길이: %d)", MinSaltLength, len(salt))
+	}
+	return nil
+}
+
+// SaltedHash - SHA-256(salt || JSON(value))의 16진 문자열
+//
+// 공개 상태에는 이 값만 남기고, 비공개 데이터를 공개받은 측은 같은 값과 솔트로 다시 계산해 대조한다.
+func SaltedHash(salt string, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", Failed("해시 대상 직렬화", err)
+	}
+
+	digest := sha256.Sum256(append([]byte(salt), data...))
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// MeterDisclosureTransientKey - 발전 보상·REC 발행의 근거 측정값을 전달하는 트랜지언트 키
+//
+// 값은 metering GetMeterRecord 결과(salt 포함)의 JSON 배열이며, 보증 피어는 비공개 컬렉션 대신
+// 공개 약정(MeterCommitment)과 대조해 검증한다. 블록에는 기록되지 않는다.
+const MeterDisclosureTransientKey = "meterRecords"
+
+// GetMeterDisclosures - 트랜지언트 맵의 근거 측정값 JSON 배열을 그대로 반환 (REC 이월분만 쓰는 발행은 빈 배열)
+func GetMeterDisclosures(ctx contractapi.TransactionContextInterface) (string, error) {
+	disclosures, err := GetTransient[[]json.RawMessage](ctx, MeterDisclosureTransientKey, "근거 측정값")
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(*disclosures)
+	if err != nil {
+		return "", Failed("근거 측정값 직렬화", err)
+	}
+	return string(data), nil
+}
+
+// MigrationTransientKey - 이전 형식 공개 데이터 이관 시 솔트 시드를 전달하는 트랜지언트 키 (MigrationSeed JSON)
+const MigrationTransientKey = "migration"
+
+// MigrationSeed - 이관 솔트 시드 (운영 기관이 보관하는 비밀 값)
+type MigrationSeed struct {
+	Seed string `json:"seed"`
+}
+
+// GetMigrationSeed - 트랜지언트 맵에서 이관 솔트 시드 조회
+func GetMigrationSeed(ctx contractapi.TransactionContextInterface) (string, error) {
+	input, err := GetTransient[MigrationSeed](ctx, MigrationTransientKey, "이관 솔트 시드")
+	if err != nil {
+		return "", err
+	}
+	if err := CheckSalt(input.Seed); err != nil {
+		return "", err
+	}
+
+	return input.Seed, nil
+}
+
+// DeriveSalt - 이관 기록별 솔트: hex(SHA-256(seed || 0x00 || id))
+//
+// 이전 형식 데이터에는 클라이언트가 만든 솔트가 없으므로 시드에서 기록마다 다른 솔트를 만든다.
+// 시드는 블록에 남지 않아 공개 해시를 전수 대입으로 복원할 수 없다.
+func DeriveSalt(seed string, id string) string {
+	digest := sha256.Sum256([]byte(seed + "\x00" + id))
+	return hex.EncodeToString(digest[:])
+}
+
+// privateKeyRange - objectType과 앞쪽 속성이 일치하는 PrivateKey 범위 [start, end)
+func privateKeyRange(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) (string, string, error) {
+	startKey, err := PrivateKey(ctx, objectType, attributes...)
+	if err != nil {
+		return "", "", err
+	}
+
+	return startKey, startKey + string(utf8.MaxRune), nil
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Selector - CouchDB 선택자(Mango selector) 조건
+//
+// 값이 빈 문자열인 조건은 Eq에서 생략되므로 선택적 필터를 그대로 연결할 수 있다.
+type Selector map[string]interface{}
+
+// NewSelector - 빈 선택자 생성
+func NewSelector() Selector {
+	return Selector{}
+}
+
+// Eq - field == value 조건 추가 (value가 비어 있으면 생략)
+func (s Selector) Eq(field string, value string) Selector {
+	if value != "" {
+		s[field] = value
+	}
+	return s
+}
+
+// Exists - field가 존재하는 문서만 선택 (같은 원장의 다른 문서 유형 제외용)
+func (s Selector) Exists(field string) Selector {
+	s[field] = map[string]bool{"$exists": true}
+	return s
+}
+
+// Or - 하위 선택자 중 하나라도 만족하는 조건 추가
+func (s Selector) Or(conditions ...Selector) Selector {
+	s["$or"] = conditions
+	return s
+}
+
+// Query - GetQueryResult에 전달할 쿼리 문자열 생성
+func (s Selector) Query() (string, error) {
+	query, err := json.Marshal(map[string]interface{}{"selector": s})
+	if err != nil {
+		return "", Failed("쿼리 생성", err)
+	}
+
+	return string(query), nil
+}
+
+// QuerySelectorPage - 선택자로 CouchDB 페이지 조회 (읽기 전용 트랜잭션 전용)
+func QuerySelectorPage[T any](ctx contractapi.TransactionContextInterface, selector Selector, pageSize int32, bookmark string, label string) (*Page[T], error) {
+	query, err := selector.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryPage[T](ctx, query, pageSize, bookmark, label)
+}
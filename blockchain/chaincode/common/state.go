@@ -0,0 +1,80 @@
+package common
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// GetState - 키의 JSON 상태를 T로 역직렬화 (키가 없으면 nil, nil)
+//
+// label은 오류 메시지에 쓰이는 대상 이름이다 (예: "거래", "정산").
+func GetState[T any](ctx contractapi.TransactionContextInterface, key string, label string) (*T, error) {
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, Failed(label+" 조회", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, Failed(label+" 역직렬화", err)
+	}
+
+	return &value, nil
+}
+
+// MustGetState - GetState와 같으나 키가 없으면 NotFound 오류 반환
+func MustGetState[T any](ctx contractapi.TransactionContextInterface, key string, label string, id string) (*T, error) {
+	value, err := GetState[T](ctx, key, label)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, NotFound(label, id)
+	}
+
+	return value, nil
+}
+
+// PutState - 값을 JSON으로 직렬화하여 저장
+func PutState(ctx contractapi.TransactionContextInterface, key string, value interface{}, label string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Failed(label+" 직렬화", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, data); err != nil {
+		return Failed(label+" 저장", err)
+	}
+
+	return nil
+}
+
+// Exists - 키 존재 여부 확인
+func Exists(ctx contractapi.TransactionContextInterface, key string) (bool, error) {
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, Failed("상태 조회", err)
+	}
+
+	return data != nil, nil
+}
+
+// SetEvent - 값을 JSON으로 직렬화하여 체인코드 이벤트 발생
+//
+// Fabric은 트랜잭션당 마지막 이벤트 하나만 전달한다.
+func SetEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Failed("이벤트 직렬화", err)
+	}
+
+	if err := ctx.GetStub().SetEvent(name, data); err != nil {
+		return Failed("이벤트 발생", err)
+	}
+
+	return nil
+}
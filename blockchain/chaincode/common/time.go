@@ -0,0 +1,45 @@
+package common
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TxTime - 트랜잭션 제안 타임스탬프
+//
+// time.Now()는 보증 피어마다 값이 달라 보증 결과 불일치를 일으키므로
+// 원장에 기록되는 모든 시각은 이 값에서 파생한다.
+func TxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, Failed("트랜잭션 타임스탬프 조회", err)
+	}
+
+	return ts.AsTime().UTC(), nil
+}
+
+// TxTimestamp - RFC3339 형식의 트랜잭션 타임스탬프
+func TxTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	t, err := TxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatTime(t), nil
+}
+
+// FormatTime - 원장 기록용 RFC3339(UTC) 문자열
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ParseTime - RFC3339 문자열 파싱
+func ParseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, Failed("시각 파싱 ("+value+")", err)
+	}
+
+	return t.UTC(), nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// operatorMSP - DID 관리자 권한을 인정하는 운영 기관 MSP
+const operatorMSP = "AdminOrgMSP"
+
+// requireAdmin - 운영 기관 관리자 여부 검사
+func requireAdmin(ctx contractapi.TransactionContextInterface) (*common.CallerIdentity, error) {
+	caller, err := common.RequireMSP(ctx, operatorMSP)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role != "admin" {
+		return nil, fmt.Errorf("권한 없음: 관리자 역할이 필요합니다 (역할: %q)", caller.Role)
+	}
+
+	return caller, nil
+}
+
+// requireOwnerOrAdmin - 호출자가 userID 본인이거나 운영 기관 관리자인지 검사
+func requireOwnerOrAdmin(ctx contractapi.TransactionContextInterface, userID string) (*common.CallerIdentity, error) {
+	caller, err := common.GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID != "" && caller.UserID == userID {
+		return caller, nil
+	}
+	if caller.MSPID == operatorMSP && caller.Role == "admin" {
+		return caller, nil
+	}
+
+	return nil, fmt.Errorf("권한 없음: %s 본인 또는 관리자만 가능합니다 (MSP: %s, 역할: %q, 사용자: %q)", userID, caller.MSPID, caller.Role, caller.UserID)
+}
@@ -3,28 +3,62 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"time"
 
+	"github.com/etp/chaincode/common"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// didCollection - DID 소유자 개인정보(사용자 ID, 역할)를 보관하는 비공개 데이터 컬렉션 (collections_config.json)
+//
+// 운영 기관 피어만 데이터를 보관하며, 읽기 권한은 체인코드에서 본인 또는 관리자로 제한한다.
+// 컬렉션 쓰기는 collections_config.json의 endorsementPolicy로 AdminOrg 피어 보증을 요구하고,
+// 다른 조직 피어는 컬렉션을 읽지 못해 시뮬레이션할 수 없으므로 did-cc는 AdminOrg 피어 보증으로 배포한다
+// (network/scripts/setup-network.sh).
+const didCollection = "didPrivateCollection"
+
+// didTransientKey - CreateDID 개인정보 입력 트랜지언트 키 (DIDPrivateData JSON)
+const didTransientKey = "did"
+
 // DIDContract - DID 인증 관리 스마트 컨트랙트
 type DIDContract struct {
 	contractapi.Contract
 }
 
-// DIDDocument - DID 문서 구조체
+// DIDDocument - DID 문서 구조체 (공개 상태)
+//
+// 사용자 ID와 역할은 비공개 컬렉션의 DIDPrivateData에 두고 솔트 해시만 기록한다.
 type DIDDocument struct {
-	DID        string   `json:"did"`
-	UserID     string   `json:"userId"`
-	PublicKey  string   `json:"publicKey"`
-	AuthMethod string   `json:"authMethod"`
-	Role       string   `json:"role"`
-	Org        string   `json:"org"`
-	Status     string   `json:"status"` // ACTIVE, REVOKED
-	CreatedAt  string   `json:"createdAt"`
-	UpdatedAt  string   `json:"updatedAt"`
-	Services   []Service `json:"services"`
+	DID             string    `json:"did"`
+	PublicKey       string    `json:"publicKey"`
+	AuthMethod      string    `json:"authMethod"`
+	Org             string    `json:"org"`
+	Status          string    `json:"status"`          // ACTIVE, REVOKED
+	PrivateDataHash string    `json:"privateDataHash"` // SHA-256(salt || JSON(salt를 비운 DIDPrivateData))
+	CreatedAt       string    `json:"createdAt"`
+	UpdatedAt       string    `json:"updatedAt"`
+	Services        []Service `json:"services"`
+}
+
+// DIDPrivateData - DID 소유자 개인정보 (비공개 컬렉션)
+type DIDPrivateData struct {
+	DID    string `json:"did"`
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+	Salt   string `json:"salt"` // 공개 해시용 솔트 (클라이언트 생성)
+}
+
+// legacyDIDDocument - 비공개 컬렉션 도입 전 DID 문서 (사용자 ID와 역할을 공개 상태에 기록)
+type legacyDIDDocument struct {
+	DIDDocument
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// DIDMigrationResult - 이전 형식 DID 이관 결과
+type DIDMigrationResult struct {
+	Migrated int  `json:"migrated"` // 개인정보를 비공개 컬렉션으로 옮긴 DID
+	Skipped  int  `json:"skipped"`  // 이미 이관되었거나 문서가 없어 공개 인덱스만 삭제한 DID
+	Done     bool `json:"done"`     // 남은 공개 USER_ 인덱스가 없으면 true
 }
 
 // Service - DID 서비스 엔드포인트
@@ -42,42 +76,63 @@ type VerificationResult struct {
 }
 
 // CreateDID - 새 DID 문서 생성
-func (c *DIDContract) CreateDID(ctx contractapi.TransactionContextInterface, did string, userID string, publicKey string, role string, org string) error {
-	existing, err := ctx.GetStub().GetState(did)
+//
+// 사용자 ID, 역할, 솔트는 인자 대신 트랜지언트 맵의 "did" 키에 DIDPrivateData JSON으로 전달한다.
+func (c *DIDContract) CreateDID(ctx contractapi.TransactionContextInterface, did string, publicKey string, org string) error {
+	private, err := common.GetTransient[DIDPrivateData](ctx, didTransientKey, "DID 개인정보")
 	if err != nil {
-		return fmt.Errorf("DID 조회 실패: %v", err)
+		return err
+	}
+	if private.UserID == "" {
+		return fmt.Errorf("사용자 ID가 비어 있습니다")
+	}
+	if err := common.CheckSalt(private.Salt); err != nil {
+		return err
+	}
+	private.DID = did
+
+	existing, err := common.GetState[DIDDocument](ctx, did, "DID")
+	if err != nil {
+		return err
 	}
 	if existing != nil {
 		return fmt.Errorf("DID가 이미 존재합니다: %s", did)
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
-
-	doc := DIDDocument{
-		DID:        did,
-		UserID:     userID,
-		PublicKey:  publicKey,
-		AuthMethod: "Ed25519VerificationKey2020",
-		Role:       role,
-		Org:        org,
-		Status:     "ACTIVE",
-		CreatedAt:  now,
-		UpdatedAt:  now,
-		Services:   []Service{},
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
 	}
 
-	docJSON, err := json.Marshal(doc)
+	hash, err := private.hash()
 	if err != nil {
-		return fmt.Errorf("DID 직렬화 실패: %v", err)
+		return err
+	}
+
+	doc := DIDDocument{
+		DID:             did,
+		PublicKey:       publicKey,
+		AuthMethod:      "Ed25519VerificationKey2020",
+		Org:             org,
+		Status:          "ACTIVE",
+		PrivateDataHash: hash,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Services:        []Service{},
 	}
 
 	// DID -> Document 매핑
-	if err := ctx.GetStub().PutState(did, docJSON); err != nil {
-		return fmt.Errorf("DID 저장 실패: %v", err)
+	if err := common.PutState(ctx, did, doc, "DID"); err != nil {
+		return err
+	}
+
+	// DID -> 개인정보 매핑
+	if err := common.PutPrivateState(ctx, didCollection, did, private, "DID 개인정보"); err != nil {
+		return err
 	}
 
-	// UserID -> DID 역방향 인덱스
-	if err := ctx.GetStub().PutState("USER_"+userID, []byte(did)); err != nil {
+	// UserID -> DID 역방향 인덱스 (사용자 ID가 공개 키에 드러나지 않도록 비공개 컬렉션에 저장)
+	if err := ctx.GetStub().PutPrivateData(didCollection, "USER_"+private.UserID, []byte(did)); err != nil {
 		return fmt.Errorf("사용자-DID 인덱스 저장 실패: %v", err)
 	}
 
@@ -86,25 +141,29 @@ func (c *DIDContract) CreateDID(ctx contractapi.TransactionContextInterface, did
 
 // GetDID - DID 문서 조회
 func (c *DIDContract) GetDID(ctx contractapi.TransactionContextInterface, did string) (*DIDDocument, error) {
-	docJSON, err := ctx.GetStub().GetState(did)
+	return common.MustGetState[DIDDocument](ctx, did, "DID", did)
+}
+
+// GetDIDPrivateData - DID 소유자 개인정보 조회 (본인 또는 관리자)
+func (c *DIDContract) GetDIDPrivateData(ctx contractapi.TransactionContextInterface, did string) (*DIDPrivateData, error) {
+	private, err := common.MustGetPrivateState[DIDPrivateData](ctx, didCollection, did, "DID 개인정보", did)
 	if err != nil {
-		return nil, fmt.Errorf("DID 조회 실패: %v", err)
-	}
-	if docJSON == nil {
-		return nil, fmt.Errorf("DID를 찾을 수 없습니다: %s", did)
+		return nil, err
 	}
-
-	var doc DIDDocument
-	if err := json.Unmarshal(docJSON, &doc); err != nil {
-		return nil, fmt.Errorf("DID 역직렬화 실패: %v", err)
+	if _, err := requireOwnerOrAdmin(ctx, private.UserID); err != nil {
+		return nil, err
 	}
 
-	return &doc, nil
+	return private, nil
 }
 
-// GetDIDByUserID - 사용자 ID로 DID 조회
+// GetDIDByUserID - 사용자 ID로 DID 조회 (본인 또는 관리자)
 func (c *DIDContract) GetDIDByUserID(ctx contractapi.TransactionContextInterface, userID string) (*DIDDocument, error) {
-	did, err := ctx.GetStub().GetState("USER_" + userID)
+	if _, err := requireOwnerOrAdmin(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	did, err := ctx.GetStub().GetPrivateData(didCollection, "USER_"+userID)
 	if err != nil {
 		return nil, fmt.Errorf("사용자 DID 조회 실패: %v", err)
 	}
@@ -133,6 +192,34 @@ func (c *DIDContract) VerifyDID(ctx contractapi.TransactionContextInterface, did
 	return &VerificationResult{Valid: true, DID: did, Message: "DID 검증 성공"}, nil
 }
 
+// VerifyDIDDisclosure - 공개받은 개인정보(GetDIDPrivateData 결과 JSON, salt 포함)가 DID 문서의 해시와 일치하는지 검증
+//
+// 비공개 컬렉션 구성원이 아닌 조직도 호출할 수 있다. 인자가 블록에 남지 않도록 evaluate로 호출한다.
+func (c *DIDContract) VerifyDIDDisclosure(ctx contractapi.TransactionContextInterface, disclosureJSON string) (*VerificationResult, error) {
+	var private DIDPrivateData
+	if err := json.Unmarshal([]byte(disclosureJSON), &private); err != nil {
+		return nil, fmt.Errorf("공개 개인정보 역직렬화 실패: %v", err)
+	}
+
+	doc, err := common.GetState[DIDDocument](ctx, private.DID, "DID")
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return &VerificationResult{Valid: false, DID: private.DID, Message: "DID를 찾을 수 없습니다"}, nil
+	}
+
+	hash, err := private.hash()
+	if err != nil {
+		return nil, err
+	}
+	if hash != doc.PrivateDataHash {
+		return &VerificationResult{Valid: false, DID: private.DID, Message: "DID 문서의 해시와 일치하지 않습니다"}, nil
+	}
+
+	return &VerificationResult{Valid: true, DID: private.DID, Message: "개인정보 검증 성공"}, nil
+}
+
 // RevokeDID - DID 폐기
 func (c *DIDContract) RevokeDID(ctx contractapi.TransactionContextInterface, did string) error {
 	doc, err := c.GetDID(ctx, did)
@@ -140,15 +227,15 @@ func (c *DIDContract) RevokeDID(ctx contractapi.TransactionContextInterface, did
 		return err
 	}
 
-	doc.Status = "REVOKED"
-	doc.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-
-	docJSON, err := json.Marshal(doc)
+	now, err := common.TxTimestamp(ctx)
 	if err != nil {
-		return fmt.Errorf("DID 직렬화 실패: %v", err)
+		return err
 	}
 
-	return ctx.GetStub().PutState(did, docJSON)
+	doc.Status = "REVOKED"
+	doc.UpdatedAt = now
+
+	return common.PutState(ctx, did, doc, "DID")
 }
 
 // AddService - DID 서비스 엔드포인트 추가
@@ -158,6 +245,11 @@ func (c *DIDContract) AddService(ctx contractapi.TransactionContextInterface, di
 		return err
 	}
 
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	service := Service{
 		ID:              serviceID,
 		Type:            serviceType,
@@ -165,14 +257,111 @@ func (c *DIDContract) AddService(ctx contractapi.TransactionContextInterface, di
 	}
 
 	doc.Services = append(doc.Services, service)
-	doc.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	doc.UpdatedAt = now
+
+	return common.PutState(ctx, did, doc, "DID")
+}
+
+// MigrateLegacyDIDs - 공개 상태의 사용자 ID·역할을 비공개 컬렉션으로 이관 (관리자 전용)
+//
+// 공개 USER_{userID} 인덱스를 따라 이전 형식 문서의 개인정보를 DIDPrivateData로 옮기고 문서에는 해시만 남긴 뒤
+// 공개 인덱스를 삭제한다. 솔트는 트랜지언트 맵 "migration" 키의 시드로 DID마다 만든다(common.DeriveSalt).
+// 한 번에 limit개 인덱스까지 처리하므로 Done이 true가 될 때까지 반복 호출한다.
+// 이전 블록에 기록된 값은 지울 수 없으며 이 함수는 현재 상태(world state)에서만 제거한다.
+func (c *DIDContract) MigrateLegacyDIDs(ctx contractapi.TransactionContextInterface, limit int) (*DIDMigrationResult, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("처리 한도는 1 이상이어야 합니다: %d", limit)
+	}
+
+	seed, err := common.GetMigrationSeed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DIDMigrationResult{Done: true}
+	processed := 0
+
+	startKey, endKey := common.PrefixRange("USER_")
+	err = common.ForEachInRange(ctx, startKey, endKey, func(key string, value []byte) error {
+		if processed == limit {
+			result.Done = false
+			return common.ErrStop
+		}
+		processed++
+
+		moved, err := migrateLegacyDID(ctx, string(value), seed)
+		if err != nil {
+			return err
+		}
+		if moved {
+			result.Migrated++
+		} else {
+			result.Skipped++
+		}
+
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("사용자-DID 공개 인덱스 삭제 실패: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// hash - salt를 비운 개인정보의 솔트 해시
+func (p *DIDPrivateData) hash() (string, error) {
+	unsalted := *p
+	unsalted.Salt = ""
+	return common.SaltedHash(p.Salt, unsalted)
+}
 
-	docJSON, err := json.Marshal(doc)
+// migrateLegacyDID - 이전 형식 DID 문서의 개인정보를 비공개 컬렉션으로 옮기고 문서 재작성
+//
+// 문서가 없거나 이미 해시가 기록된 문서면 아무것도 하지 않고 false를 반환한다.
+func migrateLegacyDID(ctx contractapi.TransactionContextInterface, did string, seed string) (bool, error) {
+	legacy, err := common.GetState[legacyDIDDocument](ctx, did, "DID")
 	if err != nil {
-		return fmt.Errorf("DID 직렬화 실패: %v", err)
+		return false, err
+	}
+	if legacy == nil || legacy.PrivateDataHash != "" || legacy.UserID == "" {
+		return false, nil
+	}
+
+	private := DIDPrivateData{
+		DID:    did,
+		UserID: legacy.UserID,
+		Role:   legacy.Role,
+		Salt:   common.DeriveSalt(seed, did),
+	}
+	hash, err := private.hash()
+	if err != nil {
+		return false, err
+	}
+
+	doc := legacy.DIDDocument
+	doc.PrivateDataHash = hash
+	if doc.Services == nil {
+		doc.Services = []Service{}
+	}
+	if err := common.PutState(ctx, did, doc, "DID"); err != nil {
+		return false, err
+	}
+	if err := common.PutPrivateState(ctx, didCollection, did, private, "DID 개인정보"); err != nil {
+		return false, err
+	}
+	if err := ctx.GetStub().PutPrivateData(didCollection, "USER_"+private.UserID, []byte(did)); err != nil {
+		return false, fmt.Errorf("사용자-DID 인덱스 저장 실패: %v", err)
 	}
 
-	return ctx.GetStub().PutState(did, docJSON)
+	return true, nil
 }
 
 func main() {
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 권한 검사 대상 작업
+const (
+	ActionMint     = "MINT"
+	ActionBurn     = "BURN"
+	ActionSetPrice = "SET_PRICE"
+	ActionLock     = "LOCK"  // 타인 잔액 잠금/해제 (정산 운영자)
+	ActionAdmin    = "ADMIN" // 정책 변경, 마이그레이션
+)
+
+const accessPolicyKey = "EPC_ACCESS_POLICY"
+
+// AccessRule - 작업별 허용 MSP/역할
+//
+// Roles가 비어 있으면 해당 MSP의 모든 역할을 허용한다.
+type AccessRule struct {
+	MSPIDs []string `json:"mspIds"`
+	Roles  []string `json:"roles"`
+}
+
+// AccessPolicy - 온체인 권한 정책
+type AccessPolicy struct {
+	RoleAttribute   string                `json:"roleAttribute"`   // 등록 인증서의 역할 속성명
+	UserIDAttribute string                `json:"userIdAttribute"` // 등록 인증서의 사용자 ID 속성명
+	Rules           map[string]AccessRule `json:"rules"`
+	UpdatedAt       string                `json:"updatedAt"`
+	UpdatedBy       string                `json:"updatedBy"`
+}
+
+// defaultAccessPolicy - 정책이 저장되지 않은 경우 적용되는 기본 정책
+func defaultAccessPolicy() *AccessPolicy {
+	admin := AccessRule{MSPIDs: []string{"AdminOrgMSP"}, Roles: []string{"admin"}}
+	return &AccessPolicy{
+		RoleAttribute:   common.DefaultRoleAttribute,
+		UserIDAttribute: common.DefaultUserIDAttribute,
+		Rules: map[string]AccessRule{
+			ActionMint:     admin,
+			ActionBurn:     admin,
+			ActionSetPrice: admin,
+			ActionAdmin:    admin,
+			ActionLock:     {MSPIDs: []string{"AdminOrgMSP"}, Roles: []string{"admin", "settlement"}},
+		},
+	}
+}
+
+// GetAccessPolicy - 현재 권한 정책 조회
+func (c *EPCContract) GetAccessPolicy(ctx contractapi.TransactionContextInterface) (*AccessPolicy, error) {
+	return c.getAccessPolicy(ctx)
+}
+
+// SetAccessPolicy - 권한 정책 변경 (관리자 전용)
+func (c *EPCContract) SetAccessPolicy(ctx contractapi.TransactionContextInterface, policyJSON string) error {
+	caller, err := c.requireAction(ctx, ActionAdmin)
+	if err != nil {
+		return err
+	}
+
+	var policy AccessPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return fmt.Errorf("권한 정책 역직렬화 실패: %v", err)
+	}
+	if policy.RoleAttribute == "" || policy.UserIDAttribute == "" {
+		return fmt.Errorf("roleAttribute와 userIdAttribute는 필수입니다")
+	}
+	for _, action := range []string{ActionMint, ActionBurn, ActionSetPrice, ActionLock, ActionAdmin} {
+		rule, ok := policy.Rules[action]
+		if !ok || len(rule.MSPIDs) == 0 {
+			return fmt.Errorf("%s 작업의 허용 MSP가 지정되지 않았습니다", action)
+		}
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	policy.UpdatedAt = now
+	policy.UpdatedBy = caller.ID
+
+	if err := common.PutState(ctx, accessPolicyKey, policy, "권한 정책"); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "AccessPolicyUpdatedEvent", policy)
+}
+
+// WhoAmI - 호출자 신원 조회
+func (c *EPCContract) WhoAmI(ctx contractapi.TransactionContextInterface) (*common.CallerIdentity, error) {
+	policy, err := c.getAccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return getCallerIdentity(ctx, policy)
+}
+
+// ========== 내부 헬퍼 ==========
+
+func (c *EPCContract) getAccessPolicy(ctx contractapi.TransactionContextInterface) (*AccessPolicy, error) {
+	policy, err := common.GetState[AccessPolicy](ctx, accessPolicyKey, "권한 정책")
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return defaultAccessPolicy(), nil
+	}
+
+	return policy, nil
+}
+
+// requireAction - 호출자가 작업 권한을 가졌는지 검사
+func (c *EPCContract) requireAction(ctx contractapi.TransactionContextInterface, action string) (*common.CallerIdentity, error) {
+	policy, err := c.getAccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerIdentity(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if !policy.allows(action, caller) {
+		return nil, fmt.Errorf("권한 없음: %s 작업은 허용되지 않습니다 (MSP: %s, 역할: %q)", action, caller.MSPID, caller.Role)
+	}
+
+	return caller, nil
+}
+
+// requireActionOrChaincode - 호출자가 작업 권한을 가졌거나 트랜잭션이 chaincodeName 체인코드의 제안인지 검사
+//
+// 체인코드 간 호출은 원 제출자 신원으로 실행되므로, 호출한 체인코드가 권한 검사를 대신하는 작업에 사용한다.
+func (c *EPCContract) requireActionOrChaincode(ctx contractapi.TransactionContextInterface, action string, chaincodeName string) error {
+	if proposed, err := common.ProposalChaincode(ctx); err == nil && proposed == chaincodeName {
+		return nil
+	}
+
+	_, err := c.requireAction(ctx, action)
+	return err
+}
+
+// requireOwnerOrAction - 호출자가 userID 본인이거나 작업 권한을 가졌는지 검사
+func (c *EPCContract) requireOwnerOrAction(ctx contractapi.TransactionContextInterface, userID string, action string) (*common.CallerIdentity, error) {
+	policy, err := c.getAccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerIdentity(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if caller.UserID != "" && caller.UserID == userID {
+		return caller, nil
+	}
+	if policy.allows(action, caller) {
+		return caller, nil
+	}
+
+	return nil, fmt.Errorf("권한 없음: %s 의 잔액에 대한 %s 작업은 소유자 또는 승인된 운영자만 가능합니다 (MSP: %s, 역할: %q, 사용자: %q)", userID, action, caller.MSPID, caller.Role, caller.UserID)
+}
+
+// requireOwner - 호출자가 userID 본인인지 검사
+func (c *EPCContract) requireOwner(ctx contractapi.TransactionContextInterface, userID string) (*common.CallerIdentity, error) {
+	caller, err := c.requireCallerUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID != userID {
+		return nil, fmt.Errorf("권한 없음: %s 의 잔액은 소유자만 사용할 수 있습니다 (호출자: %s)", userID, caller.UserID)
+	}
+	return caller, nil
+}
+
+// requireCallerUser - 사용자 ID 속성이 있는 호출자 신원 반환
+func (c *EPCContract) requireCallerUser(ctx contractapi.TransactionContextInterface) (*common.CallerIdentity, error) {
+	policy, err := c.getAccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerIdentity(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID == "" {
+		return nil, fmt.Errorf("호출자 인증서에 %s 속성이 없습니다", policy.UserIDAttribute)
+	}
+
+	return caller, nil
+}
+
+func (p *AccessPolicy) allows(action string, caller *common.CallerIdentity) bool {
+	rule, ok := p.Rules[action]
+	if !ok {
+		return false
+	}
+	if !common.Contains(rule.MSPIDs, caller.MSPID) {
+		return false
+	}
+	return len(rule.Roles) == 0 || common.Contains(rule.Roles, caller.Role)
+}
+
+func getCallerIdentity(ctx contractapi.TransactionContextInterface, policy *AccessPolicy) (*common.CallerIdentity, error) {
+	return common.GetCallerIdentity(ctx, policy.RoleAttribute, policy.UserIDAttribute)
+}
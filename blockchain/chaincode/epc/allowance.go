@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TokenAllowance - 대리 이체 허용량 (ERC-20 allowance)
+//
+// Amount는 EPCDecimals 자릿수의 십진 문자열이다.
+type TokenAllowance struct {
+	OwnerID   string `json:"ownerId"`
+	SpenderID string `json:"spenderId"`
+	Amount    string `json:"amount"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// Approve - spender에게 호출자 잔액의 대리 이체 허용량 설정
+func (c *EPCContract) Approve(ctx contractapi.TransactionContextInterface, spenderID string, amount string) error {
+	caller, err := c.requireCallerUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	value, err := ParseAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	return c.setAllowance(ctx, caller.UserID, spenderID, value)
+}
+
+// IncreaseAllowance - 허용량 증가
+func (c *EPCContract) IncreaseAllowance(ctx contractapi.TransactionContextInterface, spenderID string, addedValue string) error {
+	caller, err := c.requireCallerUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	delta, err := parsePositiveAmount(addedValue, "증가량")
+	if err != nil {
+		return err
+	}
+
+	allowance, err := c.getAllowanceAmount(ctx, caller.UserID, spenderID)
+	if err != nil {
+		return err
+	}
+	if allowance, err = allowance.Add(delta); err != nil {
+		return err
+	}
+
+	return c.setAllowance(ctx, caller.UserID, spenderID, allowance)
+}
+
+// DecreaseAllowance - 허용량 감소
+func (c *EPCContract) DecreaseAllowance(ctx contractapi.TransactionContextInterface, spenderID string, subtractedValue string) error {
+	caller, err := c.requireCallerUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	delta, err := parsePositiveAmount(subtractedValue, "감소량")
+	if err != nil {
+		return err
+	}
+
+	allowance, err := c.getAllowanceAmount(ctx, caller.UserID, spenderID)
+	if err != nil {
+		return err
+	}
+	if allowance < delta {
+		return fmt.Errorf("허용량 부족: 현재 %s, 감소 요청 %s", allowance, delta)
+	}
+	if allowance, err = allowance.Sub(delta); err != nil {
+		return err
+	}
+
+	return c.setAllowance(ctx, caller.UserID, spenderID, allowance)
+}
+
+// Allowance - 허용량 조회
+func (c *EPCContract) Allowance(ctx contractapi.TransactionContextInterface, ownerID string, spenderID string) (*TokenAllowance, error) {
+	return c.getAllowance(ctx, ownerID, spenderID)
+}
+
+// TransferFrom - 허용량 범위 내에서 호출자(spender)가 fromUserID의 EPC를 대리 이체
+func (c *EPCContract) TransferFrom(ctx contractapi.TransactionContextInterface, fromUserID string, toUserID string, amount string, reason string, refID string) error {
+	caller, err := c.requireCallerUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	value, err := parsePositiveAmount(amount, "이체량")
+	if err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowance, err := c.getAllowance(ctx, fromUserID, caller.UserID)
+	if err != nil {
+		return err
+	}
+	remaining, err := ParseAmount(allowance.Amount)
+	if err != nil {
+		return fmt.Errorf("허용량 파싱 실패: %v", err)
+	}
+	if remaining < value {
+		return fmt.Errorf("허용량 부족: 허용 %s, 필요 %s", remaining, value)
+	}
+	if remaining, err = remaining.Sub(value); err != nil {
+		return err
+	}
+
+	allowance.Amount = remaining.String()
+	allowance.UpdatedAt = now
+	if err := c.saveAllowance(ctx, allowance); err != nil {
+		return err
+	}
+
+	// 잠금 잔액 제외 검사 및 TransferEvent는 transfer가 처리한다
+	// (Fabric은 트랜잭션당 이벤트 1개만 허용하므로 ApprovalEvent는 발생하지 않음)
+	return c.transfer(ctx, fromUserID, toUserID, value, reason, refID, caller.UserID)
+}
+
+// ========== 내부 헬퍼 ==========
+
+func allowanceKey(ownerID string, spenderID string) string {
+	return "ALLOW_" + ownerID + "_" + spenderID
+}
+
+func (c *EPCContract) getAllowance(ctx contractapi.TransactionContextInterface, ownerID string, spenderID string) (*TokenAllowance, error) {
+	allowance, err := common.GetState[TokenAllowance](ctx, allowanceKey(ownerID, spenderID), "허용량")
+	if err != nil {
+		return nil, err
+	}
+
+	if allowance == nil {
+		return &TokenAllowance{
+			OwnerID:   ownerID,
+			SpenderID: spenderID,
+			Amount:    Amount(0).String(),
+		}, nil
+	}
+
+	return allowance, nil
+}
+
+func (c *EPCContract) getAllowanceAmount(ctx contractapi.TransactionContextInterface, ownerID string, spenderID string) (Amount, error) {
+	allowance, err := c.getAllowance(ctx, ownerID, spenderID)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := ParseAmount(allowance.Amount)
+	if err != nil {
+		return 0, fmt.Errorf("허용량 파싱 실패: %v", err)
+	}
+
+	return value, nil
+}
+
+func (c *EPCContract) saveAllowance(ctx contractapi.TransactionContextInterface, allowance *TokenAllowance) error {
+	return common.PutState(ctx, allowanceKey(allowance.OwnerID, allowance.SpenderID), allowance, "허용량")
+}
+
+// setAllowance - 허용량 저장 및 ApprovalEvent 발생
+func (c *EPCContract) setAllowance(ctx contractapi.TransactionContextInterface, ownerID string, spenderID string, value Amount) error {
+	if spenderID == "" {
+		return fmt.Errorf("spender ID가 비어 있습니다")
+	}
+	if ownerID == spenderID {
+		return fmt.Errorf("자기 자신에게 허용량을 설정할 수 없습니다")
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowance := &TokenAllowance{
+		OwnerID:   ownerID,
+		SpenderID: spenderID,
+		Amount:    value.String(),
+		UpdatedAt: now,
+	}
+
+	if err := c.saveAllowance(ctx, allowance); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "ApprovalEvent", allowance)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EPCDecimals - EPC 토큰 소수 자릿수 (1 EPC = 10^6 기본 단위)
+const EPCDecimals = 6
+
+// unitsPerToken - 1 EPC 당 기본 단위 수
+const unitsPerToken int64 = 1_000_000
+
+// Amount - EPC 금액 (정수 기본 단위)
+//
+// 모든 잔액/공급량 연산은 float64 대신 Amount로 수행하여 누적 오차를 방지한다.
+// 원장과 컨트랙트 API에는 "12.500000" 형식의 십진 문자열로 기록된다.
+type Amount int64
+
+// ParseAmount - 십진 문자열("12.5")을 기본 단위 금액으로 변환
+func ParseAmount(value string) (Amount, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("금액이 비어 있습니다")
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("잘못된 금액 형식: %s", value)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("잘못된 금액 형식: %s", value)
+	}
+	if len(fracPart) > EPCDecimals {
+		return 0, fmt.Errorf("금액은 소수점 이하 %d자리까지만 허용됩니다: %s", EPCDecimals, value)
+	}
+
+	var whole int64
+	if intPart != "" {
+		parsed, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("금액 범위 초과: %s", value)
+		}
+		whole = parsed
+	}
+
+	var frac int64
+	if fracPart != "" {
+		padded := fracPart + strings.Repeat("0", EPCDecimals-len(fracPart))
+		parsed, err := strconv.ParseInt(padded, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("잘못된 금액 형식: %s", value)
+		}
+		frac = parsed
+	}
+
+	if whole > (math.MaxInt64-frac)/unitsPerToken {
+		return 0, fmt.Errorf("금액 범위 초과: %s", value)
+	}
+
+	return Amount(whole*unitsPerToken + frac), nil
+}
+
+// String - 소수 자릿수를 고정한 십진 문자열 반환
+func (a Amount) String() string {
+	sign := ""
+	units := int64(a)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/unitsPerToken, EPCDecimals, units%unitsPerToken)
+}
+
+// Add - 오버플로 검사를 포함한 덧셈
+func (a Amount) Add(b Amount) (Amount, error) {
+	if b > 0 && a > math.MaxInt64-b {
+		return 0, fmt.Errorf("금액 범위 초과: %s + %s", a, b)
+	}
+	if b < 0 && a < math.MinInt64-b {
+		return 0, fmt.Errorf("금액 범위 초과: %s + %s", a, b)
+	}
+	return a + b, nil
+}
+
+// Sub - 오버플로 검사를 포함한 뺄셈
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b == math.MinInt64 {
+		return 0, fmt.Errorf("금액 범위 초과: %s - %s", a, b)
+	}
+	return a.Add(-b)
+}
+
+// amountFromFloat - 레거시 float64 금액을 기본 단위로 변환 (마이그레이션 전용)
+func amountFromFloat(value float64) (Amount, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("잘못된 레거시 금액: %v", value)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("음수 레거시 금액: %v", value)
+	}
+	return ParseAmount(strconv.FormatFloat(value, 'f', EPCDecimals, 64))
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Amount
+		wantErr bool
+	}{
+		{name: "정수", value: "12", want: 12_000_000},
+		{name: "소수", value: "12.5", want: 12_500_000},
+		{name: "소수 6자리", value: "0.000001", want: 1},
+		{name: "고정 자릿수 문자열", value: "12.500000", want: 12_500_000},
+		{name: "정수부 생략", value: ".5", want: 500_000},
+		{name: "소수부 생략", value: "7.", want: 7_000_000},
+		{name: "앞뒤 공백", value: " 3.25 ", want: 3_250_000},
+		{name: "0", value: "0", want: 0},
+		{name: "최댓값", value: "9223372036854.775807", want: math.MaxInt64},
+		{name: "빈 문자열", value: "", wantErr: true},
+		{name: "점만", value: ".", wantErr: true},
+		{name: "소수 7자리", value: "0.0000001", wantErr: true},
+		{name: "지수 표기", value: "1e-7", wantErr: true},
+		{name: "음수", value: "-1", wantErr: true},
+		{name: "부호", value: "+1", wantErr: true},
+		{name: "천 단위 구분자", value: "1,000", wantErr: true},
+		{name: "소수점 두 개", value: "1.2.3", wantErr: true},
+		{name: "최댓값 초과", value: "9223372036854.775808", wantErr: true},
+		{name: "정수부 범위 초과", value: "9223372036855", wantErr: true},
+		{name: "int64 범위 초과", value: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAmount(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAmount(%q) = %d, 오류를 기대함", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAmount(%q) 오류: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseAmount(%q) = %d, 기대값 %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Amount
+		want    Amount
+		wantErr bool
+	}{
+		{name: "양수", a: 1_500_000, b: 2_500_000, want: 4_000_000},
+		{name: "음수 더하기", a: 1_000_000, b: -400_000, want: 600_000},
+		{name: "최댓값 경계", a: math.MaxInt64 - 1, b: 1, want: math.MaxInt64},
+		{name: "최솟값 경계", a: math.MinInt64 + 1, b: -1, want: math.MinInt64},
+		{name: "양의 오버플로", a: math.MaxInt64, b: 1, wantErr: true},
+		{name: "음의 오버플로", a: math.MinInt64, b: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%d + %d = %d, 오류를 기대함", tt.a, tt.b, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%d + %d 오류: %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Fatalf("%d + %d = %d, 기대값 %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountSub(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Amount
+		want    Amount
+		wantErr bool
+	}{
+		{name: "양수", a: 4_000_000, b: 1_500_000, want: 2_500_000},
+		{name: "음수 결과", a: 1, b: 2, want: -1},
+		{name: "최솟값 경계", a: math.MinInt64 + 1, b: 1, want: math.MinInt64},
+		{name: "음의 오버플로", a: math.MinInt64, b: 1, wantErr: true},
+		{name: "양의 오버플로", a: math.MaxInt64, b: -1, wantErr: true},
+		{name: "최솟값 빼기", a: 0, b: math.MinInt64, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Sub(tt.b)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%d - %d = %d, 오류를 기대함", tt.a, tt.b, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%d - %d 오류: %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Fatalf("%d - %d = %d, 기대값 %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{amount: 0, want: "0.000000"},
+		{amount: 1, want: "0.000001"},
+		{amount: 12_500_000, want: "12.500000"},
+		{amount: 100_000_000, want: "100.000000"},
+		{amount: -1_250_000, want: "-1.250000"},
+		{amount: math.MaxInt64, want: "9223372036854.775807"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, 기대값 %q", int64(tt.amount), got, tt.want)
+		}
+
+		// 음수가 아니면 String 결과를 다시 파싱해 같은 값이 나와야 한다
+		if tt.amount < 0 {
+			continue
+		}
+		parsed, err := ParseAmount(tt.want)
+		if err != nil || parsed != tt.amount {
+			t.Errorf("ParseAmount(%q) = %d, %v, 기대값 %d", tt.want, parsed, err, int64(tt.amount))
+		}
+	}
+}
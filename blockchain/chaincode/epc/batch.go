@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BatchInstruction - 일괄 정산 지시 (settlement 체인코드의 SettleBatch가 생성)
+type BatchInstruction struct {
+	BatchID      string          `json:"batchId"`
+	FeeAccountID string          `json:"feeAccountId,omitempty" metadata:",optional"` // 판매자 수수료 수취 계정
+	Escrows      []BatchEscrow   `json:"escrows"`
+	Transfers    []BatchTransfer `json:"transfers"`
+}
+
+// BatchEscrow - 거래 한 건에 해당하는 에스크로 사용분
+type BatchEscrow struct {
+	RefID         string `json:"refId"`
+	OwnerID       string `json:"ownerId"`
+	BeneficiaryID string `json:"beneficiaryId"`
+	Amount        string `json:"amount"`
+	Fee           string `json:"fee,omitempty" metadata:",optional"` // 수취인(판매자) 부담 수수료
+}
+
+// BatchTransfer - 상계 후 순 이체
+type BatchTransfer struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+// SettleBatch - 상계된 일괄 정산 (정산 운영자 전용)
+//
+// 거래별 에스크로 사용분을 잠금 해제한 뒤 순 이체만 실행한다. 계정별 증감을 먼저 합산하고
+// 계정마다 한 번만 기록하므로, 이체 도중 잔액이 부족해도 최종 잔액이 음수가 아니면 성공한다.
+// 순 이체는 검증된 에스크로 사용분(소유자 → 수취인 쌍별 상계, 수취인별 수수료 → FeeAccountID)으로
+// 다시 계산하며, 지시에 담긴 Transfers가 이와 다르면 거부한다.
+func (c *EPCContract) SettleBatch(ctx contractapi.TransactionContextInterface, instructionJSON string) error {
+	if _, err := c.requireAction(ctx, ActionLock); err != nil {
+		return err
+	}
+
+	var instruction BatchInstruction
+	if err := json.Unmarshal([]byte(instructionJSON), &instruction); err != nil {
+		return fmt.Errorf("일괄 정산 지시 역직렬화 실패: %v", err)
+	}
+	if instruction.BatchID == "" {
+		return fmt.Errorf("batchId는 필수입니다")
+	}
+	if len(instruction.Escrows) == 0 {
+		return fmt.Errorf("일괄 정산 지시가 비어 있습니다: %s", instruction.BatchID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	totalDelta := map[string]Amount{}
+	lockedDelta := map[string]Amount{}
+
+	// 같은 트랜잭션에서 쓴 값은 다시 읽을 수 없으므로 refID별로 합산해 한 번만 차감한다
+	escrowUse := map[string]Amount{}
+	escrows := map[string]*Escrow{}
+	pairGross := map[string]Amount{} // 소유자\x00수취인 → 사용액
+	fees := map[string]Amount{}
+	for _, entry := range instruction.Escrows {
+		value, err := parsePositiveAmount(entry.Amount, "에스크로 사용액")
+		if err != nil {
+			return err
+		}
+		var fee Amount
+		if entry.Fee != "" {
+			if fee, err = ParseAmount(entry.Fee); err != nil {
+				return fmt.Errorf("잘못된 수수료: %v", err)
+			}
+		}
+		if fee > value {
+			return fmt.Errorf("수수료가 에스크로 사용액을 초과합니다: %s (사용 %s, 수수료 %s)", entry.RefID, value, fee)
+		}
+		if entry.BeneficiaryID == "" || entry.BeneficiaryID == entry.OwnerID {
+			return fmt.Errorf("잘못된 에스크로 수취인: %s → %q", entry.RefID, entry.BeneficiaryID)
+		}
+
+		escrow, ok := escrows[entry.RefID]
+		if !ok {
+			if escrow, err = c.getReleasableEscrow(ctx, entry.RefID, entry.BeneficiaryID, now); err != nil {
+				return err
+			}
+			escrows[entry.RefID] = escrow
+		} else if escrow.BeneficiaryID != "" && escrow.BeneficiaryID != entry.BeneficiaryID {
+			return fmt.Errorf("에스크로 수취인 불일치: 지정 %s, 요청 %s", escrow.BeneficiaryID, entry.BeneficiaryID)
+		}
+		if escrow.OwnerID != entry.OwnerID {
+			return fmt.Errorf("에스크로 소유자 불일치: %s 의 소유자는 %s 입니다", entry.RefID, escrow.OwnerID)
+		}
+
+		if escrowUse[entry.RefID], err = escrowUse[entry.RefID].Add(value); err != nil {
+			return err
+		}
+		if lockedDelta[entry.OwnerID], err = lockedDelta[entry.OwnerID].Sub(value); err != nil {
+			return err
+		}
+		pairKey := entry.OwnerID + "\x00" + entry.BeneficiaryID
+		if pairGross[pairKey], err = pairGross[pairKey].Add(value); err != nil {
+			return err
+		}
+		if fees[entry.BeneficiaryID], err = fees[entry.BeneficiaryID].Add(fee); err != nil {
+			return err
+		}
+	}
+
+	expected, err := netBatchTransfers(pairGross, fees, instruction.FeeAccountID)
+	if err != nil {
+		return err
+	}
+	supplied := map[string]Amount{}
+	for _, transfer := range instruction.Transfers {
+		value, err := parsePositiveAmount(transfer.Amount, "이체액")
+		if err != nil {
+			return err
+		}
+		if transfer.From == "" || transfer.To == "" || transfer.From == transfer.To {
+			return fmt.Errorf("잘못된 이체: %q → %q", transfer.From, transfer.To)
+		}
+		key := transfer.From + "\x00" + transfer.To
+		if supplied[key], err = supplied[key].Add(value); err != nil {
+			return err
+		}
+	}
+	if len(supplied) != len(expected) {
+		return fmt.Errorf("이체 목록이 에스크로 사용분과 일치하지 않습니다: 이체 %d건, 예상 %d건", len(supplied), len(expected))
+	}
+	for _, key := range sortedKeys(expected) {
+		value := expected[key]
+		if supplied[key] != value {
+			from, to, _ := strings.Cut(key, "\x00")
+			return fmt.Errorf("이체 목록이 에스크로 사용분과 일치하지 않습니다: %s → %s 예상 %s, 지시 %s", from, to, value, supplied[key])
+		}
+	}
+
+	var transferred Amount
+	for _, key := range sortedKeys(expected) {
+		from, to, _ := strings.Cut(key, "\x00")
+		value := expected[key]
+		if totalDelta[from], err = totalDelta[from].Sub(value); err != nil {
+			return err
+		}
+		if totalDelta[to], err = totalDelta[to].Add(value); err != nil {
+			return err
+		}
+		if transferred, err = transferred.Add(value); err != nil {
+			return err
+		}
+	}
+
+	for _, refID := range sortedKeys(escrowUse) {
+		if err := c.consumeEscrow(ctx, escrows[refID], escrowUse[refID], EscrowReleased, now); err != nil {
+			return err
+		}
+	}
+
+	accounts := map[string]Amount{}
+	for userID := range totalDelta {
+		accounts[userID] = 0
+	}
+	for userID := range lockedDelta {
+		accounts[userID] = 0
+	}
+	for _, userID := range sortedKeys(accounts) {
+		if err := c.applyBatchDelta(ctx, userID, totalDelta[userID], lockedDelta[userID], now); err != nil {
+			return err
+		}
+	}
+
+	tx := TokenTransaction{
+		TxID:      ctx.GetStub().GetTxID(),
+		Type:      "SETTLE_BATCH",
+		Amount:    transferred.String(),
+		Reason:    "batch_settlement",
+		RefID:     instruction.BatchID,
+		CreatedAt: now,
+	}
+
+	return c.recordTransaction(ctx, tx, "BatchSettlementEvent")
+}
+
+// applyBatchDelta - 계정 잔액/잠금 잔액에 일괄 정산 증감 반영
+func (c *EPCContract) applyBatchDelta(ctx contractapi.TransactionContextInterface, userID string, total Amount, locked Amount, now string) error {
+	balance, err := c.getOrCreateBalance(ctx, userID)
+	if err != nil {
+		return err
+	}
+	currentTotal, currentLocked, err := balanceAmounts(balance)
+	if err != nil {
+		return err
+	}
+
+	if currentTotal, err = currentTotal.Add(total); err != nil {
+		return err
+	}
+	if currentLocked, err = currentLocked.Add(locked); err != nil {
+		return err
+	}
+	if currentLocked < 0 {
+		return fmt.Errorf("잠금 잔액 부족: %s", userID)
+	}
+	if currentTotal < currentLocked {
+		return fmt.Errorf("잔액 부족: %s 정산 후 잔액 %s, 잠금 %s", userID, currentTotal, currentLocked)
+	}
+
+	balance.Balance = currentTotal.String()
+	balance.LockedBalance = currentLocked.String()
+	balance.UpdatedAt = now
+
+	return c.saveBalance(ctx, balance)
+}
+
+// netBatchTransfers - 쌍별 에스크로 사용액을 상계한 순 이체와 수취인별 수수료 이체 (키: from\x00to)
+//
+// 수수료 수취 계정이 수취인이면 그 수수료는 이체하지 않는다.
+func netBatchTransfers(pairGross map[string]Amount, fees map[string]Amount, feeAccountID string) (map[string]Amount, error) {
+	transfers := map[string]Amount{}
+	for _, key := range sortedKeys(pairGross) {
+		ownerID, beneficiaryID, _ := strings.Cut(key, "\x00")
+		reverse := beneficiaryID + "\x00" + ownerID
+		if _, both := pairGross[reverse]; both && ownerID > beneficiaryID {
+			continue // 반대 방향 키에서 함께 상계
+		}
+		net, err := pairGross[key].Sub(pairGross[reverse])
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case net > 0:
+			transfers[key] = net
+		case net < 0:
+			transfers[reverse] = -net
+		}
+	}
+
+	for _, beneficiaryID := range sortedKeys(fees) {
+		fee := fees[beneficiaryID]
+		if fee == 0 || beneficiaryID == feeAccountID {
+			continue
+		}
+		if feeAccountID == "" {
+			return nil, fmt.Errorf("수수료 수취 계정(feeAccountId)은 필수입니다")
+		}
+		key := beneficiaryID + "\x00" + feeAccountID
+		value, err := transfers[key].Add(fee)
+		if err != nil {
+			return nil, err
+		}
+		transfers[key] = value
+	}
+
+	return transfers, nil
+}
+
+func sortedKeys(m map[string]Amount) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
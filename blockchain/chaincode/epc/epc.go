@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
+	"strings"
 
+	"github.com/etp/chaincode/common"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -15,23 +16,30 @@ type EPCContract struct {
 }
 
 // TokenBalance - 사용자 토큰 잔액
+//
+// Balance, LockedBalance는 EPCDecimals 자릿수의 십진 문자열이다.
 type TokenBalance struct {
-	UserID        string  `json:"userId"`
-	Balance       float64 `json:"balance"`
-	LockedBalance float64 `json:"lockedBalance"`
-	UpdatedAt     string  `json:"updatedAt"`
+	UserID        string `json:"userId"`
+	Balance       string `json:"balance"`
+	LockedBalance string `json:"lockedBalance"`
+	UpdatedAt     string `json:"updatedAt"`
 }
 
 // TokenTransaction - 토큰 거래 기록
 type TokenTransaction struct {
-	TxID      string  `json:"txId"`
-	Type      string  `json:"type"` // MINT, BURN, TRANSFER, LOCK, UNLOCK
-	From      string  `json:"from"`
-	To        string  `json:"to"`
-	Amount    float64 `json:"amount"`
-	Reason    string  `json:"reason"`
-	RefID     string  `json:"refId"`
-	CreatedAt string  `json:"createdAt"`
+	TxID       string `json:"txId"`
+	Type       string `json:"type"` // MINT, BURN, TRANSFER, LOCK, UNLOCK, RELEASE, SETTLE, SETTLE_BATCH
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Amount     string `json:"amount"`
+	Spender    string `json:"spender,omitempty" metadata:",optional"`    // TransferFrom 대리 이체자
+	Fee        string `json:"fee,omitempty" metadata:",optional"`        // SETTLE 수수료
+	FeeAccount string `json:"feeAccount,omitempty" metadata:",optional"` // SETTLE 수수료 수취 계정
+	// 발전 보상 MINT의 근거 측정값 (metering 체인코드 recordID)
+	MeterRecordIDs []string `json:"meterRecordIds,omitempty" metadata:",optional"`
+	Reason         string   `json:"reason"`
+	RefID          string   `json:"refId"`
+	CreatedAt      string   `json:"createdAt"`
 }
 
 // PriceRecord - 전력 가격 기록 (오라클 데이터)
@@ -45,35 +53,69 @@ type PriceRecord struct {
 	RecordedAt  string  `json:"recordedAt"`
 }
 
+// PriceRecordPage - 가격 기록 페이지 조회 결과
+type PriceRecordPage struct {
+	Records      []PriceRecord `json:"records"`
+	Bookmark     string        `json:"bookmark"`
+	FetchedCount int32         `json:"fetchedCount"`
+}
+
 // TokenSupply - 전체 공급량 메타데이터
+//
+// TotalSupply, TotalMinted, TotalBurned는 EPCDecimals 자릿수의 십진 문자열이다.
 type TokenSupply struct {
-	TotalSupply  float64 `json:"totalSupply"`
-	TotalMinted  float64 `json:"totalMinted"`
-	TotalBurned  float64 `json:"totalBurned"`
+	TotalSupply  string  `json:"totalSupply"`
+	TotalMinted  string  `json:"totalMinted"`
+	TotalBurned  string  `json:"totalBurned"`
 	CurrentPrice float64 `json:"currentPrice"`
 	UpdatedAt    string  `json:"updatedAt"`
 }
 
-// InitLedger - 토큰 원장 초기화
+// MigrationResult - 레거시 금액 마이그레이션 결과
+type MigrationResult struct {
+	MigratedBalances int  `json:"migratedBalances"`
+	SkippedBalances  int  `json:"skippedBalances"`
+	SupplyMigrated   bool `json:"supplyMigrated"`
+}
+
+// InitLedger - 토큰 원장 초기화 (관리자 전용)
+//
+// 공급량 상태가 이미 있으면 아무것도 하지 않아 발행·소각 기록을 되돌릴 수 없다.
 func (c *EPCContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	supply := TokenSupply{
-		TotalSupply:  0,
-		TotalMinted:  0,
-		TotalBurned:  0,
-		CurrentPrice: 0,
-		UpdatedAt:    time.Now().UTC().Format(time.RFC3339),
+	if _, err := c.requireAction(ctx, ActionAdmin); err != nil {
+		return err
+	}
+
+	exists, err := common.Exists(ctx, "EPC_SUPPLY")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
 	}
 
-	supplyJSON, err := json.Marshal(supply)
+	now, err := common.TxTimestamp(ctx)
 	if err != nil {
-		return fmt.Errorf("EPC 공급량 직렬화 실패: %v", err)
+		return err
 	}
 
-	return ctx.GetStub().PutState("EPC_SUPPLY", supplyJSON)
+	supply := TokenSupply{
+		TotalSupply:  Amount(0).String(),
+		TotalMinted:  Amount(0).String(),
+		TotalBurned:  Amount(0).String(),
+		CurrentPrice: 0,
+		UpdatedAt:    now,
+	}
+
+	return c.saveSupply(ctx, &supply)
 }
 
 // SetPrice - 글로벌 전력 가격 업데이트 (관리자 전용)
 func (c *EPCContract) SetPrice(ctx contractapi.TransactionContextInterface, priceID string, source string, price float64, currency string, basketPrice float64, timestamp string) error {
+	if _, err := c.requireAction(ctx, ActionSetPrice); err != nil {
+		return err
+	}
+
 	if price <= 0 {
 		return fmt.Errorf("가격은 0보다 커야 합니다")
 	}
@@ -81,6 +123,11 @@ func (c *EPCContract) SetPrice(ctx contractapi.TransactionContextInterface, pric
 		return fmt.Errorf("바스켓 가격은 0보다 커야 합니다")
 	}
 
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	record := PriceRecord{
 		PriceID:     priceID,
 		Source:      source,
@@ -88,22 +135,17 @@ func (c *EPCContract) SetPrice(ctx contractapi.TransactionContextInterface, pric
 		Currency:    currency,
 		BasketPrice: basketPrice,
 		Timestamp:   timestamp,
-		RecordedAt:  time.Now().UTC().Format(time.RFC3339),
-	}
-
-	recordJSON, err := json.Marshal(record)
-	if err != nil {
-		return fmt.Errorf("가격 기록 직렬화 실패: %v", err)
+		RecordedAt:  now,
 	}
 
 	// 가격 기록 저장
-	if err := ctx.GetStub().PutState("PRICE_"+priceID, recordJSON); err != nil {
-		return fmt.Errorf("가격 기록 저장 실패: %v", err)
+	if err := common.PutState(ctx, "PRICE_"+priceID, record, "가격 기록"); err != nil {
+		return err
 	}
 
 	// 최신 가격 포인터 업데이트
-	if err := ctx.GetStub().PutState("EPC_LATEST_PRICE", recordJSON); err != nil {
-		return fmt.Errorf("최신 가격 업데이트 실패: %v", err)
+	if err := common.PutState(ctx, "EPC_LATEST_PRICE", record, "최신 가격"); err != nil {
+		return err
 	}
 
 	// 공급량 메타데이터의 현재 가격 업데이트
@@ -112,138 +154,112 @@ func (c *EPCContract) SetPrice(ctx contractapi.TransactionContextInterface, pric
 		return err
 	}
 	supply.CurrentPrice = basketPrice
-	supply.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	supply.UpdatedAt = now
 
-	supplyJSON, err := json.Marshal(supply)
-	if err != nil {
-		return fmt.Errorf("공급량 직렬화 실패: %v", err)
-	}
-
-	return ctx.GetStub().PutState("EPC_SUPPLY", supplyJSON)
+	return c.saveSupply(ctx, supply)
 }
 
 // GetPrice - 최신 가격 조회
 func (c *EPCContract) GetPrice(ctx contractapi.TransactionContextInterface) (*PriceRecord, error) {
-	recordJSON, err := ctx.GetStub().GetState("EPC_LATEST_PRICE")
+	record, err := common.GetState[PriceRecord](ctx, "EPC_LATEST_PRICE", "가격")
 	if err != nil {
-		return nil, fmt.Errorf("가격 조회 실패: %v", err)
+		return nil, err
 	}
-	if recordJSON == nil {
+	if record == nil {
 		return nil, fmt.Errorf("가격 데이터가 없습니다")
 	}
 
-	var record PriceRecord
-	if err := json.Unmarshal(recordJSON, &record); err != nil {
-		return nil, fmt.Errorf("가격 역직렬화 실패: %v", err)
-	}
-
-	return &record, nil
+	return record, nil
 }
 
 // GetPriceHistory - 가격 이력 조회
 func (c *EPCContract) GetPriceHistory(ctx contractapi.TransactionContextInterface, priceID string) ([]PriceRecord, error) {
-	historyIter, err := ctx.GetStub().GetHistoryForKey("PRICE_" + priceID)
-	if err != nil {
-		return nil, fmt.Errorf("가격 이력 조회 실패: %v", err)
-	}
-	defer historyIter.Close()
-
-	var records []PriceRecord
-	for historyIter.HasNext() {
-		result, err := historyIter.Next()
-		if err != nil {
-			return nil, fmt.Errorf("이력 순회 실패: %v", err)
-		}
+	return common.GetHistory[PriceRecord](ctx, "PRICE_"+priceID, "가격")
+}
 
-		var record PriceRecord
-		if err := json.Unmarshal(result.Value, &record); err != nil {
-			continue
-		}
-		records = append(records, record)
+// GetPriceHistoryWithPagination - 가격 이력 조회 (북마크 기반 페이지 조회)
+func (c *EPCContract) GetPriceHistoryWithPagination(ctx contractapi.TransactionContextInterface, priceID string, pageSize int32, bookmark string) (*PriceRecordPage, error) {
+	page, err := common.GetHistoryPage[PriceRecord](ctx, "PRICE_"+priceID, pageSize, bookmark, "가격")
+	if err != nil {
+		return nil, err
 	}
 
-	return records, nil
+	return &PriceRecordPage{Records: page.Records, Bookmark: page.Bookmark, FetchedCount: page.FetchedCount}, nil
 }
 
 // Mint - EPC 토큰 발행
-func (c *EPCContract) Mint(ctx contractapi.TransactionContextInterface, userID string, amount float64, reason string, refID string) error {
-	if amount <= 0 {
-		return fmt.Errorf("발행량은 0보다 커야 합니다")
-	}
-
-	// 사용자 잔액 업데이트
-	balance, err := c.getOrCreateBalance(ctx, userID)
-	if err != nil {
+func (c *EPCContract) Mint(ctx contractapi.TransactionContextInterface, userID string, amount string, reason string, refID string) error {
+	if _, err := c.requireAction(ctx, ActionMint); err != nil {
 		return err
 	}
-	balance.Balance += amount
-	balance.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 
-	if err := c.saveBalance(ctx, balance); err != nil {
+	value, err := parsePositiveAmount(amount, "발행량")
+	if err != nil {
 		return err
 	}
 
-	// 공급량 업데이트
-	supply, err := c.getSupply(ctx)
+	now, err := common.TxTimestamp(ctx)
 	if err != nil {
 		return err
 	}
-	supply.TotalSupply += amount
-	supply.TotalMinted += amount
-	supply.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 
-	supplyJSON, err := json.Marshal(supply)
-	if err != nil {
-		return fmt.Errorf("공급량 직렬화 실패: %v", err)
-	}
-	if err := ctx.GetStub().PutState("EPC_SUPPLY", supplyJSON); err != nil {
-		return fmt.Errorf("공급량 저장 실패: %v", err)
+	if err := c.mint(ctx, userID, value, now); err != nil {
+		return err
 	}
 
 	// 거래 기록
-	txID := ctx.GetStub().GetTxID()
 	tx := TokenTransaction{
-		TxID:      txID,
+		TxID:      ctx.GetStub().GetTxID(),
 		Type:      "MINT",
 		From:      "",
 		To:        userID,
-		Amount:    amount,
+		Amount:    value.String(),
 		Reason:    reason,
 		RefID:     refID,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
-	}
-
-	txJSON, err := json.Marshal(tx)
-	if err != nil {
-		return fmt.Errorf("거래 기록 직렬화 실패: %v", err)
-	}
-	if err := ctx.GetStub().PutState("TX_"+txID, txJSON); err != nil {
-		return fmt.Errorf("거래 기록 저장 실패: %v", err)
+		CreatedAt: now,
 	}
 
-	// 이벤트 발생
-	ctx.GetStub().SetEvent("MintEvent", txJSON)
-
-	return nil
+	return c.recordTransaction(ctx, tx, "MintEvent")
 }
 
 // Burn - EPC 토큰 소각
-func (c *EPCContract) Burn(ctx contractapi.TransactionContextInterface, userID string, amount float64, reason string, refID string) error {
-	if amount <= 0 {
-		return fmt.Errorf("소각량은 0보다 커야 합니다")
+func (c *EPCContract) Burn(ctx contractapi.TransactionContextInterface, userID string, amount string, reason string, refID string) error {
+	if _, err := c.requireAction(ctx, ActionBurn); err != nil {
+		return err
+	}
+
+	value, err := parsePositiveAmount(amount, "소각량")
+	if err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
 	}
 
 	balance, err := c.getOrCreateBalance(ctx, userID)
 	if err != nil {
 		return err
 	}
+	total, locked, err := balanceAmounts(balance)
+	if err != nil {
+		return err
+	}
 
-	if balance.Balance < amount {
-		return fmt.Errorf("잔액 부족: 현재 %.2f, 필요 %.2f", balance.Balance, amount)
+	available, err := total.Sub(locked)
+	if err != nil {
+		return err
+	}
+	if available < value {
+		return fmt.Errorf("가용 잔액 부족: 가용 %s, 필요 %s", available, value)
 	}
 
-	balance.Balance -= amount
-	balance.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if total, err = total.Sub(value); err != nil {
+		return err
+	}
+	balance.Balance = total.String()
+	balance.UpdatedAt = now
 
 	if err := c.saveBalance(ctx, balance); err != nil {
 		return err
@@ -254,189 +270,167 @@ func (c *EPCContract) Burn(ctx contractapi.TransactionContextInterface, userID s
 	if err != nil {
 		return err
 	}
-	supply.TotalSupply -= amount
-	supply.TotalBurned += amount
-	supply.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-
-	supplyJSON, err := json.Marshal(supply)
+	totalSupply, _, totalBurned, err := supplyAmounts(supply)
 	if err != nil {
-		return fmt.Errorf("공급량 직렬화 실패: %v", err)
+		return err
 	}
-	if err := ctx.GetStub().PutState("EPC_SUPPLY", supplyJSON); err != nil {
-		return fmt.Errorf("공급량 저장 실패: %v", err)
+	if totalSupply, err = totalSupply.Sub(value); err != nil {
+		return err
+	}
+	if totalBurned, err = totalBurned.Add(value); err != nil {
+		return err
+	}
+	supply.TotalSupply = totalSupply.String()
+	supply.TotalBurned = totalBurned.String()
+	supply.UpdatedAt = now
+
+	if err := c.saveSupply(ctx, supply); err != nil {
+		return err
 	}
 
 	// 거래 기록
-	txID := ctx.GetStub().GetTxID()
 	tx := TokenTransaction{
-		TxID:      txID,
+		TxID:      ctx.GetStub().GetTxID(),
 		Type:      "BURN",
 		From:      userID,
 		To:        "",
-		Amount:    amount,
+		Amount:    value.String(),
 		Reason:    reason,
 		RefID:     refID,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		CreatedAt: now,
 	}
 
-	txJSON, err := json.Marshal(tx)
-	if err != nil {
-		return fmt.Errorf("거래 기록 직렬화 실패: %v", err)
-	}
-	if err := ctx.GetStub().PutState("TX_"+txID, txJSON); err != nil {
-		return fmt.Errorf("거래 기록 저장 실패: %v", err)
+	return c.recordTransaction(ctx, tx, "BurnEvent")
+}
+
+// Transfer - EPC 토큰 이체 (보유자 본인 전용, 대리 이체는 TransferFrom 사용)
+func (c *EPCContract) Transfer(ctx contractapi.TransactionContextInterface, fromUserID string, toUserID string, amount string, reason string, refID string) error {
+	if _, err := c.requireOwner(ctx, fromUserID); err != nil {
+		return err
 	}
 
-	ctx.GetStub().SetEvent("BurnEvent", txJSON)
+	value, err := parsePositiveAmount(amount, "이체량")
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return c.transfer(ctx, fromUserID, toUserID, value, reason, refID, "")
 }
 
-// Transfer - EPC 토큰 이체
-func (c *EPCContract) Transfer(ctx contractapi.TransactionContextInterface, fromUserID string, toUserID string, amount float64, reason string, refID string) error {
-	if amount <= 0 {
-		return fmt.Errorf("이체량은 0보다 커야 합니다")
+// Lock - 거래 대기 잠금 (refID별 에스크로 생성)
+//
+// beneficiaryID가 비어 있으면 지급 대상을 제한하지 않는다. expiresAt(RFC3339) 이후에는
+// 지급이 거부되며 소유자가 ReclaimExpired로 잠금을 회수할 수 있다.
+func (c *EPCContract) Lock(ctx contractapi.TransactionContextInterface, userID string, amount string, refID string, beneficiaryID string, expiresAt string) error {
+	if _, err := c.requireOwnerOrAction(ctx, userID, ActionLock); err != nil {
+		return err
 	}
-	if fromUserID == toUserID {
-		return fmt.Errorf("자기 자신에게 이체할 수 없습니다")
+	if strings.HasPrefix(refID, legacyEscrowPrefix) {
+		return fmt.Errorf("%s 접두사는 레거시 잠금 이관용 refID입니다: %s", legacyEscrowPrefix, refID)
 	}
 
-	fromBalance, err := c.getOrCreateBalance(ctx, fromUserID)
+	value, err := parsePositiveAmount(amount, "잠금량")
 	if err != nil {
 		return err
 	}
 
-	availableBalance := fromBalance.Balance - fromBalance.LockedBalance
-	if availableBalance < amount {
-		return fmt.Errorf("가용 잔액 부족: 가용 %.2f, 필요 %.2f", availableBalance, amount)
-	}
-
-	toBalance, err := c.getOrCreateBalance(ctx, toUserID)
+	now, err := common.TxTimestamp(ctx)
 	if err != nil {
 		return err
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	fromBalance.Balance -= amount
-	fromBalance.UpdatedAt = now
-	toBalance.Balance += amount
-	toBalance.UpdatedAt = now
-
-	if err := c.saveBalance(ctx, fromBalance); err != nil {
+	balance, err := c.getOrCreateBalance(ctx, userID)
+	if err != nil {
 		return err
 	}
-	if err := c.saveBalance(ctx, toBalance); err != nil {
+	total, locked, err := balanceAmounts(balance)
+	if err != nil {
 		return err
 	}
 
-	// 거래 기록
-	txID := ctx.GetStub().GetTxID()
-	tx := TokenTransaction{
-		TxID:      txID,
-		Type:      "TRANSFER",
-		From:      fromUserID,
-		To:        toUserID,
-		Amount:    amount,
-		Reason:    reason,
-		RefID:     refID,
-		CreatedAt: now,
-	}
-
-	txJSON, err := json.Marshal(tx)
+	availableBalance, err := total.Sub(locked)
 	if err != nil {
-		return fmt.Errorf("거래 기록 직렬화 실패: %v", err)
-	}
-	if err := ctx.GetStub().PutState("TX_"+txID, txJSON); err != nil {
-		return fmt.Errorf("거래 기록 저장 실패: %v", err)
+		return err
 	}
-
-	ctx.GetStub().SetEvent("TransferEvent", txJSON)
-
-	return nil
-}
-
-// Lock - 거래 대기 잠금
-func (c *EPCContract) Lock(ctx contractapi.TransactionContextInterface, userID string, amount float64, refID string) error {
-	if amount <= 0 {
-		return fmt.Errorf("잠금량은 0보다 커야 합니다")
+	if availableBalance < value {
+		return fmt.Errorf("가용 잔액 부족: 가용 %s, 필요 %s", availableBalance, value)
 	}
 
-	balance, err := c.getOrCreateBalance(ctx, userID)
-	if err != nil {
+	if err := c.createEscrow(ctx, refID, userID, beneficiaryID, value, expiresAt, now); err != nil {
 		return err
 	}
 
-	availableBalance := balance.Balance - balance.LockedBalance
-	if availableBalance < amount {
-		return fmt.Errorf("가용 잔액 부족: 가용 %.2f, 필요 %.2f", availableBalance, amount)
+	if locked, err = locked.Add(value); err != nil {
+		return err
 	}
-
-	balance.LockedBalance += amount
-	balance.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	balance.LockedBalance = locked.String()
+	balance.UpdatedAt = now
 
 	if err := c.saveBalance(ctx, balance); err != nil {
 		return err
 	}
 
 	// 거래 기록
-	txID := ctx.GetStub().GetTxID()
 	tx := TokenTransaction{
-		TxID:      txID,
+		TxID:      ctx.GetStub().GetTxID(),
 		Type:      "LOCK",
 		From:      userID,
 		To:        "",
-		Amount:    amount,
+		Amount:    value.String(),
 		Reason:    "trade_lock",
 		RefID:     refID,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		CreatedAt: now,
 	}
 
-	txJSON, _ := json.Marshal(tx)
-	ctx.GetStub().PutState("TX_"+txID, txJSON)
-	ctx.GetStub().SetEvent("LockEvent", txJSON)
-
-	return nil
+	return c.recordTransaction(ctx, tx, "LockEvent")
 }
 
-// Unlock - 잠금 해제
-func (c *EPCContract) Unlock(ctx contractapi.TransactionContextInterface, userID string, amount float64, refID string) error {
-	if amount <= 0 {
-		return fmt.Errorf("해제량은 0보다 커야 합니다")
+// Unlock - 에스크로 잠금 해제 (정산 운영자 전용: 소유자가 거래 대기 자금을 임의로 풀 수 없도록 한다)
+//
+// trading 체인코드 트랜잭션 안에서의 호출(주문 취소·만료 시 매수 주문 에스크로의 미체결분 해제)은
+// 제출자와 무관하게 허용한다. 해제 대상과 금액은 trading 체인코드가 주문 상태로 검증한다.
+func (c *EPCContract) Unlock(ctx contractapi.TransactionContextInterface, userID string, amount string, refID string) error {
+	if err := c.requireActionOrChaincode(ctx, ActionLock, tradingChaincode); err != nil {
+		return err
 	}
 
-	balance, err := c.getOrCreateBalance(ctx, userID)
+	value, err := parsePositiveAmount(amount, "해제량")
 	if err != nil {
 		return err
 	}
 
-	if balance.LockedBalance < amount {
-		return fmt.Errorf("잠금 잔액 부족: 현재 잠금 %.2f, 해제 요청 %.2f", balance.LockedBalance, amount)
+	escrow, err := c.getOpenEscrow(ctx, refID)
+	if err != nil {
+		return err
+	}
+	if escrow.OwnerID != userID {
+		return fmt.Errorf("에스크로 소유자 불일치: %s 의 소유자는 %s 입니다", refID, escrow.OwnerID)
 	}
 
-	balance.LockedBalance -= amount
-	balance.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
 
-	if err := c.saveBalance(ctx, balance); err != nil {
+	if err := c.debitLocked(ctx, userID, value, false, now); err != nil {
+		return err
+	}
+	if err := c.consumeEscrow(ctx, escrow, value, EscrowUnlocked, now); err != nil {
 		return err
 	}
 
-	txID := ctx.GetStub().GetTxID()
 	tx := TokenTransaction{
-		TxID:      txID,
+		TxID:      ctx.GetStub().GetTxID(),
 		Type:      "UNLOCK",
 		From:      "",
 		To:        userID,
-		Amount:    amount,
+		Amount:    value.String(),
 		Reason:    "trade_unlock",
 		RefID:     refID,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		CreatedAt: now,
 	}
 
-	txJSON, _ := json.Marshal(tx)
-	ctx.GetStub().PutState("TX_"+txID, txJSON)
-	ctx.GetStub().SetEvent("UnlockEvent", txJSON)
-
-	return nil
+	return c.recordTransaction(ctx, tx, "UnlockEvent")
 }
 
 // BalanceOf - 사용자 잔액 조회
@@ -449,62 +443,345 @@ func (c *EPCContract) TotalSupply(ctx contractapi.TransactionContextInterface) (
 	return c.getSupply(ctx)
 }
 
+// Decimals - EPC 토큰 소수 자릿수 조회
+func (c *EPCContract) Decimals(ctx contractapi.TransactionContextInterface) int {
+	return EPCDecimals
+}
+
+// MigrateLegacyAmounts - float64로 기록된 레거시 BAL_* / EPC_SUPPLY 상태를 고정 소수점 문자열로 변환 (관리자 전용)
+//
+// 이미 변환된 레코드는 건너뛰므로 재실행해도 안전하다.
+func (c *EPCContract) MigrateLegacyAmounts(ctx contractapi.TransactionContextInterface) (*MigrationResult, error) {
+	if _, err := c.requireAction(ctx, ActionAdmin); err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{}
+
+	startKey, endKey := common.PrefixRange("BAL_")
+	err := common.ForEachInRange(ctx, startKey, endKey, func(key string, value []byte) error {
+		var probe struct {
+			Balance json.RawMessage `json:"balance"`
+		}
+		if err := json.Unmarshal(value, &probe); err != nil {
+			return fmt.Errorf("잔액 역직렬화 실패 (%s): %v", key, err)
+		}
+		if !isLegacyAmount(probe.Balance) {
+			result.SkippedBalances++
+			return nil
+		}
+
+		var legacy legacyTokenBalance
+		if err := json.Unmarshal(value, &legacy); err != nil {
+			return fmt.Errorf("레거시 잔액 역직렬화 실패 (%s): %v", key, err)
+		}
+		total, err := amountFromFloat(legacy.Balance)
+		if err != nil {
+			return fmt.Errorf("레거시 잔액 변환 실패 (%s): %v", key, err)
+		}
+		locked, err := amountFromFloat(legacy.LockedBalance)
+		if err != nil {
+			return fmt.Errorf("레거시 잠금 잔액 변환 실패 (%s): %v", key, err)
+		}
+
+		balance := &TokenBalance{
+			UserID:        legacy.UserID,
+			Balance:       total.String(),
+			LockedBalance: locked.String(),
+			UpdatedAt:     legacy.UpdatedAt,
+		}
+		if err := c.saveBalance(ctx, balance); err != nil {
+			return err
+		}
+		result.MigratedBalances++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	supplyJSON, err := ctx.GetStub().GetState("EPC_SUPPLY")
+	if err != nil {
+		return nil, fmt.Errorf("공급량 조회 실패: %v", err)
+	}
+	if supplyJSON != nil {
+		var probe struct {
+			TotalSupply json.RawMessage `json:"totalSupply"`
+		}
+		if err := json.Unmarshal(supplyJSON, &probe); err != nil {
+			return nil, fmt.Errorf("공급량 역직렬화 실패: %v", err)
+		}
+
+		if isLegacyAmount(probe.TotalSupply) {
+			var legacy legacyTokenSupply
+			if err := json.Unmarshal(supplyJSON, &legacy); err != nil {
+				return nil, fmt.Errorf("레거시 공급량 역직렬화 실패: %v", err)
+			}
+
+			supply := &TokenSupply{CurrentPrice: legacy.CurrentPrice, UpdatedAt: legacy.UpdatedAt}
+			for _, field := range []struct {
+				src float64
+				dst *string
+			}{
+				{legacy.TotalSupply, &supply.TotalSupply},
+				{legacy.TotalMinted, &supply.TotalMinted},
+				{legacy.TotalBurned, &supply.TotalBurned},
+			} {
+				value, err := amountFromFloat(field.src)
+				if err != nil {
+					return nil, fmt.Errorf("레거시 공급량 변환 실패: %v", err)
+				}
+				*field.dst = value.String()
+			}
+
+			if err := c.saveSupply(ctx, supply); err != nil {
+				return nil, err
+			}
+			result.SupplyMigrated = true
+		}
+	}
+
+	return result, nil
+}
+
 // ========== 내부 헬퍼 ==========
 
-func (c *EPCContract) getOrCreateBalance(ctx contractapi.TransactionContextInterface, userID string) (*TokenBalance, error) {
-	balanceJSON, err := ctx.GetStub().GetState("BAL_" + userID)
+// legacyTokenBalance - 마이그레이션 이전 float64 잔액 레코드
+type legacyTokenBalance struct {
+	UserID        string  `json:"userId"`
+	Balance       float64 `json:"balance"`
+	LockedBalance float64 `json:"lockedBalance"`
+	UpdatedAt     string  `json:"updatedAt"`
+}
+
+// legacyTokenSupply - 마이그레이션 이전 float64 공급량 레코드
+type legacyTokenSupply struct {
+	TotalSupply  float64 `json:"totalSupply"`
+	TotalMinted  float64 `json:"totalMinted"`
+	TotalBurned  float64 `json:"totalBurned"`
+	CurrentPrice float64 `json:"currentPrice"`
+	UpdatedAt    string  `json:"updatedAt"`
+}
+
+// isLegacyAmount - JSON 값이 문자열이 아닌 숫자로 기록되었는지 확인
+func isLegacyAmount(raw json.RawMessage) bool {
+	return len(raw) > 0 && raw[0] != '"'
+}
+
+func parsePositiveAmount(value string, label string) (Amount, error) {
+	amount, err := ParseAmount(value)
 	if err != nil {
-		return nil, fmt.Errorf("잔액 조회 실패: %v", err)
+		return 0, err
 	}
+	if amount <= 0 {
+		return 0, fmt.Errorf("%s은 0보다 커야 합니다", label)
+	}
+	return amount, nil
+}
 
-	if balanceJSON == nil {
-		return &TokenBalance{
-			UserID:        userID,
-			Balance:       0,
-			LockedBalance: 0,
-			UpdatedAt:     time.Now().UTC().Format(time.RFC3339),
-		}, nil
+func balanceAmounts(balance *TokenBalance) (Amount, Amount, error) {
+	total, err := ParseAmount(balance.Balance)
+	if err != nil {
+		return 0, 0, fmt.Errorf("잔액 파싱 실패 (%s): %v", balance.UserID, err)
+	}
+	locked, err := ParseAmount(balance.LockedBalance)
+	if err != nil {
+		return 0, 0, fmt.Errorf("잠금 잔액 파싱 실패 (%s): %v", balance.UserID, err)
+	}
+	return total, locked, nil
+}
+
+func supplyAmounts(supply *TokenSupply) (Amount, Amount, Amount, error) {
+	totalSupply, err := ParseAmount(supply.TotalSupply)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("총 공급량 파싱 실패: %v", err)
+	}
+	totalMinted, err := ParseAmount(supply.TotalMinted)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("총 발행량 파싱 실패: %v", err)
+	}
+	totalBurned, err := ParseAmount(supply.TotalBurned)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("총 소각량 파싱 실패: %v", err)
+	}
+	return totalSupply, totalMinted, totalBurned, nil
+}
+
+// transfer - 가용 잔액(잠금 제외) 검사 후 이체 및 거래 기록
+func (c *EPCContract) transfer(ctx contractapi.TransactionContextInterface, fromUserID string, toUserID string, value Amount, reason string, refID string, spenderID string) error {
+	if fromUserID == toUserID {
+		return fmt.Errorf("자기 자신에게 이체할 수 없습니다")
+	}
+
+	fromBalance, err := c.getOrCreateBalance(ctx, fromUserID)
+	if err != nil {
+		return err
+	}
+	fromTotal, fromLocked, err := balanceAmounts(fromBalance)
+	if err != nil {
+		return err
+	}
+
+	availableBalance, err := fromTotal.Sub(fromLocked)
+	if err != nil {
+		return err
+	}
+	if availableBalance < value {
+		return fmt.Errorf("가용 잔액 부족: 가용 %s, 필요 %s", availableBalance, value)
+	}
+
+	toBalance, err := c.getOrCreateBalance(ctx, toUserID)
+	if err != nil {
+		return err
+	}
+	toTotal, _, err := balanceAmounts(toBalance)
+	if err != nil {
+		return err
 	}
 
-	var balance TokenBalance
-	if err := json.Unmarshal(balanceJSON, &balance); err != nil {
-		return nil, fmt.Errorf("잔액 역직렬화 실패: %v", err)
+	if fromTotal, err = fromTotal.Sub(value); err != nil {
+		return err
+	}
+	if toTotal, err = toTotal.Add(value); err != nil {
+		return err
 	}
 
-	return &balance, nil
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	fromBalance.Balance = fromTotal.String()
+	fromBalance.UpdatedAt = now
+	toBalance.Balance = toTotal.String()
+	toBalance.UpdatedAt = now
+
+	if err := c.saveBalance(ctx, fromBalance); err != nil {
+		return err
+	}
+	if err := c.saveBalance(ctx, toBalance); err != nil {
+		return err
+	}
+
+	// 거래 기록
+	tx := TokenTransaction{
+		TxID:      ctx.GetStub().GetTxID(),
+		Type:      "TRANSFER",
+		From:      fromUserID,
+		To:        toUserID,
+		Amount:    value.String(),
+		Spender:   spenderID,
+		Reason:    reason,
+		RefID:     refID,
+		CreatedAt: now,
+	}
+
+	return c.recordTransaction(ctx, tx, "TransferEvent")
 }
 
-func (c *EPCContract) saveBalance(ctx contractapi.TransactionContextInterface, balance *TokenBalance) error {
-	balanceJSON, err := json.Marshal(balance)
+// mint - 사용자 잔액과 총 공급량/총 발행량 증가 (거래 기록은 호출자가 남김)
+func (c *EPCContract) mint(ctx contractapi.TransactionContextInterface, userID string, value Amount, now string) error {
+	balance, err := c.getOrCreateBalance(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("잔액 직렬화 실패: %v", err)
+		return err
+	}
+	total, _, err := balanceAmounts(balance)
+	if err != nil {
+		return err
 	}
+	if total, err = total.Add(value); err != nil {
+		return err
+	}
+	balance.Balance = total.String()
+	balance.UpdatedAt = now
 
-	return ctx.GetStub().PutState("BAL_"+balance.UserID, balanceJSON)
+	if err := c.saveBalance(ctx, balance); err != nil {
+		return err
+	}
+
+	supply, err := c.getSupply(ctx)
+	if err != nil {
+		return err
+	}
+	totalSupply, totalMinted, _, err := supplyAmounts(supply)
+	if err != nil {
+		return err
+	}
+	if totalSupply, err = totalSupply.Add(value); err != nil {
+		return err
+	}
+	if totalMinted, err = totalMinted.Add(value); err != nil {
+		return err
+	}
+	supply.TotalSupply = totalSupply.String()
+	supply.TotalMinted = totalMinted.String()
+	supply.UpdatedAt = now
+
+	return c.saveSupply(ctx, supply)
+}
+
+func (c *EPCContract) getOrCreateBalance(ctx contractapi.TransactionContextInterface, userID string) (*TokenBalance, error) {
+	balance, err := common.GetState[TokenBalance](ctx, "BAL_"+userID, "잔액")
+	if err != nil {
+		return nil, fmt.Errorf("%v (레거시 형식이면 MigrateLegacyAmounts 필요)", err)
+	}
+
+	if balance == nil {
+		now, err := common.TxTimestamp(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &TokenBalance{
+			UserID:        userID,
+			Balance:       Amount(0).String(),
+			LockedBalance: Amount(0).String(),
+			UpdatedAt:     now,
+		}, nil
+	}
+
+	return balance, nil
+}
+
+func (c *EPCContract) saveBalance(ctx contractapi.TransactionContextInterface, balance *TokenBalance) error {
+	return common.PutState(ctx, "BAL_"+balance.UserID, balance, "잔액")
 }
 
 func (c *EPCContract) getSupply(ctx contractapi.TransactionContextInterface) (*TokenSupply, error) {
-	supplyJSON, err := ctx.GetStub().GetState("EPC_SUPPLY")
+	supply, err := common.GetState[TokenSupply](ctx, "EPC_SUPPLY", "공급량")
 	if err != nil {
-		return nil, fmt.Errorf("공급량 조회 실패: %v", err)
+		return nil, fmt.Errorf("%v (레거시 형식이면 MigrateLegacyAmounts 필요)", err)
 	}
 
-	if supplyJSON == nil {
+	if supply == nil {
+		now, err := common.TxTimestamp(ctx)
+		if err != nil {
+			return nil, err
+		}
+
 		return &TokenSupply{
-			TotalSupply:  0,
-			TotalMinted:  0,
-			TotalBurned:  0,
+			TotalSupply:  Amount(0).String(),
+			TotalMinted:  Amount(0).String(),
+			TotalBurned:  Amount(0).String(),
 			CurrentPrice: 0,
-			UpdatedAt:    time.Now().UTC().Format(time.RFC3339),
+			UpdatedAt:    now,
 		}, nil
 	}
 
-	var supply TokenSupply
-	if err := json.Unmarshal(supplyJSON, &supply); err != nil {
-		return nil, fmt.Errorf("공급량 역직렬화 실패: %v", err)
+	return supply, nil
+}
+
+func (c *EPCContract) saveSupply(ctx contractapi.TransactionContextInterface, supply *TokenSupply) error {
+	return common.PutState(ctx, "EPC_SUPPLY", supply, "공급량")
+}
+
+// recordTransaction - TX_{txID} 거래 기록 저장 및 이벤트 발생
+func (c *EPCContract) recordTransaction(ctx contractapi.TransactionContextInterface, tx TokenTransaction, eventName string) error {
+	if err := common.PutState(ctx, "TX_"+tx.TxID, tx, "거래 기록"); err != nil {
+		return err
 	}
 
-	return &supply, nil
+	return common.SetEvent(ctx, eventName, tx)
 }
 
 func main() {
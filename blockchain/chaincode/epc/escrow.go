@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 에스크로 상태
+const (
+	EscrowOpen      = "OPEN"
+	EscrowReleased  = "RELEASED"  // 전액 지급 완료
+	EscrowUnlocked  = "UNLOCKED"  // 운영자 해제로 전액 반환
+	EscrowReclaimed = "RECLAIMED" // 만료 후 소유자 회수
+)
+
+const escrowOwnerIndex = "ESCROW_OWNER"
+
+// tradingChaincode - 매수 주문 에스크로를 잠그고 해제하는 체인코드 이름 (network/scripts/setup-network.sh 기준)
+const tradingChaincode = "trading-cc"
+
+// legacyEscrowPrefix - 에스크로 도입 전 잠금 잔액을 옮긴 에스크로 refID 접두사 (LEGACY_{userID})
+const legacyEscrowPrefix = "LEGACY_"
+
+// Escrow - refID별 잠금 내역
+//
+// Lock이 생성하고 Unlock/ReleaseTo/SettleLocked/ReclaimExpired가 잔여 잠금액을 차감한다.
+// 에스크로 도입 전에 잠근 금액은 어느 에스크로에도 속하지 않으므로, MigrateLegacyLocks로
+// LEGACY_{userID} 에스크로를 만든 뒤에야 사용자별 OPEN 에스크로 Remaining 합계가 TokenBalance.LockedBalance와 같아진다.
+type Escrow struct {
+	RefID         string `json:"refId"`
+	OwnerID       string `json:"ownerId"`
+	BeneficiaryID string `json:"beneficiaryId"` // 비어 있으면 지급 대상 제한 없음
+	Amount        string `json:"amount"`        // 최초 잠금액
+	Remaining     string `json:"remaining"`     // 잔여 잠금액
+	Status        string `json:"status"`        // OPEN, RELEASED, UNLOCKED, RECLAIMED
+	ExpiresAt     string `json:"expiresAt"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+}
+
+// LockMigrationResult - 레거시 잠금 잔액 에스크로 이관 결과
+type LockMigrationResult struct {
+	MigratedUsers  int      `json:"migratedUsers"`
+	MigratedAmount string   `json:"migratedAmount"`
+	Inconsistent   []string `json:"inconsistent"` // 에스크로 합계가 잠금 잔액보다 크거나 LEGACY 에스크로가 이미 종료된 사용자
+}
+
+// GetEscrow - 에스크로 조회
+func (c *EPCContract) GetEscrow(ctx contractapi.TransactionContextInterface, refID string) (*Escrow, error) {
+	return common.MustGetState[Escrow](ctx, escrowKey(refID), "에스크로", refID)
+}
+
+// GetOpenEscrows - 사용자의 잠금 중인 에스크로 목록
+func (c *EPCContract) GetOpenEscrows(ctx contractapi.TransactionContextInterface, userID string) ([]Escrow, error) {
+	escrows := []Escrow{}
+	err := common.ForEachByPartialKey(ctx, escrowOwnerIndex, []string{userID}, func(key string, value []byte) error {
+		escrow, err := c.GetEscrow(ctx, string(value))
+		if err != nil {
+			return err
+		}
+		escrows = append(escrows, *escrow)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return escrows, nil
+}
+
+// ReleaseTo - 에스크로 자금을 수취인에게 지급 (정산 운영자 전용)
+func (c *EPCContract) ReleaseTo(ctx contractapi.TransactionContextInterface, refID string, toUserID string, amount string, reason string) error {
+	if _, err := c.requireAction(ctx, ActionLock); err != nil {
+		return err
+	}
+
+	value, err := parsePositiveAmount(amount, "지급액")
+	if err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	escrow, err := c.getReleasableEscrow(ctx, refID, toUserID, now)
+	if err != nil {
+		return err
+	}
+	if toUserID == escrow.OwnerID {
+		return fmt.Errorf("소유자에게 지급할 수 없습니다 (잠금 해제는 Unlock 사용): %s", refID)
+	}
+
+	if err := c.debitLocked(ctx, escrow.OwnerID, value, true, now); err != nil {
+		return err
+	}
+	if err := c.credit(ctx, toUserID, value, now); err != nil {
+		return err
+	}
+	if err := c.consumeEscrow(ctx, escrow, value, EscrowReleased, now); err != nil {
+		return err
+	}
+
+	tx := TokenTransaction{
+		TxID:      ctx.GetStub().GetTxID(),
+		Type:      "RELEASE",
+		From:      escrow.OwnerID,
+		To:        toUserID,
+		Amount:    value.String(),
+		Reason:    reason,
+		RefID:     refID,
+		CreatedAt: now,
+	}
+
+	return c.recordTransaction(ctx, tx, "ReleaseEvent")
+}
+
+// ReclaimExpired - 만료된 에스크로의 잔여 잠금액 회수 (소유자 전용)
+//
+// 백엔드 장애 등으로 해제되지 않은 잠금을 소유자가 직접 풀 수 있게 한다.
+func (c *EPCContract) ReclaimExpired(ctx contractapi.TransactionContextInterface, refID string) error {
+	escrow, err := c.getOpenEscrow(ctx, refID)
+	if err != nil {
+		return err
+	}
+	if _, err := c.requireOwner(ctx, escrow.OwnerID); err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	expired, err := escrowExpired(escrow, now)
+	if err != nil {
+		return err
+	}
+	if !expired {
+		return fmt.Errorf("에스크로가 아직 만료되지 않았습니다: %s (만료: %s)", refID, escrow.ExpiresAt)
+	}
+
+	remaining, err := ParseAmount(escrow.Remaining)
+	if err != nil {
+		return fmt.Errorf("에스크로 잔여액 파싱 실패: %v", err)
+	}
+	if err := c.debitLocked(ctx, escrow.OwnerID, remaining, false, now); err != nil {
+		return err
+	}
+	if err := c.consumeEscrow(ctx, escrow, remaining, EscrowReclaimed, now); err != nil {
+		return err
+	}
+
+	tx := TokenTransaction{
+		TxID:      ctx.GetStub().GetTxID(),
+		Type:      "UNLOCK",
+		From:      "",
+		To:        escrow.OwnerID,
+		Amount:    remaining.String(),
+		Reason:    "escrow_expired",
+		RefID:     refID,
+		CreatedAt: now,
+	}
+
+	return c.recordTransaction(ctx, tx, "ReclaimEvent")
+}
+
+// MigrateLegacyLocks - 어느 에스크로에도 속하지 않은 레거시 잠금 잔액을 LEGACY_{userID} 에스크로로 이관 (관리자 전용)
+//
+// 사용자별로 LockedBalance에서 OPEN 에스크로 Remaining 합계를 뺀 금액을 수취인 제한 없는 에스크로로 만든다.
+// 이후 Unlock/ReleaseTo/SettleLocked로 해제·지급할 수 있고 expiresAt이 지나면 소유자가 회수할 수 있다.
+// 잔액은 바꾸지 않으며 이미 이관된 사용자는 차액이 0이므로 재실행해도 안전하다.
+// MigrateLegacyAmounts 이후 별도 트랜잭션으로 실행한다.
+func (c *EPCContract) MigrateLegacyLocks(ctx contractapi.TransactionContextInterface, expiresAt string) (*LockMigrationResult, error) {
+	if _, err := c.requireAction(ctx, ActionAdmin); err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LockMigrationResult{Inconsistent: []string{}}
+	var migrated Amount
+
+	startKey, endKey := common.PrefixRange("BAL_")
+	err = common.ForEachInRange(ctx, startKey, endKey, func(key string, value []byte) error {
+		var balance TokenBalance
+		if err := json.Unmarshal(value, &balance); err != nil {
+			return fmt.Errorf("잔액 역직렬화 실패 (%s, MigrateLegacyAmounts를 먼저 실행하세요): %v", key, err)
+		}
+		_, locked, err := balanceAmounts(&balance)
+		if err != nil {
+			return err
+		}
+		if locked == 0 {
+			return nil
+		}
+
+		covered, err := c.openEscrowTotal(ctx, balance.UserID)
+		if err != nil {
+			return err
+		}
+		if covered > locked {
+			result.Inconsistent = append(result.Inconsistent, balance.UserID)
+			return nil
+		}
+		uncovered, err := locked.Sub(covered)
+		if err != nil {
+			return err
+		}
+		if uncovered == 0 {
+			return nil
+		}
+
+		refID := legacyEscrowPrefix + balance.UserID
+		exists, err := common.Exists(ctx, escrowKey(refID))
+		if err != nil {
+			return err
+		}
+		if exists {
+			result.Inconsistent = append(result.Inconsistent, balance.UserID)
+			return nil
+		}
+		if err := c.createEscrow(ctx, refID, balance.UserID, "", uncovered, expiresAt, now); err != nil {
+			return err
+		}
+
+		if migrated, err = migrated.Add(uncovered); err != nil {
+			return err
+		}
+		result.MigratedUsers++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.MigratedAmount = migrated.String()
+	return result, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+func escrowKey(refID string) string {
+	return "ESCROW_" + refID
+}
+
+// createEscrow - 에스크로 및 소유자 인덱스 저장
+func (c *EPCContract) createEscrow(ctx contractapi.TransactionContextInterface, refID string, ownerID string, beneficiaryID string, value Amount, expiresAt string, now string) error {
+	if refID == "" {
+		return fmt.Errorf("refID가 비어 있습니다")
+	}
+	if beneficiaryID == ownerID {
+		return fmt.Errorf("수취인은 소유자와 달라야 합니다: %s", beneficiaryID)
+	}
+
+	existing, err := common.GetState[Escrow](ctx, escrowKey(refID), "에스크로")
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("에스크로가 이미 존재합니다: %s", refID)
+	}
+
+	expiry, err := common.ParseTime(expiresAt)
+	if err != nil {
+		return err
+	}
+	if expiresAt = common.FormatTime(expiry); expiresAt <= now {
+		return fmt.Errorf("만료 시각이 이미 지났습니다: %s", expiresAt)
+	}
+
+	escrow := Escrow{
+		RefID:         refID,
+		OwnerID:       ownerID,
+		BeneficiaryID: beneficiaryID,
+		Amount:        value.String(),
+		Remaining:     value.String(),
+		Status:        EscrowOpen,
+		ExpiresAt:     expiresAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := common.PutState(ctx, escrowKey(refID), escrow, "에스크로"); err != nil {
+		return err
+	}
+
+	indexKey, err := common.CompositeKey(ctx, escrowOwnerIndex, ownerID, refID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(refID)); err != nil {
+		return common.Failed("에스크로 인덱스 저장", err)
+	}
+
+	return nil
+}
+
+// getOpenEscrow - 잠금 중인 에스크로 조회
+func (c *EPCContract) getOpenEscrow(ctx contractapi.TransactionContextInterface, refID string) (*Escrow, error) {
+	escrow, err := c.GetEscrow(ctx, refID)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.Status != EscrowOpen {
+		return nil, fmt.Errorf("종료된 에스크로입니다: %s (%s)", refID, escrow.Status)
+	}
+
+	return escrow, nil
+}
+
+// getReleasableEscrow - toUserID에게 지급 가능한(만료 전, 수취인 일치) 에스크로 조회
+func (c *EPCContract) getReleasableEscrow(ctx contractapi.TransactionContextInterface, refID string, toUserID string, now string) (*Escrow, error) {
+	escrow, err := c.getOpenEscrow(ctx, refID)
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := escrowExpired(escrow, now)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, fmt.Errorf("만료된 에스크로는 지급할 수 없습니다: %s (만료: %s)", refID, escrow.ExpiresAt)
+	}
+	if escrow.BeneficiaryID != "" && escrow.BeneficiaryID != toUserID {
+		return nil, fmt.Errorf("에스크로 수취인 불일치: 지정 %s, 요청 %s", escrow.BeneficiaryID, toUserID)
+	}
+
+	return escrow, nil
+}
+
+// openEscrowTotal - 사용자의 OPEN 에스크로 잔여 잠금액 합계
+func (c *EPCContract) openEscrowTotal(ctx contractapi.TransactionContextInterface, userID string) (Amount, error) {
+	escrows, err := c.GetOpenEscrows(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var total Amount
+	for _, escrow := range escrows {
+		remaining, err := ParseAmount(escrow.Remaining)
+		if err != nil {
+			return 0, fmt.Errorf("에스크로 잔여액 파싱 실패 (%s): %v", escrow.RefID, err)
+		}
+		if total, err = total.Add(remaining); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// consumeEscrow - 잔여 잠금액 차감, 전액 소진 시 closeStatus로 종료하고 인덱스 삭제
+func (c *EPCContract) consumeEscrow(ctx contractapi.TransactionContextInterface, escrow *Escrow, value Amount, closeStatus string, now string) error {
+	remaining, err := ParseAmount(escrow.Remaining)
+	if err != nil {
+		return fmt.Errorf("에스크로 잔여액 파싱 실패: %v", err)
+	}
+	if remaining < value {
+		return fmt.Errorf("에스크로 잔여액 부족: %s 잔여 %s, 요청 %s", escrow.RefID, remaining, value)
+	}
+	if remaining, err = remaining.Sub(value); err != nil {
+		return err
+	}
+
+	escrow.Remaining = remaining.String()
+	escrow.UpdatedAt = now
+	if remaining == 0 {
+		escrow.Status = closeStatus
+
+		indexKey, err := common.CompositeKey(ctx, escrowOwnerIndex, escrow.OwnerID, escrow.RefID)
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().DelState(indexKey); err != nil {
+			return common.Failed("에스크로 인덱스 삭제", err)
+		}
+	}
+
+	return common.PutState(ctx, escrowKey(escrow.RefID), escrow, "에스크로")
+}
+
+// debitLocked - 잠금 잔액 차감 (spend가 true이면 총 잔액도 함께 차감하여 지급, 아니면 잠금 해제)
+func (c *EPCContract) debitLocked(ctx contractapi.TransactionContextInterface, userID string, value Amount, spend bool, now string) error {
+	balance, err := c.getOrCreateBalance(ctx, userID)
+	if err != nil {
+		return err
+	}
+	total, locked, err := balanceAmounts(balance)
+	if err != nil {
+		return err
+	}
+
+	if locked < value {
+		return fmt.Errorf("잠금 잔액 부족: 현재 잠금 %s, 요청 %s", locked, value)
+	}
+	if locked, err = locked.Sub(value); err != nil {
+		return err
+	}
+	if spend {
+		if total, err = total.Sub(value); err != nil {
+			return err
+		}
+	}
+
+	balance.Balance = total.String()
+	balance.LockedBalance = locked.String()
+	balance.UpdatedAt = now
+
+	return c.saveBalance(ctx, balance)
+}
+
+func escrowExpired(escrow *Escrow, now string) (bool, error) {
+	expiry, err := common.ParseTime(escrow.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	current, err := common.ParseTime(now)
+	if err != nil {
+		return false, err
+	}
+
+	return !expiry.After(current), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// meteringChaincode - 발전량 청구 대상 체인코드 이름 (network/scripts/setup-network.sh 기준)
+const meteringChaincode = "metering-cc"
+
+// maxRewardRecords - 보상 한 건에 묶을 수 있는 최대 측정값 수
+const maxRewardRecords = 100
+
+// GenerationReward - 발전 보상 발행 기록 (REWARD_{txID})
+type GenerationReward struct {
+	RewardID       string   `json:"rewardId"` // 발행 트랜잭션 ID (측정값의 claimRef)
+	UserID         string   `json:"userId"`
+	MeterRecordIDs []string `json:"meterRecordIds"`
+	ProductionKWh  float64  `json:"productionKwh"`
+	PriceID        string   `json:"priceId"`
+	Price          float64  `json:"price"`
+	BasketPrice    float64  `json:"basketPrice"`
+	Amount         string   `json:"amount"`
+	CreatedAt      string   `json:"createdAt"`
+}
+
+// generationClaim - metering 체인코드 ClaimGeneration 결과
+type generationClaim struct {
+	ClaimRef      string   `json:"claimRef"`
+	UserID        string   `json:"userId"`
+	RecordIDs     []string `json:"recordIds"`
+	ProductionKWh float64  `json:"productionKwh"`
+}
+
+// ClaimGenerationReward - 검증된 발전 측정값에 대한 EPC 보상 발행 (발행 권한 필요)
+//
+// 근거 측정값은 트랜지언트 맵 "meterRecords"(common.MeterDisclosureTransientKey)로 공개받는다.
+// metering.ClaimGeneration이 이를 공개 약정(MeterCommitment)과 대조하고 청구 표시를 남기므로
+// 비공개 컬렉션을 읽지 않고 채널 기본 정책의 보증 피어에서 실행되며, 한 측정값은 한 번만 보상받는다.
+// 보상액은 EPC_LATEST_PRICE 기준 발전량(kWh) × price / basketPrice 이며 기본 단위 미만은 버린다.
+func (c *EPCContract) ClaimGenerationReward(ctx contractapi.TransactionContextInterface, userID string, recordIDsJSON string) (*GenerationReward, error) {
+	if _, err := c.requireAction(ctx, ActionMint); err != nil {
+		return nil, err
+	}
+
+	var recordIDs []string
+	if err := json.Unmarshal([]byte(recordIDsJSON), &recordIDs); err != nil {
+		return nil, fmt.Errorf("측정값 ID 목록 역직렬화 실패: %v", err)
+	}
+	if len(recordIDs) == 0 || len(recordIDs) > maxRewardRecords {
+		return nil, fmt.Errorf("측정값은 1~%d건이어야 합니다: %d건", maxRewardRecords, len(recordIDs))
+	}
+
+	price, err := c.GetPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	disclosures, err := common.GetMeterDisclosures(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	payload, err := common.InvokeChaincode(ctx, meteringChaincode, "ClaimGeneration", userID, recordIDsJSON, txID, disclosures)
+	if err != nil {
+		return nil, err
+	}
+	var claim generationClaim
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return nil, common.Failed("발전량 청구 역직렬화", err)
+	}
+	if claim.UserID != userID || claim.ClaimRef != txID {
+		return nil, fmt.Errorf("발전량 청구 결과가 요청과 일치하지 않습니다")
+	}
+
+	value, err := rewardAmount(claim.ProductionKWh, price)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.mint(ctx, userID, value, now); err != nil {
+		return nil, err
+	}
+
+	reward := &GenerationReward{
+		RewardID:       txID,
+		UserID:         userID,
+		MeterRecordIDs: claim.RecordIDs,
+		ProductionKWh:  claim.ProductionKWh,
+		PriceID:        price.PriceID,
+		Price:          price.Price,
+		BasketPrice:    price.BasketPrice,
+		Amount:         value.String(),
+		CreatedAt:      now,
+	}
+	if err := common.PutState(ctx, "REWARD_"+txID, reward, "발전 보상"); err != nil {
+		return nil, err
+	}
+
+	tx := TokenTransaction{
+		TxID:           txID,
+		Type:           "MINT",
+		From:           "",
+		To:             userID,
+		Amount:         value.String(),
+		MeterRecordIDs: claim.RecordIDs,
+		Reason:         "generation_reward",
+		RefID:          txID,
+		CreatedAt:      now,
+	}
+	if err := c.recordTransaction(ctx, tx, "MintEvent"); err != nil {
+		return nil, err
+	}
+
+	return reward, nil
+}
+
+// GetGenerationReward - 발전 보상 발행 기록 조회
+func (c *EPCContract) GetGenerationReward(ctx contractapi.TransactionContextInterface, rewardID string) (*GenerationReward, error) {
+	return common.MustGetState[GenerationReward](ctx, "REWARD_"+rewardID, "발전 보상", rewardID)
+}
+
+// ========== 내부 헬퍼 ==========
+
+// rewardAmount - 발전량(kWh)의 EPC 환산액 (kWh × price / basketPrice, 기본 단위 미만 버림)
+func rewardAmount(kWh float64, price *PriceRecord) (Amount, error) {
+	if price.BasketPrice <= 0 {
+		return 0, fmt.Errorf("바스켓 가격이 올바르지 않습니다: %v", price.BasketPrice)
+	}
+
+	units := math.Floor(kWh * price.Price / price.BasketPrice * float64(unitsPerToken))
+	if math.IsNaN(units) || units >= math.MaxInt64 {
+		return 0, fmt.Errorf("보상액 범위 초과: %v kWh", kWh)
+	}
+	if units < 1 {
+		return 0, fmt.Errorf("보상액이 최소 단위보다 작습니다: %v kWh", kWh)
+	}
+
+	return Amount(units), nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SettleLocked - 거래 정산 (정산 운영자 전용)
+//
+// 구매자의 refID 에스크로에서 amount를 차감하여 판매자에게 amount-fee, 수수료 계정에 fee를 지급한다.
+// 잠금 해제와 두 건의 이체를 한 번에 처리하므로 중간 실패로 잔액이 어긋나지 않는다.
+func (c *EPCContract) SettleLocked(ctx contractapi.TransactionContextInterface, buyerID string, sellerID string, amount string, fee string, feeAccountID string, refID string) error {
+	if _, err := c.requireAction(ctx, ActionLock); err != nil {
+		return err
+	}
+
+	gross, err := parsePositiveAmount(amount, "정산 금액")
+	if err != nil {
+		return err
+	}
+	feeValue, err := ParseAmount(fee)
+	if err != nil {
+		return err
+	}
+	if feeValue > gross {
+		return fmt.Errorf("수수료가 정산 금액보다 큽니다: 금액 %s, 수수료 %s", gross, feeValue)
+	}
+	net, err := gross.Sub(feeValue)
+	if err != nil {
+		return err
+	}
+
+	if buyerID == sellerID {
+		return fmt.Errorf("구매자와 판매자가 같습니다: %s", buyerID)
+	}
+	if feeValue > 0 && (feeAccountID == "" || feeAccountID == buyerID) {
+		return fmt.Errorf("잘못된 수수료 계정: %q", feeAccountID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	escrow, err := c.getReleasableEscrow(ctx, refID, sellerID, now)
+	if err != nil {
+		return err
+	}
+	if escrow.OwnerID != buyerID {
+		return fmt.Errorf("에스크로 소유자 불일치: %s 의 소유자는 %s 입니다", refID, escrow.OwnerID)
+	}
+	if err := c.consumeEscrow(ctx, escrow, gross, EscrowReleased, now); err != nil {
+		return err
+	}
+	if err := c.debitLocked(ctx, buyerID, gross, true, now); err != nil {
+		return err
+	}
+
+	// 같은 트랜잭션 내에서는 자신이 쓴 값을 다시 읽을 수 없으므로
+	// 판매자가 수수료 계정을 겸하는 경우 한 번에 입금한다
+	credits := map[string]Amount{sellerID: net}
+	order := []string{sellerID}
+	if feeValue > 0 {
+		if _, ok := credits[feeAccountID]; !ok {
+			order = append(order, feeAccountID)
+		}
+		if credits[feeAccountID], err = credits[feeAccountID].Add(feeValue); err != nil {
+			return err
+		}
+	}
+	for _, userID := range order {
+		if err := c.credit(ctx, userID, credits[userID], now); err != nil {
+			return err
+		}
+	}
+
+	tx := TokenTransaction{
+		TxID:       ctx.GetStub().GetTxID(),
+		Type:       "SETTLE",
+		From:       buyerID,
+		To:         sellerID,
+		Amount:     net.String(),
+		Fee:        feeValue.String(),
+		FeeAccount: feeAccountID,
+		Reason:     "trade_settlement",
+		RefID:      refID,
+		CreatedAt:  now,
+	}
+
+	return c.recordTransaction(ctx, tx, "SettlementEvent")
+}
+
+// credit - 잔액 입금
+func (c *EPCContract) credit(ctx contractapi.TransactionContextInterface, userID string, value Amount, now string) error {
+	balance, err := c.getOrCreateBalance(ctx, userID)
+	if err != nil {
+		return err
+	}
+	total, _, err := balanceAmounts(balance)
+	if err != nil {
+		return err
+	}
+	if total, err = total.Add(value); err != nil {
+		return err
+	}
+
+	balance.Balance = total.String()
+	balance.UpdatedAt = now
+
+	return c.saveBalance(ctx, balance)
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 집계 단위 (구간 경계는 UTC 기준)
+const (
+	GranularityHourly  = "HOURLY"
+	GranularityDaily   = "DAILY"
+	GranularityMonthly = "MONTHLY"
+)
+
+// 집계 비공개 키 objectType (common.PrivateKey)
+//
+// 구간 합계: AGG_USER~{단위}~{userID}~{구간 시작}, AGG_DEVICE~{단위}~{userID}~{deviceID}~{구간 시작}
+// 구간 증감: AGG_DELTA_USER~{단위}~{userID}~{구간 시작}~{txID}~{recordID}, AGG_DELTA_DEVICE~{단위}~{userID}~{deviceID}~{구간 시작}~{txID}~{recordID}
+// 측정값 기록은 트랜잭션마다 새 증감 키만 쓰므로 같은 사용자의 측정값이 한 블록에 몰려도 MVCC 충돌이 없고,
+// FoldEnergyAggregates가 쌓인 증감을 구간 합계에 합친다.
+const (
+	userAggregateIndex   = "AGG_USER"
+	deviceAggregateIndex = "AGG_DEVICE"
+	userDeltaIndex       = "AGG_DELTA_USER"
+	deviceDeltaIndex     = "AGG_DELTA_DEVICE"
+)
+
+// maxSummaryBuckets - GetEnergySummary 한 번에 반환할 수 있는 최대 구간 수
+const maxSummaryBuckets = 1000
+
+// maxFoldDeltas - FoldEnergyAggregates 한 번에 합칠 수 있는 최대 증감 수
+const maxFoldDeltas = 500
+
+var granularities = []string{GranularityHourly, GranularityDaily, GranularityMonthly}
+
+// EnergyBucket - 구간별 발전/소비 집계
+type EnergyBucket struct {
+	Granularity  string  `json:"granularity"`
+	UserID       string  `json:"userId"`
+	DeviceID     string  `json:"deviceId,omitempty" metadata:",optional"` // 사용자 합계는 빈 문자열
+	BucketStart  string  `json:"bucketStart"`
+	Production   float64 `json:"production"`
+	Consumption  float64 `json:"consumption"`
+	NetExport    float64 `json:"netExport"` // 발전 - 소비
+	ReadingCount int     `json:"readingCount"`
+	UpdatedAt    string  `json:"updatedAt"`
+}
+
+// energyDelta - 측정값 한 건의 집계 증감 (기록·정정·무효 판정마다 한 건)
+type energyDelta struct {
+	Production  float64 `json:"production"`
+	Consumption float64 `json:"consumption"`
+	Readings    int     `json:"readings"`
+	RecordedAt  string  `json:"recordedAt"`
+}
+
+// EnergySummary - 조회 구간의 집계 결과
+type EnergySummary struct {
+	UserID           string         `json:"userId"`
+	DeviceID         string         `json:"deviceId,omitempty" metadata:",optional"`
+	Granularity      string         `json:"granularity"`
+	From             string         `json:"from"`
+	To               string         `json:"to"`
+	Buckets          []EnergyBucket `json:"buckets"`
+	TotalProduction  float64        `json:"totalProduction"`
+	TotalConsumption float64        `json:"totalConsumption"`
+	NetExport        float64        `json:"netExport"`
+}
+
+// AggregateFold - FoldEnergyAggregates 결과
+type AggregateFold struct {
+	UserID         string `json:"userId"`
+	FoldedDeltas   int    `json:"foldedDeltas"`
+	UpdatedBuckets int    `json:"updatedBuckets"`
+	Complete       bool   `json:"complete"` // false면 남은 증감이 있으므로 다시 호출해야 한다
+	FoldedAt       string `json:"foldedAt"`
+}
+
+// GetEnergySummary - 사용자 전체 디바이스의 구간별 발전/소비 합계 조회
+//
+// from 이상 to 미만에 시작하는 집계 구간을 시간순으로 반환한다. 구간 합계에 아직 합쳐지지 않은 증감도
+// 더해 반환하므로 FoldEnergyAggregates 호출 전에도 최신 값이다. 본인 또는 관리자만 조회할 수 있다.
+func (c *MeteringContract) GetEnergySummary(ctx contractapi.TransactionContextInterface, userID string, granularity string, from string, to string) (*EnergySummary, error) {
+	return getEnergySummary(ctx, userID, "", granularity, from, to)
+}
+
+// GetDeviceEnergySummary - 디바이스 한 대의 구간별 발전/소비 합계 조회
+func (c *MeteringContract) GetDeviceEnergySummary(ctx contractapi.TransactionContextInterface, userID string, deviceID string, granularity string, from string, to string) (*EnergySummary, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("디바이스 ID가 비어 있습니다")
+	}
+	return getEnergySummary(ctx, userID, deviceID, granularity, from, to)
+}
+
+// FoldEnergyAggregates - 사용자의 쌓인 집계 증감을 시간/일/월 구간 합계에 합치고 증감 키 삭제 (관리자 전용)
+//
+// 한 번에 최대 maxFoldDeltas건을 합치며, Complete가 false면 남은 증감이 있으므로 다시 호출한다.
+// 측정값 기록과 다른 키를 쓰므로 주기적으로 호출해도 RecordMeter와 충돌하지 않는다.
+func (c *MeteringContract) FoldEnergyAggregates(ctx contractapi.TransactionContextInterface, userID string) (*AggregateFold, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("사용자 ID가 비어 있습니다")
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fold := &AggregateFold{UserID: userID, Complete: true, FoldedAt: now}
+	buckets := map[string]*EnergyBucket{}
+	var bucketKeys []string
+
+	for _, granularity := range granularities {
+		for _, index := range []string{userDeltaIndex, deviceDeltaIndex} {
+			err := common.ForEachPrivateByPartialKey(ctx, meterCollection, index, []string{granularity, userID}, func(key string, value []byte) error {
+				if fold.FoldedDeltas == maxFoldDeltas {
+					fold.Complete = false
+					return common.ErrStop
+				}
+
+				empty, delta, err := parseDelta(ctx, key, value)
+				if err != nil {
+					return err
+				}
+				bucketKey, err := aggregateKey(ctx, empty)
+				if err != nil {
+					return err
+				}
+
+				bucket, ok := buckets[bucketKey]
+				if !ok {
+					if bucket, err = common.GetPrivateState[EnergyBucket](ctx, meterCollection, bucketKey, "집계"); err != nil {
+						return err
+					}
+					if bucket == nil {
+						bucket = empty
+					}
+					buckets[bucketKey] = bucket
+					bucketKeys = append(bucketKeys, bucketKey)
+				}
+				bucket.apply(delta)
+
+				if err := ctx.GetStub().DelPrivateData(meterCollection, key); err != nil {
+					return common.Failed("집계 증감 삭제", err)
+				}
+				fold.FoldedDeltas++
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, bucketKey := range bucketKeys {
+		if err := common.PutPrivateState(ctx, meterCollection, bucketKey, buckets[bucketKey], "집계"); err != nil {
+			return nil, err
+		}
+	}
+	fold.UpdatedBuckets = len(bucketKeys)
+
+	return fold, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// updateAggregates - 측정값 증감을 사용자·디바이스의 시간/일/월 구간 증감 키에 기록
+//
+// 새 측정값은 readings=1, 정정은 증감분과 readings=0, 무효 판정은 음의 값과 readings=-1로 호출한다.
+// 구간 합계 키는 읽거나 쓰지 않으며 FoldEnergyAggregates가 나중에 합친다.
+func updateAggregates(ctx contractapi.TransactionContextInterface, record *MeterRecord, production float64, consumption float64, readings int, now string) error {
+	ts, err := common.ParseTime(record.Timestamp)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+	delta := energyDelta{Production: production, Consumption: consumption, Readings: readings, RecordedAt: now}
+
+	for _, granularity := range granularities {
+		start := bucketStart(ts, granularity)
+		userKey, err := common.PrivateKey(ctx, userDeltaIndex, granularity, record.UserID, start, txID, record.RecordID)
+		if err != nil {
+			return err
+		}
+		deviceKey, err := common.PrivateKey(ctx, deviceDeltaIndex, granularity, record.UserID, record.DeviceID, start, txID, record.RecordID)
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{userKey, deviceKey} {
+			if err := common.PutPrivateState(ctx, meterCollection, key, delta, "집계 증감"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// apply - 증감 한 건을 구간 합계에 반영
+func (b *EnergyBucket) apply(delta *energyDelta) {
+	b.Production += delta.Production
+	b.Consumption += delta.Consumption
+	b.NetExport = b.Production - b.Consumption
+	b.ReadingCount += delta.Readings
+	if delta.RecordedAt > b.UpdatedAt {
+		b.UpdatedAt = delta.RecordedAt
+	}
+}
+
+// aggregateKey - 사용자 합계(DeviceID 없음) 또는 디바이스 구간 합계 키
+func aggregateKey(ctx contractapi.TransactionContextInterface, bucket *EnergyBucket) (string, error) {
+	if bucket.DeviceID == "" {
+		return common.PrivateKey(ctx, userAggregateIndex, bucket.Granularity, bucket.UserID, bucket.BucketStart)
+	}
+	return common.PrivateKey(ctx, deviceAggregateIndex, bucket.Granularity, bucket.UserID, bucket.DeviceID, bucket.BucketStart)
+}
+
+// parseDelta - 증감 키/값을 증감과 그 증감이 속한 빈 구간 합계로 해석
+func parseDelta(ctx contractapi.TransactionContextInterface, key string, value []byte) (*EnergyBucket, *energyDelta, error) {
+	index, attributes, err := common.SplitPrivateKey(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bucket := &EnergyBucket{}
+	switch {
+	case index == userDeltaIndex && len(attributes) == 5:
+		bucket.Granularity, bucket.UserID, bucket.BucketStart = attributes[0], attributes[1], attributes[2]
+	case index == deviceDeltaIndex && len(attributes) == 6:
+		bucket.Granularity, bucket.UserID, bucket.DeviceID, bucket.BucketStart = attributes[0], attributes[1], attributes[2], attributes[3]
+	default:
+		return nil, nil, fmt.Errorf("알 수 없는 집계 증감 키입니다: %q", key)
+	}
+
+	var delta energyDelta
+	if err := json.Unmarshal(value, &delta); err != nil {
+		return nil, nil, common.Failed("집계 증감 역직렬화", err)
+	}
+
+	return bucket, &delta, nil
+}
+
+// getEnergySummary - [from, to)에 시작하는 구간 합계를 읽고 아직 합쳐지지 않은 증감을 더해 반환
+func getEnergySummary(ctx contractapi.TransactionContextInterface, userID string, deviceID string, granularity string, from string, to string) (*EnergySummary, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("사용자 ID가 비어 있습니다")
+	}
+	if _, err := requireOwnerOrAdmin(ctx, userID); err != nil {
+		return nil, err
+	}
+	if !common.Contains(granularities, granularity) {
+		return nil, fmt.Errorf("알 수 없는 집계 단위입니다: %s (HOURLY, DAILY, MONTHLY)", granularity)
+	}
+	start, err := common.ParseTime(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := common.ParseTime(to)
+	if err != nil {
+		return nil, err
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("조회 구간이 올바르지 않습니다: %s ~ %s", from, to)
+	}
+	startKey, endKey := common.FormatTime(start), common.FormatTime(end)
+
+	summary := &EnergySummary{
+		UserID:      userID,
+		DeviceID:    deviceID,
+		Granularity: granularity,
+		From:        startKey,
+		To:          endKey,
+		Buckets:     []EnergyBucket{},
+	}
+
+	aggregateIndex, deltaIndex := userAggregateIndex, userDeltaIndex
+	attributes := []string{granularity, userID}
+	if deviceID != "" {
+		aggregateIndex, deltaIndex = deviceAggregateIndex, deviceDeltaIndex
+		attributes = append(attributes, deviceID)
+	}
+
+	// 구간 시작 -> summary.Buckets 위치
+	positions := map[string]int{}
+	bucketAt := func(empty *EnergyBucket) (*EnergyBucket, error) {
+		position, ok := positions[empty.BucketStart]
+		if !ok {
+			if len(summary.Buckets) == maxSummaryBuckets {
+				return nil, fmt.Errorf("조회 구간이 너무 깁니다: 최대 %d개 구간까지 조회할 수 있습니다", maxSummaryBuckets)
+			}
+			summary.Buckets = append(summary.Buckets, *empty)
+			position = len(summary.Buckets) - 1
+			positions[empty.BucketStart] = position
+		}
+		return &summary.Buckets[position], nil
+	}
+
+	rangeStart, rangeEnd, err := bucketRange(ctx, aggregateIndex, attributes, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	err = common.ForEachPrivateInRange(ctx, meterCollection, rangeStart, rangeEnd, func(key string, value []byte) error {
+		var stored EnergyBucket
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return common.Failed("집계 역직렬화", err)
+		}
+		_, err := bucketAt(&stored)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rangeStart, rangeEnd, err = bucketRange(ctx, deltaIndex, attributes, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	err = common.ForEachPrivateInRange(ctx, meterCollection, rangeStart, rangeEnd, func(key string, value []byte) error {
+		empty, delta, err := parseDelta(ctx, key, value)
+		if err != nil {
+			return err
+		}
+		bucket, err := bucketAt(empty)
+		if err != nil {
+			return err
+		}
+		bucket.apply(delta)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 합쳐지지 않은 증감만 있는 구간이 뒤에 붙었을 수 있으므로 구간 시작순으로 정렬
+	sort.Slice(summary.Buckets, func(i, j int) bool {
+		return summary.Buckets[i].BucketStart < summary.Buckets[j].BucketStart
+	})
+	for _, bucket := range summary.Buckets {
+		summary.TotalProduction += bucket.Production
+		summary.TotalConsumption += bucket.Consumption
+	}
+	summary.NetExport = summary.TotalProduction - summary.TotalConsumption
+
+	return summary, nil
+}
+
+// bucketRange - index 키 중 구간 시작이 [startKey, endKey)인 범위
+func bucketRange(ctx contractapi.TransactionContextInterface, index string, attributes []string, startKey string, endKey string) (string, string, error) {
+	rangeStart, err := common.PrivateKey(ctx, index, append(attributes, startKey)...)
+	if err != nil {
+		return "", "", err
+	}
+	rangeEnd, err := common.PrivateKey(ctx, index, append(attributes, endKey)...)
+	if err != nil {
+		return "", "", err
+	}
+
+	return rangeStart, rangeEnd, nil
+}
+
+// bucketStart - 측정 시각이 속한 집계 구간의 시작 시각 (RFC3339 UTC)
+func bucketStart(t time.Time, granularity string) string {
+	switch granularity {
+	case GranularityHourly:
+		t = t.Truncate(time.Hour)
+	case GranularityDaily:
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case GranularityMonthly:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return common.FormatTime(t)
+}
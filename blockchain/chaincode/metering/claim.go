@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ClaimStatusClaimed - 발전 보상이 발행된 측정값 (공개 청구 표시 도입 전 비공개 기록에 남은 값)
+const ClaimStatusClaimed = "CLAIMED"
+
+// generationClaimIndex - 발전 보상 청구 표시 공개 키 objectType (GEN_CLAIM~{recordID} -> GenerationClaimMark)
+const generationClaimIndex = "GEN_CLAIM"
+
+// GenerationClaim - 발전량 청구 결과 (epc 체인코드가 보상 계산에 사용)
+type GenerationClaim struct {
+	ClaimRef      string   `json:"claimRef"`
+	UserID        string   `json:"userId"`
+	RecordIDs     []string `json:"recordIds"`
+	ProductionKWh float64  `json:"productionKwh"`
+	ClaimedAt     string   `json:"claimedAt"`
+}
+
+// GenerationClaimMark - 측정값의 발전 보상 청구 표시 (공개 상태, 측정값 내용은 담지 않는다)
+type GenerationClaimMark struct {
+	RecordID  string `json:"recordId"`
+	ClaimRef  string `json:"claimRef"`
+	ClaimedAt string `json:"claimedAt"`
+}
+
+// ClaimGeneration - 검증된 발전 측정값에 보상 청구 표시를 남기고 발전량 합계 반환 (관리자 전용)
+//
+// epc.ClaimGenerationReward가 같은 트랜잭션에서 호출하며 claimRef는 보상 발행 트랜잭션 ID다.
+// 측정값은 disclosuresJSON(GetMeterRecord 결과 배열, salt 포함)으로 공개받아 공개 약정과 대조하고
+// 청구 여부는 공개 표시(GEN_CLAIM~{recordID})로 관리하므로 비공개 컬렉션을 읽지 않는다.
+// 모든 측정값이 userID 소유의 VALID(검증 도입 이전 기록 포함) 발전량이고 아직 청구되지 않아야 하며,
+// 하나라도 조건에 맞지 않으면 전체를 거부한다.
+func (c *MeteringContract) ClaimGeneration(ctx contractapi.TransactionContextInterface, userID string, recordIDsJSON string, claimRef string, disclosuresJSON string) (*GenerationClaim, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if userID == "" || claimRef == "" {
+		return nil, fmt.Errorf("사용자 ID와 청구 참조는 필수입니다")
+	}
+
+	var recordIDs []string
+	if err := json.Unmarshal([]byte(recordIDsJSON), &recordIDs); err != nil {
+		return nil, fmt.Errorf("측정값 ID 목록 역직렬화 실패: %v", err)
+	}
+	if len(recordIDs) == 0 {
+		return nil, fmt.Errorf("청구할 측정값이 없습니다")
+	}
+
+	disclosed, err := disclosedRecords(ctx, disclosuresJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := &GenerationClaim{ClaimRef: claimRef, UserID: userID, RecordIDs: recordIDs, ClaimedAt: now}
+	seen := map[string]bool{}
+	for _, recordID := range recordIDs {
+		if seen[recordID] {
+			return nil, fmt.Errorf("측정값 ID가 중복되었습니다: %s", recordID)
+		}
+		seen[recordID] = true
+
+		record, ok := disclosed[recordID]
+		if !ok {
+			return nil, fmt.Errorf("공개되지 않은 측정값입니다: %s", recordID)
+		}
+		if err := checkClaimable(record, userID); err != nil {
+			return nil, err
+		}
+
+		markKey, err := common.CompositeKey(ctx, generationClaimIndex, recordID)
+		if err != nil {
+			return nil, err
+		}
+		mark, err := common.GetState[GenerationClaimMark](ctx, markKey, "발전 보상 청구 표시")
+		if err != nil {
+			return nil, err
+		}
+		if mark != nil {
+			return nil, fmt.Errorf("이미 보상이 청구된 측정값입니다: %s (청구: %s)", recordID, mark.ClaimRef)
+		}
+		if err := common.PutState(ctx, markKey, GenerationClaimMark{RecordID: recordID, ClaimRef: claimRef, ClaimedAt: now}, "발전 보상 청구 표시"); err != nil {
+			return nil, err
+		}
+		claim.ProductionKWh += record.Production
+	}
+
+	return claim, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// checkClaimable - 보상 청구 가능 여부 검사 (공개받은 측정값 기준)
+func checkClaimable(record *MeterRecord, userID string) error {
+	if record.UserID != userID {
+		return fmt.Errorf("측정값 %s 는 %s 의 기록이 아닙니다", record.RecordID, userID)
+	}
+	if record.Quality != "" && record.Quality != QualityValid {
+		return fmt.Errorf("검증되지 않은 측정값은 청구할 수 없습니다: %s (%s)", record.RecordID, record.Quality)
+	}
+	if record.ClaimStatus != "" {
+		return fmt.Errorf("이미 보상이 청구된 측정값입니다: %s (청구: %s)", record.RecordID, record.ClaimRef)
+	}
+	if record.Production <= 0 {
+		return fmt.Errorf("발전량이 없는 측정값입니다: %s", record.RecordID)
+	}
+	return nil
+}
+
+// checkCorrectable - 보상 청구나 REC 발행에 사용된 측정값은 발행된 자산과 어긋나므로 정정 거부
+//
+// 공개 표시와 함께 공개 표시 도입 전 비공개 기록에 남은 청구 상태도 확인한다.
+func checkCorrectable(ctx contractapi.TransactionContextInterface, record *MeterRecord) error {
+	if record.ClaimStatus != "" {
+		return fmt.Errorf("보상이 청구된 측정값은 정정할 수 없습니다: %s (청구: %s)", record.RecordID, record.ClaimRef)
+	}
+	if len(record.RECTokenIDs) > 0 {
+		return fmt.Errorf("REC 발행에 사용된 측정값은 정정할 수 없습니다: %s (REC: %v)", record.RecordID, record.RECTokenIDs)
+	}
+
+	markKey, err := common.CompositeKey(ctx, generationClaimIndex, record.RecordID)
+	if err != nil {
+		return err
+	}
+	mark, err := common.GetState[GenerationClaimMark](ctx, markKey, "발전 보상 청구 표시")
+	if err != nil {
+		return err
+	}
+	if mark != nil {
+		return fmt.Errorf("보상이 청구된 측정값은 정정할 수 없습니다: %s (청구: %s)", record.RecordID, mark.ClaimRef)
+	}
+
+	use, err := getRECGenerationUse(ctx, record.RecordID)
+	if err != nil {
+		return err
+	}
+	if use != nil {
+		return fmt.Errorf("REC 발행에 사용된 측정값은 정정할 수 없습니다: %s (REC: %s)", record.RecordID, use.TokenID)
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 디바이스 서명 키 종류
+const (
+	KeyTypeEd25519 = "ED25519"
+	KeyTypeECDSA   = "ECDSA" // P-256, SHA-256, ASN.1 DER 서명
+)
+
+// 디바이스 상태
+const (
+	DeviceActive         = "ACTIVE"
+	DeviceRevoked        = "REVOKED"        // 키 유출 등으로 더 이상 측정값을 받지 않음
+	DeviceDecommissioned = "DECOMMISSIONED" // 설비 철거 (종료 상태)
+)
+
+// 계량기 종류
+const (
+	MeterTypeProduction    = "PRODUCTION"
+	MeterTypeConsumption   = "CONSUMPTION"
+	MeterTypeBidirectional = "BIDIRECTIONAL"
+)
+
+const deviceOwnerIndex = "DEVICE_OWNER"
+
+// meterSignatureVersion - 서명 대상 정규 인코딩 버전
+const meterSignatureVersion = "ETP_METER_V1"
+
+// Device - 미터링 디바이스 (서명 공개키 및 설비 정보)
+type Device struct {
+	DeviceID         string  `json:"deviceId"`
+	OwnerID          string  `json:"ownerId"`
+	KeyType          string  `json:"keyType"`   // ED25519, ECDSA
+	PublicKey        string  `json:"publicKey"` // PKIX PEM
+	MeterType        string  `json:"meterType"` // PRODUCTION, CONSUMPTION, BIDIRECTIONAL
+	CapacityKW       float64 `json:"capacityKw"`
+	Location         string  `json:"location"`
+	EnergySource     string  `json:"energySource"`
+	CommissionedAt   string  `json:"commissionedAt"`
+	Status           string  `json:"status"` // ACTIVE, REVOKED, DECOMMISSIONED
+	RevokeReason     string  `json:"revokeReason,omitempty" metadata:",optional"`
+	DecommissionedAt string  `json:"decommissionedAt,omitempty" metadata:",optional"`
+	TransferredAt    string  `json:"transferredAt,omitempty" metadata:",optional"` // 마지막 소유권 이전 시각
+	LastReadingAt    string  `json:"lastReadingAt,omitempty" metadata:",optional"` // 마지막 측정 시각 (단조 증가 검사용)
+	RegisteredAt     string  `json:"registeredAt"`
+	UpdatedAt        string  `json:"updatedAt"`
+}
+
+// DeviceTransferredEvent - 디바이스 소유권 이전 이벤트
+type DeviceTransferredEvent struct {
+	DeviceID      string `json:"deviceId"`
+	FromOwnerID   string `json:"fromOwnerId"`
+	ToOwnerID     string `json:"toOwnerId"`
+	TransferredBy string `json:"transferredBy"`
+	TransferredAt string `json:"transferredAt"`
+}
+
+// RegisterDevice - 디바이스 등록 (소유자 본인 또는 관리자)
+//
+// publicKey는 PKIX 형식 PEM 또는 base64 DER이며 commissionedAt은 RFC3339 설비 준공(계통 연계) 시각이다.
+func (c *MeteringContract) RegisterDevice(ctx contractapi.TransactionContextInterface, deviceID string, userID string, keyType string, publicKey string, meterType string, capacityKW float64, location string, energySource string, commissionedAt string) error {
+	if deviceID == "" || userID == "" {
+		return fmt.Errorf("디바이스 ID와 사용자 ID는 필수입니다")
+	}
+	if _, err := requireOwnerOrAdmin(ctx, userID); err != nil {
+		return err
+	}
+	if !common.Contains([]string{MeterTypeProduction, MeterTypeConsumption, MeterTypeBidirectional}, meterType) {
+		return fmt.Errorf("알 수 없는 계량기 종류입니다: %s (PRODUCTION, CONSUMPTION, BIDIRECTIONAL)", meterType)
+	}
+	if math.IsNaN(capacityKW) || math.IsInf(capacityKW, 0) || capacityKW <= 0 {
+		return fmt.Errorf("설비 용량(kW)은 0보다 커야 합니다: %v", capacityKW)
+	}
+	if meterType != MeterTypeConsumption && energySource == "" {
+		return fmt.Errorf("발전 계량기는 에너지원이 필요합니다: %s", deviceID)
+	}
+	commissioned, err := common.ParseTime(commissionedAt)
+	if err != nil {
+		return err
+	}
+
+	exists, err := common.Exists(ctx, deviceKey(deviceID))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("디바이스가 이미 존재합니다: %s", deviceID)
+	}
+
+	if _, err := parseDeviceKey(keyType, publicKey); err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	device := Device{
+		DeviceID:       deviceID,
+		OwnerID:        userID,
+		KeyType:        keyType,
+		PublicKey:      publicKey,
+		MeterType:      meterType,
+		CapacityKW:     capacityKW,
+		Location:       location,
+		EnergySource:   energySource,
+		CommissionedAt: common.FormatTime(commissioned),
+		Status:         DeviceActive,
+		RegisteredAt:   now,
+		UpdatedAt:      now,
+	}
+
+	if err := common.PutState(ctx, deviceKey(deviceID), device, "디바이스"); err != nil {
+		return err
+	}
+	if err := putDeviceOwnerIndex(ctx, userID, deviceID); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "DeviceRegistered", device)
+}
+
+// GetDevice - 디바이스 조회
+func (c *MeteringContract) GetDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*Device, error) {
+	return common.MustGetState[Device](ctx, deviceKey(deviceID), "디바이스", deviceID)
+}
+
+// RevokeDevice - 디바이스 키 폐기 (소유자 본인 또는 관리자)
+//
+// 폐기 이후 해당 디바이스의 측정값은 기록할 수 없으며, 이미 기록된 측정값은 유지된다.
+func (c *MeteringContract) RevokeDevice(ctx contractapi.TransactionContextInterface, deviceID string, reason string) error {
+	device, err := c.GetDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if _, err := requireOwnerOrAdmin(ctx, device.OwnerID); err != nil {
+		return err
+	}
+	if device.Status != DeviceActive {
+		return fmt.Errorf("활성 상태의 디바이스만 폐기할 수 있습니다: %s (%s)", deviceID, device.Status)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.Status = DeviceRevoked
+	device.RevokeReason = reason
+	device.UpdatedAt = now
+
+	if err := common.PutState(ctx, deviceKey(deviceID), device, "디바이스"); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "DeviceRevoked", device)
+}
+
+// TransferDevice - 디바이스 소유권 이전 (현재 소유자 또는 관리자)
+//
+// 이전 이후의 측정값은 새 소유자 명의로만 기록할 수 있다. 이미 기록된 측정값의 소유자는 바뀌지 않는다.
+func (c *MeteringContract) TransferDevice(ctx contractapi.TransactionContextInterface, deviceID string, newOwnerID string) error {
+	device, err := c.GetDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	caller, err := requireOwnerOrAdmin(ctx, device.OwnerID)
+	if err != nil {
+		return err
+	}
+	if newOwnerID == "" || newOwnerID == device.OwnerID {
+		return fmt.Errorf("새 소유자가 올바르지 않습니다: %q", newOwnerID)
+	}
+	if device.Status == DeviceDecommissioned {
+		return fmt.Errorf("철거된 디바이스는 이전할 수 없습니다: %s", deviceID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	event := DeviceTransferredEvent{
+		DeviceID:      deviceID,
+		FromOwnerID:   device.OwnerID,
+		ToOwnerID:     newOwnerID,
+		TransferredBy: caller.ID,
+		TransferredAt: now,
+	}
+
+	if err := deleteDeviceOwnerIndex(ctx, device.OwnerID, deviceID); err != nil {
+		return err
+	}
+	if err := putDeviceOwnerIndex(ctx, newOwnerID, deviceID); err != nil {
+		return err
+	}
+
+	device.OwnerID = newOwnerID
+	device.TransferredAt = now
+	device.UpdatedAt = now
+	if err := common.PutState(ctx, deviceKey(deviceID), device, "디바이스"); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "DeviceTransferred", event)
+}
+
+// DecommissionDevice - 디바이스 철거 처리 (소유자 본인 또는 관리자)
+//
+// 철거는 종료 상태이며 이후 측정값 기록, 이전, 폐기를 할 수 없다.
+func (c *MeteringContract) DecommissionDevice(ctx contractapi.TransactionContextInterface, deviceID string) error {
+	device, err := c.GetDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if _, err := requireOwnerOrAdmin(ctx, device.OwnerID); err != nil {
+		return err
+	}
+	if device.Status == DeviceDecommissioned {
+		return fmt.Errorf("이미 철거된 디바이스입니다: %s", deviceID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.Status = DeviceDecommissioned
+	device.DecommissionedAt = now
+	device.UpdatedAt = now
+
+	if err := common.PutState(ctx, deviceKey(deviceID), device, "디바이스"); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "DeviceDecommissioned", device)
+}
+
+// GetDevicesByUser - 사용자가 소유한 디바이스 목록 (철거된 디바이스 포함)
+func (c *MeteringContract) GetDevicesByUser(ctx contractapi.TransactionContextInterface, userID string) ([]Device, error) {
+	devices := []Device{}
+	err := common.ForEachByPartialKey(ctx, deviceOwnerIndex, []string{userID}, func(key string, value []byte) error {
+		device, err := c.GetDevice(ctx, string(value))
+		if err != nil {
+			return err
+		}
+		devices = append(devices, *device)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+func deviceKey(deviceID string) string {
+	return "DEVICE_" + deviceID
+}
+
+func putDeviceOwnerIndex(ctx contractapi.TransactionContextInterface, ownerID string, deviceID string) error {
+	indexKey, err := common.CompositeKey(ctx, deviceOwnerIndex, ownerID, deviceID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(deviceID)); err != nil {
+		return common.Failed("디바이스 인덱스 저장", err)
+	}
+	return nil
+}
+
+func deleteDeviceOwnerIndex(ctx contractapi.TransactionContextInterface, ownerID string, deviceID string) error {
+	indexKey, err := common.CompositeKey(ctx, deviceOwnerIndex, ownerID, deviceID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(indexKey); err != nil {
+		return common.Failed("디바이스 인덱스 삭제", err)
+	}
+	return nil
+}
+
+// verifyReading - userID 소유의 활성 디바이스가 서명한 측정값인지 검증하고 디바이스 반환
+func (c *MeteringContract) verifyReading(ctx contractapi.TransactionContextInterface, record *MeterRecord) (*Device, error) {
+	device, err := common.GetState[Device](ctx, deviceKey(record.DeviceID), "디바이스")
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, fmt.Errorf("등록되지 않은 디바이스입니다: %s", record.DeviceID)
+	}
+	if device.Status != DeviceActive {
+		return nil, fmt.Errorf("사용할 수 없는 디바이스입니다: %s (%s)", record.DeviceID, device.Status)
+	}
+	if device.OwnerID != record.UserID {
+		return nil, fmt.Errorf("디바이스 소유자 불일치: %s 의 소유자는 %s 입니다 (요청: %s)", record.DeviceID, device.OwnerID, record.UserID)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(record.Signature)
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("디바이스 서명 형식이 올바르지 않습니다 (base64 필요): %s", record.RecordID)
+	}
+
+	publicKey, err := parseDeviceKey(device.KeyType, device.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := canonicalReading(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var valid bool
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, message, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	}
+	if !valid {
+		return nil, fmt.Errorf("디바이스 서명 검증 실패: %s (디바이스: %s)", record.RecordID, record.DeviceID)
+	}
+
+	return device, nil
+}
+
+// canonicalReading - 디바이스 서명 대상 정규 인코딩
+//
+// ["ETP_METER_V1", recordId, userId, deviceId, production, consumption, source, timestamp, hash] 의
+// JSON 배열이며 측정값은 지수 표기 없는 최단 십진 문자열(strconv 'f', -1)이다.
+func canonicalReading(record *MeterRecord) ([]byte, error) {
+	message, err := json.Marshal([]string{
+		meterSignatureVersion,
+		record.RecordID,
+		record.UserID,
+		record.DeviceID,
+		strconv.FormatFloat(record.Production, 'f', -1, 64),
+		strconv.FormatFloat(record.Consumption, 'f', -1, 64),
+		record.Source,
+		record.Timestamp,
+		record.Hash,
+	})
+	if err != nil {
+		return nil, common.Failed("서명 대상 직렬화", err)
+	}
+	return message, nil
+}
+
+// parseDeviceKey - PKIX 공개키(PEM 또는 base64 DER)를 파싱하고 키 종류 확인
+func parseDeviceKey(keyType string, publicKey string) (interface{}, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(publicKey)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("공개키 형식이 올바르지 않습니다 (PEM 또는 base64 DER 필요)")
+		}
+		der = decoded
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("공개키 파싱 실패: %v", err)
+	}
+
+	switch keyType {
+	case KeyTypeEd25519:
+		if _, ok := key.(ed25519.PublicKey); ok {
+			return key, nil
+		}
+	case KeyTypeECDSA:
+		if ecKey, ok := key.(*ecdsa.PublicKey); ok && ecKey.Curve.Params().Name == "P-256" {
+			return key, nil
+		}
+	default:
+		return nil, fmt.Errorf("지원하지 않는 키 종류입니다: %s (ED25519, ECDSA)", keyType)
+	}
+
+	return nil, fmt.Errorf("공개키가 키 종류 %s 와 일치하지 않습니다", keyType)
+}
@@ -3,17 +3,24 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"time"
+	"strings"
 
+	"github.com/etp/chaincode/common"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// meterIndex - 측정값 비공개 키(common.PrivateKey) objectType: METER~{userID}~{deviceID}~{측정 시각}
+const meterIndex = "METER"
+
+// meterTimeLayout - 측정 시각 키 형식 (고정 길이 UTC, 사전순 = 시간순)
+const meterTimeLayout = "2006-01-02T15:04:05.000Z"
+
 // MeteringContract - 전력 미터링 스마트 컨트랙트
 type MeteringContract struct {
 	contractapi.Contract
 }
 
-// MeterRecord - 미터링 기록
+// MeterRecord - 미터링 기록 (비공개 컬렉션에 저장, 공개 상태에는 MeterCommitment만 기록)
 type MeterRecord struct {
 	RecordID    string  `json:"recordId"`
 	UserID      string  `json:"userId"`
@@ -23,84 +30,331 @@ type MeterRecord struct {
 	Source      string  `json:"source"`
 	Timestamp   string  `json:"timestamp"`
 	RecordedAt  string  `json:"recordedAt"`
-	Hash        string  `json:"hash"` // 데이터 무결성 해시
+	Hash        string  `json:"hash"`                                     // 데이터 무결성 해시
+	Signature   string  `json:"signature,omitempty" metadata:",optional"` // 디바이스 서명 (base64)
+	Salt        string  `json:"salt"`                                     // 공개 약정 해시용 솔트 (클라이언트 생성)
+	// CorrectMeterRecord로 정정된 경우에만 기록
+	Revision         int           `json:"revision,omitempty" metadata:",optional"`
+	Original         *MeterReading `json:"original,omitempty" metadata:",optional"` // 최초 기록된 측정값
+	CorrectedBy      string        `json:"correctedBy,omitempty" metadata:",optional"`
+	CorrectedAt      string        `json:"correctedAt,omitempty" metadata:",optional"`
+	CorrectionReason string        `json:"correctionReason,omitempty" metadata:",optional"`
+	// 검증 규칙 결과 (비어 있으면 검증 도입 이전 기록으로 VALID와 같이 취급)
+	Quality      string   `json:"quality,omitempty" metadata:",optional"` // VALID, SUSPECT, INVALID
+	AnomalyFlags []string `json:"anomalyFlags,omitempty" metadata:",optional"`
+	ReviewedBy   string   `json:"reviewedBy,omitempty" metadata:",optional"`
+	ReviewedAt   string   `json:"reviewedAt,omitempty" metadata:",optional"`
+	ReviewNote   string   `json:"reviewNote,omitempty" metadata:",optional"`
+	// 발전 보상 청구 결과 (ClaimGeneration)
+	ClaimStatus string `json:"claimStatus,omitempty" metadata:",optional"` // CLAIMED
+	ClaimRef    string `json:"claimRef,omitempty" metadata:",optional"`    // 보상 발행 트랜잭션 ID
+	ClaimedAt   string `json:"claimedAt,omitempty" metadata:",optional"`
+	// 이 측정값의 발전량으로 발행된 REC 토큰 (ClaimRECGeneration)
+	RECTokenIDs []string `json:"recTokenIds,omitempty" metadata:",optional"`
+}
+
+// MeterReading - 정정 대상 측정값
+type MeterReading struct {
+	Production  float64 `json:"production"`
+	Consumption float64 `json:"consumption"`
+	Hash        string  `json:"hash"`
+}
+
+// MeterCorrectedEvent - 미터링 기록 정정 이벤트 (이벤트는 블록에 남으므로 측정값은 포함하지 않음)
+type MeterCorrectedEvent struct {
+	RecordID    string `json:"recordId"`
+	Revision    int    `json:"revision"`
+	Reason      string `json:"reason"`
+	CorrectedBy string `json:"correctedBy"`
+	CorrectedAt string `json:"correctedAt"`
+}
+
+// MeterRecordPage - 미터링 기록 페이지 조회 결과
+type MeterRecordPage struct {
+	Records      []MeterRecord `json:"records"`
+	Bookmark     string        `json:"bookmark"`
+	FetchedCount int32         `json:"fetchedCount"`
 }
 
 // RecordMeter - 미터링 데이터 기록
-func (c *MeteringContract) RecordMeter(ctx contractapi.TransactionContextInterface, recordID string, userID string, deviceID string, production float64, consumption float64, source string, timestamp string, dataHash string) error {
-	now := time.Now().UTC().Format(time.RFC3339)
+//
+// 측정값은 인자 대신 트랜지언트 맵의 "meter" 키에 MeterRecord JSON(recordId ~ signature와 salt)으로 전달한다.
+// 기록 전체는 비공개 컬렉션에 저장하고 공개 상태에는 솔트 해시(MeterCommitment)만 남긴다.
+//
+// 같은 recordID로 같은 데이터를 다시 기록하면(IoT 업로드 재시도) 아무것도 변경하지 않고 성공하며,
+// 다른 데이터이거나 같은 사용자·디바이스·시각(밀리초 단위)에 다른 기록이 있으면 충돌 오류를 반환한다.
+// 값을 바로잡으려면 CorrectMeterRecord를 사용한다.
+//
+// signature는 등록된 활성 디바이스 키로 canonicalReading에 서명한 값(base64)이며 저장 전에 검증한다.
+// 이후 PlausibilityRules로 값을 검사해 거부하거나 SUSPECT로 표시하며, VALID 측정값만
+// 발전 보상(ClaimGeneration)과 REC 발행(ClaimRECGeneration)에 사용할 수 있다.
+func (c *MeteringContract) RecordMeter(ctx contractapi.TransactionContextInterface) error {
+	input, err := common.GetTransient[MeterRecord](ctx, meterTransientKey, "측정값")
+	if err != nil {
+		return err
+	}
+	if input.RecordID == "" {
+		return fmt.Errorf("측정값 ID가 비어 있습니다")
+	}
+	if strings.ContainsRune(input.RecordID, 0) {
+		// 비공개 컬렉션의 인덱스 키(common.PrivateKey)와 겹치지 않도록 \x00을 허용하지 않는다
+		return fmt.Errorf("측정값 ID에 사용할 수 없는 문자가 포함되어 있습니다: %q", input.RecordID)
+	}
+	if err := common.CheckSalt(input.Salt); err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
 
 	record := MeterRecord{
-		RecordID:    recordID,
-		UserID:      userID,
-		DeviceID:    deviceID,
-		Production:  production,
-		Consumption: consumption,
-		Source:      source,
-		Timestamp:   timestamp,
+		RecordID:    input.RecordID,
+		UserID:      input.UserID,
+		DeviceID:    input.DeviceID,
+		Production:  input.Production,
+		Consumption: input.Consumption,
+		Source:      input.Source,
+		Timestamp:   input.Timestamp,
 		RecordedAt:  now,
-		Hash:        dataHash,
+		Hash:        input.Hash,
+		Signature:   input.Signature,
+		Salt:        input.Salt,
 	}
 
-	recordJSON, err := json.Marshal(record)
+	device, err := c.verifyReading(ctx, &record)
 	if err != nil {
-		return fmt.Errorf("미터링 직렬화 실패: %v", err)
+		return err
 	}
 
-	// 복합키: METER_{UserID}_{Timestamp}
-	compositeKey := fmt.Sprintf("METER_%s_%s", userID, timestamp)
-	if err := ctx.GetStub().PutState(compositeKey, recordJSON); err != nil {
-		return fmt.Errorf("미터링 저장 실패: %v", err)
+	existing, err := common.GetPrivateState[MeterRecord](ctx, meterCollection, record.RecordID, "미터링")
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.sameReading(&record) {
+			return nil
+		}
+		return fmt.Errorf("미터링 기록 충돌: %s 가 다른 데이터로 이미 기록되어 있습니다", record.RecordID)
 	}
 
-	// recordID로도 조회 가능하도록 인덱스 저장
-	return ctx.GetStub().PutState(recordID, recordJSON)
+	compositeKey, err := meterKey(ctx, &record)
+	if err != nil {
+		return err
+	}
+	byTime, err := common.GetPrivateState[MeterRecord](ctx, meterCollection, compositeKey, "미터링")
+	if err != nil {
+		return err
+	}
+	if byTime != nil {
+		return fmt.Errorf("미터링 기록 충돌: %s/%s 의 %s 측정값이 이미 기록되어 있습니다 (기록: %s)", record.UserID, record.DeviceID, record.Timestamp, byTime.RecordID)
+	}
+
+	rules, err := c.GetPlausibilityRules(ctx)
+	if err != nil {
+		return err
+	}
+	txTime, err := common.TxTime(ctx)
+	if err != nil {
+		return err
+	}
+	flags, err := checkPlausibility(rules, &record, device, txTime)
+	if err != nil {
+		return err
+	}
+
+	record.Quality = QualityValid
+	if len(flags) > 0 {
+		record.Quality = QualitySuspect
+		record.AnomalyFlags = flags
+		if err := putAnomalyIndex(ctx, &record); err != nil {
+			return err
+		}
+	}
+
+	if err := saveMeterRecord(ctx, &record, now); err != nil {
+		return err
+	}
+
+	ts, err := common.ParseTime(record.Timestamp)
+	if err != nil {
+		return err
+	}
+	device.LastReadingAt = ts.Format(meterTimeLayout)
+	if err := common.PutState(ctx, deviceKey(device.DeviceID), device, "디바이스"); err != nil {
+		return err
+	}
+
+	return updateAggregates(ctx, &record, record.Production, record.Consumption, 1, now)
+}
+
+// CorrectMeterRecord - 미터링 기록 정정 (관리자 전용)
+//
+// 정정값은 트랜지언트 맵의 "correction" 키에 MeterReading JSON으로 전달한다.
+// 최초 측정값은 Original에 보존하고 정정자와 사유를 함께 기록한다. 집계에는 증감분만 반영하며
+// INVALID로 확정된 측정값은 집계에서 이미 제외되어 있으므로 반영하지 않는다.
+// 보상이 청구된(CLAIMED) 측정값과 REC 발행에 사용된 측정값은 발행된 자산과 어긋나므로 정정할 수 없다.
+func (c *MeteringContract) CorrectMeterRecord(ctx contractapi.TransactionContextInterface, recordID string, reason string) error {
+	caller, err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("정정 사유가 비어 있습니다")
+	}
+	corrected, err := common.GetTransient[MeterReading](ctx, correctionTransientKey, "정정값")
+	if err != nil {
+		return err
+	}
+
+	record, err := getMeterRecord(ctx, recordID)
+	if err != nil {
+		return err
+	}
+	if err := checkCorrectable(ctx, record); err != nil {
+		return err
+	}
+
+	previous := record.reading()
+	if previous == *corrected {
+		return fmt.Errorf("정정할 내용이 없습니다: %s", recordID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if record.Original == nil {
+		original := previous
+		record.Original = &original
+	}
+	record.Production = corrected.Production
+	record.Consumption = corrected.Consumption
+	record.Hash = corrected.Hash
+	record.Revision++
+	record.CorrectedBy = caller.ID
+	record.CorrectedAt = now
+	record.CorrectionReason = reason
+
+	if err := saveMeterRecord(ctx, record, now); err != nil {
+		return err
+	}
+	if record.Quality != QualityInvalid {
+		if err := updateAggregates(ctx, record, corrected.Production-previous.Production, corrected.Consumption-previous.Consumption, 0, now); err != nil {
+			return err
+		}
+	}
+	return common.SetEvent(ctx, "MeterRecordCorrected", MeterCorrectedEvent{
+		RecordID:    recordID,
+		Revision:    record.Revision,
+		Reason:      reason,
+		CorrectedBy: caller.ID,
+		CorrectedAt: now,
+	})
 }
 
-// GetMeterRecord - 미터링 기록 조회
+// GetMeterRecord - 미터링 기록 조회 (본인 또는 관리자)
 func (c *MeteringContract) GetMeterRecord(ctx contractapi.TransactionContextInterface, recordID string) (*MeterRecord, error) {
-	recordJSON, err := ctx.GetStub().GetState(recordID)
+	record, err := getMeterRecord(ctx, recordID)
 	if err != nil {
-		return nil, fmt.Errorf("미터링 조회 실패: %v", err)
+		return nil, err
 	}
-	if recordJSON == nil {
-		return nil, fmt.Errorf("미터링 기록을 찾을 수 없습니다: %s", recordID)
+	if _, err := requireOwnerOrAdmin(ctx, record.UserID); err != nil {
+		return nil, err
 	}
 
-	var record MeterRecord
-	if err := json.Unmarshal(recordJSON, &record); err != nil {
-		return nil, fmt.Errorf("미터링 역직렬화 실패: %v", err)
+	return record, nil
+}
+
+// GetMeterHistory - 측정값 공개 약정의 변경 이력 조회
+//
+// 비공개 데이터는 이력 조회를 지원하지 않으므로 기록·정정·검토 시점별 약정만 반환한다.
+func (c *MeteringContract) GetMeterHistory(ctx contractapi.TransactionContextInterface, recordID string) ([]MeterCommitment, error) {
+	return common.GetHistory[MeterCommitment](ctx, recordID, "측정값 약정")
+}
+
+// GetMeterHistoryWithPagination - 측정값 공개 약정 이력 조회 (북마크 기반 페이지 조회)
+func (c *MeteringContract) GetMeterHistoryWithPagination(ctx contractapi.TransactionContextInterface, recordID string, pageSize int32, bookmark string) (*MeterCommitmentPage, error) {
+	page, err := common.GetHistoryPage[MeterCommitment](ctx, recordID, pageSize, bookmark, "측정값 약정")
+	if err != nil {
+		return nil, err
 	}
 
-	return &record, nil
+	return &MeterCommitmentPage{Records: page.Records, Bookmark: page.Bookmark, FetchedCount: page.FetchedCount}, nil
 }
 
-// GetMeterHistory - 디바이스 미터링 이력 조회
-func (c *MeteringContract) GetMeterHistory(ctx contractapi.TransactionContextInterface, recordID string) ([]MeterRecord, error) {
-	historyIterator, err := ctx.GetStub().GetHistoryForKey(recordID)
+// GetMeterReadingsByRange - 측정 시각이 [from, to) 구간인 사용자·디바이스 측정값을 시간순으로 페이지 조회
+//
+// 첫 페이지는 bookmark를 비워 두고, 이후에는 직전 결과의 bookmark를 전달한다.
+// bookmark가 비어 있으면 마지막 페이지다. 본인 또는 관리자만 조회할 수 있다.
+func (c *MeteringContract) GetMeterReadingsByRange(ctx contractapi.TransactionContextInterface, userID string, deviceID string, from string, to string, pageSize int32, bookmark string) (*MeterRecordPage, error) {
+	if userID == "" || deviceID == "" {
+		return nil, fmt.Errorf("사용자 ID와 디바이스 ID는 필수입니다")
+	}
+	if _, err := requireOwnerOrAdmin(ctx, userID); err != nil {
+		return nil, err
+	}
+	start, err := common.ParseTime(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := common.ParseTime(to)
 	if err != nil {
-		return nil, fmt.Errorf("미터링 이력 조회 실패: %v", err)
+		return nil, err
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("조회 구간이 올바르지 않습니다: %s ~ %s", from, to)
+	}
+	endKey := end.Format(meterTimeLayout)
+
+	if bookmark == "" {
+		// 페이지 조회의 북마크는 시작 키이므로 from 위치부터 읽는다
+		if bookmark, err = common.PrivateKey(ctx, meterIndex, userID, deviceID, start.Format(meterTimeLayout)); err != nil {
+			return nil, err
+		}
+	} else if err := checkMeterBookmark(ctx, bookmark, userID, deviceID); err != nil {
+		return nil, err
 	}
-	defer historyIterator.Close()
 
-	var records []MeterRecord
-	for historyIterator.HasNext() {
-		modification, err := historyIterator.Next()
+	page := &MeterRecordPage{Records: []MeterRecord{}}
+	done := false
+	next, _, err := common.ForEachPrivateByPartialKeyPage(ctx, meterCollection, meterIndex, []string{userID, deviceID}, pageSize, bookmark, func(key string, value []byte) error {
+		_, attributes, err := common.SplitPrivateKey(ctx, key)
 		if err != nil {
-			return nil, fmt.Errorf("이력 반복 실패: %v", err)
+			return err
+		}
+		if len(attributes) != 3 || attributes[2] >= endKey {
+			done = true
+			return common.ErrStop
 		}
 
 		var record MeterRecord
-		if err := json.Unmarshal(modification.Value, &record); err != nil {
-			continue
+		if err := json.Unmarshal(value, &record); err != nil {
+			return common.Failed("미터링 역직렬화", err)
+		}
+		page.Records = append(page.Records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !done && next != "" {
+		// 다음 페이지 시작 키가 이미 구간을 벗어났으면 마지막 페이지로 처리
+		if _, attributes, err := common.SplitPrivateKey(ctx, next); err == nil && len(attributes) == 3 && attributes[2] < endKey {
+			page.Bookmark = next
 		}
-		records = append(records, record)
 	}
+	page.FetchedCount = int32(len(page.Records))
 
-	return records, nil
+	return page, nil
 }
 
-// VerifyMeterData - 미터링 데이터 무결성 검증
+// VerifyMeterData - 미터링 데이터 무결성 검증 (본인 또는 관리자)
+//
+// 비공개 데이터가 없는 조직은 VerifyMeterDisclosure로 공개받은 기록을 검증한다.
 func (c *MeteringContract) VerifyMeterData(ctx contractapi.TransactionContextInterface, recordID string, expectedHash string) (bool, error) {
 	record, err := c.GetMeterRecord(ctx, recordID)
 	if err != nil {
@@ -110,6 +364,69 @@ func (c *MeteringContract) VerifyMeterData(ctx contractapi.TransactionContextInt
 	return record.Hash == expectedHash, nil
 }
 
+// ========== 내부 헬퍼 ==========
+
+// meterKey - 비공개 키: METER~{UserID}~{DeviceID}~{측정 시각}
+func meterKey(ctx contractapi.TransactionContextInterface, record *MeterRecord) (string, error) {
+	ts, err := common.ParseTime(record.Timestamp)
+	if err != nil {
+		return "", err
+	}
+
+	return common.PrivateKey(ctx, meterIndex, record.UserID, record.DeviceID, ts.Format(meterTimeLayout))
+}
+
+// getMeterRecord - 비공개 컬렉션의 미터링 기록 조회 (권한 검사 없음)
+func getMeterRecord(ctx contractapi.TransactionContextInterface, recordID string) (*MeterRecord, error) {
+	return common.MustGetPrivateState[MeterRecord](ctx, meterCollection, recordID, "미터링 기록", recordID)
+}
+
+// saveMeterRecord - 비공개 컬렉션의 시각 키와 recordID 키에 함께 저장하고 공개 약정 갱신
+func saveMeterRecord(ctx contractapi.TransactionContextInterface, record *MeterRecord, now string) error {
+	key, err := meterKey(ctx, record)
+	if err != nil {
+		return err
+	}
+	if err := common.PutPrivateState(ctx, meterCollection, key, record, "미터링"); err != nil {
+		return err
+	}
+
+	// recordID로도 조회 가능하도록 인덱스 저장
+	if err := common.PutPrivateState(ctx, meterCollection, record.RecordID, record, "미터링"); err != nil {
+		return err
+	}
+
+	return putMeterCommitment(ctx, record, now)
+}
+
+// checkMeterBookmark - 북마크가 같은 사용자·디바이스의 측정값 키인지 확인
+func checkMeterBookmark(ctx contractapi.TransactionContextInterface, bookmark string, userID string, deviceID string) error {
+	objectType, attributes, err := common.SplitPrivateKey(ctx, bookmark)
+	if err != nil || objectType != meterIndex || len(attributes) != 3 || attributes[0] != userID || attributes[1] != deviceID {
+		return fmt.Errorf("잘못된 북마크입니다")
+	}
+	return nil
+}
+
+// reading - 현재 측정값
+func (r *MeterRecord) reading() MeterReading {
+	return MeterReading{Production: r.Production, Consumption: r.Consumption, Hash: r.Hash}
+}
+
+// sameReading - 재전송된 기록이 최초 기록과 같은지 비교 (정정 이후의 재시도도 중복으로 인정)
+func (r *MeterRecord) sameReading(other *MeterRecord) bool {
+	original := r.reading()
+	if r.Original != nil {
+		original = *r.Original
+	}
+
+	return r.UserID == other.UserID &&
+		r.DeviceID == other.DeviceID &&
+		r.Source == other.Source &&
+		r.Timestamp == other.Timestamp &&
+		original == other.reading()
+}
+
 func main() {
 	chaincode, err := contractapi.NewChaincode(&MeteringContract{})
 	if err != nil {
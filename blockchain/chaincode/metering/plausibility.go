@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const plausibilityRulesKey = "PLAUSIBILITY_RULES"
+
+// 측정값 품질 상태
+const (
+	QualityValid   = "VALID"
+	QualitySuspect = "SUSPECT" // 검증 규칙 위반 의심, 관리자 검토 대기
+	QualityInvalid = "INVALID" // 관리자가 이상으로 확정, 집계에서 제외
+)
+
+// 이상 플래그
+const (
+	FlagExceedsCapacity  = "EXCEEDS_CAPACITY"   // 설비 용량 × 측정 간격 초과
+	FlagIntervalTooShort = "INTERVAL_TOO_SHORT" // 직전 측정과의 간격이 최소 간격 미만
+	FlagIntervalTooLong  = "INTERVAL_TOO_LONG"  // 직전 측정과의 간격이 최대 간격 초과 (데이터 누락)
+)
+
+// 검토 결정
+const (
+	ReviewClear   = "CLEAR"   // 정상으로 판정
+	ReviewConfirm = "CONFIRM" // 이상으로 확정
+)
+
+// anomalyIndex - SUSPECT 측정값 인덱스 (비공개 컬렉션): METER_SUSPECT~{userID}~{recordID}
+const anomalyIndex = "METER_SUSPECT"
+
+// PlausibilityRules - 측정값 검증 규칙
+//
+// 측정값(production/consumption)은 직전 측정 이후 구간의 kWh로 간주한다.
+// 음수, 미래 시각, 디바이스별 비단조 시각, 준공 이전 시각은 거부하고
+// 용량·간격 규칙 위반은 SUSPECT로 기록한다.
+type PlausibilityRules struct {
+	MaxFutureSkewSeconds int64   `json:"maxFutureSkewSeconds"` // 트랜잭션 시각 대비 허용 미래 오차
+	MinIntervalSeconds   int64   `json:"minIntervalSeconds"`
+	MaxIntervalSeconds   int64   `json:"maxIntervalSeconds"` // 첫 측정의 용량 검사 구간으로도 사용
+	CapacityTolerance    float64 `json:"capacityTolerance"`  // 1.1 = 용량의 110%까지 허용
+	UpdatedAt            string  `json:"updatedAt"`
+	UpdatedBy            string  `json:"updatedBy"`
+}
+
+// MeterReviewedEvent - 이상 측정값 검토 이벤트 (사용자·디바이스는 포함하지 않음)
+type MeterReviewedEvent struct {
+	RecordID     string   `json:"recordId"`
+	Decision     string   `json:"decision"`
+	Quality      string   `json:"quality"`
+	AnomalyFlags []string `json:"anomalyFlags"`
+	Note         string   `json:"note"`
+	ReviewedBy   string   `json:"reviewedBy"`
+	ReviewedAt   string   `json:"reviewedAt"`
+}
+
+func defaultPlausibilityRules() *PlausibilityRules {
+	return &PlausibilityRules{
+		MaxFutureSkewSeconds: 300,
+		MinIntervalSeconds:   60,
+		MaxIntervalSeconds:   3600,
+		CapacityTolerance:    1.1,
+	}
+}
+
+// GetPlausibilityRules - 현재 측정값 검증 규칙 조회
+func (c *MeteringContract) GetPlausibilityRules(ctx contractapi.TransactionContextInterface) (*PlausibilityRules, error) {
+	rules, err := common.GetState[PlausibilityRules](ctx, plausibilityRulesKey, "검증 규칙")
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		return defaultPlausibilityRules(), nil
+	}
+
+	return rules, nil
+}
+
+// SetPlausibilityRules - 측정값 검증 규칙 변경 (관리자 전용)
+func (c *MeteringContract) SetPlausibilityRules(ctx contractapi.TransactionContextInterface, rulesJSON string) error {
+	caller, err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rules PlausibilityRules
+	if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+		return fmt.Errorf("검증 규칙 역직렬화 실패: %v", err)
+	}
+	if rules.MaxFutureSkewSeconds < 0 || rules.MinIntervalSeconds < 0 {
+		return fmt.Errorf("허용 오차와 최소 간격은 0 이상이어야 합니다")
+	}
+	if rules.MaxIntervalSeconds <= rules.MinIntervalSeconds {
+		return fmt.Errorf("최대 간격은 최소 간격보다 커야 합니다: %d <= %d", rules.MaxIntervalSeconds, rules.MinIntervalSeconds)
+	}
+	if math.IsNaN(rules.CapacityTolerance) || rules.CapacityTolerance < 1 {
+		return fmt.Errorf("용량 허용 배율은 1 이상이어야 합니다: %v", rules.CapacityTolerance)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	rules.UpdatedAt = now
+	rules.UpdatedBy = caller.ID
+
+	return common.PutState(ctx, plausibilityRulesKey, rules, "검증 규칙")
+}
+
+// GetAnomalies - 검토 대기(SUSPECT) 측정값 페이지 조회
+//
+// userID가 비어 있으면 전체 사용자를 조회하며 관리자만 가능하다. 사용자별 조회는 본인도 가능하다.
+func (c *MeteringContract) GetAnomalies(ctx contractapi.TransactionContextInterface, userID string, pageSize int32, bookmark string) (*MeterRecordPage, error) {
+	attributes := []string{}
+	if userID == "" {
+		if _, err := requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := requireOwnerOrAdmin(ctx, userID); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, userID)
+	}
+
+	page := &MeterRecordPage{Records: []MeterRecord{}}
+	next, fetched, err := common.ForEachPrivateByPartialKeyPage(ctx, meterCollection, anomalyIndex, attributes, pageSize, bookmark, func(key string, value []byte) error {
+		record, err := getMeterRecord(ctx, string(value))
+		if err != nil {
+			return err
+		}
+		page.Records = append(page.Records, *record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.Bookmark = next
+	page.FetchedCount = fetched
+
+	return page, nil
+}
+
+// ReviewAnomaly - SUSPECT 측정값 검토 (관리자 전용)
+//
+// CLEAR는 정상(VALID)으로, CONFIRM은 이상(INVALID)으로 확정하며 INVALID 측정값은 집계에서 제외한다.
+// CLEAR된 측정값의 발전량은 이때부터 REC 적격 발전량에 포함된다.
+func (c *MeteringContract) ReviewAnomaly(ctx contractapi.TransactionContextInterface, recordID string, decision string, note string) error {
+	caller, err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	record, err := getMeterRecord(ctx, recordID)
+	if err != nil {
+		return err
+	}
+	if record.Quality != QualitySuspect {
+		return fmt.Errorf("검토 대기 중인 측정값이 아닙니다: %s (%s)", recordID, record.Quality)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch decision {
+	case ReviewClear:
+		record.Quality = QualityValid
+	case ReviewConfirm:
+		record.Quality = QualityInvalid
+		if err := updateAggregates(ctx, record, -record.Production, -record.Consumption, -1, now); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("알 수 없는 검토 결정입니다: %s (CLEAR, CONFIRM)", decision)
+	}
+	record.ReviewedBy = caller.ID
+	record.ReviewedAt = now
+	record.ReviewNote = note
+
+	if err := saveMeterRecord(ctx, record, now); err != nil {
+		return err
+	}
+	if err := deleteAnomalyIndex(ctx, record); err != nil {
+		return err
+	}
+	return common.SetEvent(ctx, "MeterReviewed", MeterReviewedEvent{
+		RecordID:     record.RecordID,
+		Decision:     decision,
+		Quality:      record.Quality,
+		AnomalyFlags: record.AnomalyFlags,
+		Note:         note,
+		ReviewedBy:   caller.ID,
+		ReviewedAt:   now,
+	})
+}
+
+// ========== 내부 헬퍼 ==========
+
+// checkPlausibility - 거부 사유가 있으면 오류, 의심 사유는 플래그 목록으로 반환
+func checkPlausibility(rules *PlausibilityRules, record *MeterRecord, device *Device, txTime time.Time) ([]string, error) {
+	for _, v := range []float64{record.Production, record.Consumption} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return nil, fmt.Errorf("측정값은 0 이상이어야 합니다: 발전 %v, 소비 %v", record.Production, record.Consumption)
+		}
+	}
+
+	ts, err := common.ParseTime(record.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if ts.After(txTime.Add(time.Duration(rules.MaxFutureSkewSeconds) * time.Second)) {
+		return nil, fmt.Errorf("미래 시각의 측정값입니다: %s", record.Timestamp)
+	}
+	if commissioned, err := common.ParseTime(device.CommissionedAt); err == nil && ts.Before(commissioned) {
+		return nil, fmt.Errorf("디바이스 준공(%s) 이전 시각의 측정값입니다: %s", device.CommissionedAt, record.Timestamp)
+	}
+
+	flags := []string{}
+	interval := time.Duration(rules.MaxIntervalSeconds) * time.Second
+	if device.LastReadingAt != "" {
+		last, err := common.ParseTime(device.LastReadingAt)
+		if err != nil {
+			return nil, err
+		}
+		if !ts.After(last) {
+			return nil, fmt.Errorf("디바이스 %s 의 측정 시각은 직전 측정(%s)보다 늦어야 합니다: %s", device.DeviceID, device.LastReadingAt, record.Timestamp)
+		}
+
+		interval = ts.Sub(last)
+		if interval < time.Duration(rules.MinIntervalSeconds)*time.Second {
+			flags = append(flags, FlagIntervalTooShort)
+		}
+		if interval > time.Duration(rules.MaxIntervalSeconds)*time.Second {
+			flags = append(flags, FlagIntervalTooLong)
+		}
+	}
+
+	limit := device.CapacityKW * interval.Hours() * rules.CapacityTolerance
+	if record.Production > limit || record.Consumption > limit {
+		flags = append(flags, FlagExceedsCapacity)
+	}
+
+	return flags, nil
+}
+
+// putAnomalyIndex - SUSPECT 측정값 인덱스 저장
+func putAnomalyIndex(ctx contractapi.TransactionContextInterface, record *MeterRecord) error {
+	indexKey, err := common.PrivateKey(ctx, anomalyIndex, record.UserID, record.RecordID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutPrivateData(meterCollection, indexKey, []byte(record.RecordID)); err != nil {
+		return common.Failed("이상 인덱스 저장", err)
+	}
+	return nil
+}
+
+func deleteAnomalyIndex(ctx contractapi.TransactionContextInterface, record *MeterRecord) error {
+	indexKey, err := common.PrivateKey(ctx, anomalyIndex, record.UserID, record.RecordID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelPrivateData(meterCollection, indexKey); err != nil {
+		return common.Failed("이상 인덱스 삭제", err)
+	}
+	return nil
+}
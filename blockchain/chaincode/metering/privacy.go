@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// meterCollection - 측정값·집계·이상 인덱스를 보관하는 비공개 데이터 컬렉션 (collections_config.json)
+//
+// 운영 기관 피어만 데이터를 보관하며, 읽기 권한은 체인코드에서 본인 또는 관리자로 제한한다.
+// 컬렉션 쓰기는 collections_config.json의 endorsementPolicy로 AdminOrg 피어 보증을 요구한다.
+// 다른 체인코드(epc-cc, rec-token-cc)가 같은 트랜잭션에서 호출하는 청구 함수는 컬렉션을 읽지 않고
+// 공개받은 측정값을 공개 약정(MeterCommitment)과 대조하므로 다른 조직 피어도 시뮬레이션할 수 있다.
+const meterCollection = "meterPrivateCollection"
+
+// 트랜지언트 맵 키
+const (
+	meterTransientKey      = "meter"      // RecordMeter 입력 (MeterRecord JSON + salt)
+	correctionTransientKey = "correction" // CorrectMeterRecord 입력 (MeterReading JSON)
+)
+
+// MeterCommitment - 공개 상태에 남기는 측정값 약정(commitment)
+//
+// 비공개 MeterRecord가 바뀔 때마다 Commitment를 다시 계산하므로 최신 기록만 검증된다.
+type MeterCommitment struct {
+	RecordID   string `json:"recordId"`
+	Commitment string `json:"commitment"` // SHA-256(salt || JSON(salt를 비운 MeterRecord))
+	RecordedAt string `json:"recordedAt"`
+	UpdatedAt  string `json:"updatedAt"`
+}
+
+// MeterCommitmentPage - 측정값 약정 이력 페이지 조회 결과
+type MeterCommitmentPage struct {
+	Records      []MeterCommitment `json:"records"`
+	Bookmark     string            `json:"bookmark"`
+	FetchedCount int32             `json:"fetchedCount"`
+}
+
+// VerificationResult - 공개받은 측정값 검증 결과
+type VerificationResult struct {
+	Valid    bool   `json:"valid"`
+	RecordID string `json:"recordId"`
+	Message  string `json:"message"`
+}
+
+// MeterMigrationResult - 이전 형식 공개 측정값 이관 결과
+type MeterMigrationResult struct {
+	Migrated    int  `json:"migrated"`    // 비공개 컬렉션으로 옮긴 측정값
+	Skipped     int  `json:"skipped"`     // 이미 비공개 컬렉션에 있어 공개 키만 삭제한 측정값
+	RemovedKeys int  `json:"removedKeys"` // 삭제한 공개 집계·이상 인덱스 키
+	Done        bool `json:"done"`        // 남은 이전 형식 공개 키가 없으면 true
+}
+
+// 이전 형식(비공개 컬렉션 도입 전) 공개 키
+const (
+	legacyMeterPrefix = "METER_" // METER_{userID}_{측정 시각} -> MeterRecord
+)
+
+// legacyPublicIndexes - 비공개 컬렉션 도입 전 공개 상태에 쓰던 복합키 objectType (집계·이상 인덱스)
+var legacyPublicIndexes = []string{"AGG_USER", "AGG_DEVICE", anomalyIndex}
+
+// GetMeterCommitment - 측정값의 공개 약정 조회
+func (c *MeteringContract) GetMeterCommitment(ctx contractapi.TransactionContextInterface, recordID string) (*MeterCommitment, error) {
+	return common.MustGetState[MeterCommitment](ctx, recordID, "측정값 약정", recordID)
+}
+
+// VerifyMeterDisclosure - 공개받은 측정값(GetMeterRecord 결과 JSON, salt 포함)이 원장의 약정과 일치하는지 검증
+//
+// 비공개 컬렉션 구성원이 아닌 조직도 호출할 수 있다. 인자가 블록에 남지 않도록 evaluate로 호출한다.
+func (c *MeteringContract) VerifyMeterDisclosure(ctx contractapi.TransactionContextInterface, recordJSON string) (*VerificationResult, error) {
+	var record MeterRecord
+	if err := json.Unmarshal([]byte(recordJSON), &record); err != nil {
+		return nil, fmt.Errorf("공개 측정값 역직렬화 실패: %v", err)
+	}
+
+	message, err := verifyDisclosure(ctx, &record)
+	if err != nil {
+		return nil, err
+	}
+	if message != "" {
+		return &VerificationResult{Valid: false, RecordID: record.RecordID, Message: message}, nil
+	}
+
+	return &VerificationResult{Valid: true, RecordID: record.RecordID, Message: "측정값 검증 성공"}, nil
+}
+
+// MigrateLegacyMeterRecords - 비공개 컬렉션 도입 전 공개 상태의 측정값을 비공개 컬렉션으로 이관 (관리자 전용)
+//
+// 공개 색인 키(METER_{userID}_{시각}, METER~...)의 MeterRecord를 비공개 컬렉션에 저장하고 recordID 키는
+// 공개 약정으로 덮어쓴 뒤 공개 색인을 삭제한다. 공개 집계·이상 인덱스 키도 삭제한다.
+// 솔트는 트랜지언트 맵 "migration" 키의 시드로 기록마다 만든다(common.DeriveSalt).
+// 한 번에 limit개 키까지 처리하므로 Done이 true가 될 때까지 반복 호출한다.
+// 이전 블록에 기록된 값은 지울 수 없으며 이 함수는 현재 상태(world state)에서만 제거한다.
+func (c *MeteringContract) MigrateLegacyMeterRecords(ctx contractapi.TransactionContextInterface, limit int) (*MeterMigrationResult, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("처리 한도는 1 이상이어야 합니다: %d", limit)
+	}
+
+	seed, err := common.GetMigrationSeed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MeterMigrationResult{Done: true}
+	processed := 0
+	// Fabric은 같은 트랜잭션의 쓰기를 읽지 못하므로 이번 트랜잭션에서 옮긴 기록을 따로 기억한다
+	migrated := map[string]bool{}
+
+	migrateRecord := func(key string, value []byte) error {
+		if processed == limit {
+			result.Done = false
+			return common.ErrStop
+		}
+
+		var record MeterRecord
+		if err := json.Unmarshal(value, &record); err != nil || record.RecordID == "" || record.UserID == "" {
+			// 같은 접두사를 쓰는 현재 형식 키(recordID의 공개 약정 등)는 건너뛴다
+			return nil
+		}
+		processed++
+
+		moved, err := migrateLegacyRecord(ctx, &record, seed, now, migrated)
+		if err != nil {
+			return err
+		}
+		if moved {
+			result.Migrated++
+		} else {
+			result.Skipped++
+		}
+
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("이전 측정값 키 삭제 실패: %v", err)
+		}
+		return nil
+	}
+
+	startKey, endKey := common.PrefixRange(legacyMeterPrefix)
+	if err := common.ForEachInRange(ctx, startKey, endKey, migrateRecord); err != nil {
+		return nil, err
+	}
+	if err := common.ForEachByPartialKey(ctx, meterIndex, []string{}, migrateRecord); err != nil {
+		return nil, err
+	}
+
+	for _, objectType := range legacyPublicIndexes {
+		err := common.ForEachByPartialKey(ctx, objectType, []string{}, func(key string, value []byte) error {
+			if processed == limit {
+				result.Done = false
+				return common.ErrStop
+			}
+			processed++
+
+			if err := ctx.GetStub().DelState(key); err != nil {
+				return fmt.Errorf("이전 공개 인덱스 삭제 실패: %v", err)
+			}
+			result.RemovedKeys++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// commitment - salt를 비운 기록의 솔트 해시
+func (r *MeterRecord) commitment() (string, error) {
+	unsalted := *r
+	unsalted.Salt = ""
+	return common.SaltedHash(r.Salt, unsalted)
+}
+
+// verifyDisclosure - 공개받은 측정값을 원장의 약정과 대조해 일치하지 않으면 사유 반환 (일치하면 "")
+func verifyDisclosure(ctx contractapi.TransactionContextInterface, record *MeterRecord) (string, error) {
+	commitment, err := common.GetState[MeterCommitment](ctx, record.RecordID, "측정값 약정")
+	if err != nil {
+		return "", err
+	}
+	if commitment == nil {
+		return "측정값 약정을 찾을 수 없습니다", nil
+	}
+
+	hash, err := record.commitment()
+	if err != nil {
+		return "", err
+	}
+	if hash != commitment.Commitment {
+		return "원장의 약정과 일치하지 않습니다 (변조되었거나 최신 기록이 아닙니다)", nil
+	}
+	return "", nil
+}
+
+// disclosedRecords - 공개받은 측정값 목록(GetMeterRecord 결과 JSON 배열)을 공개 약정과 대조해 recordID별로 반환
+//
+// 비공개 컬렉션을 읽지 않으므로 컬렉션 구성원이 아닌 보증 피어도 실행할 수 있다.
+func disclosedRecords(ctx contractapi.TransactionContextInterface, disclosuresJSON string) (map[string]*MeterRecord, error) {
+	var records []MeterRecord
+	if err := json.Unmarshal([]byte(disclosuresJSON), &records); err != nil {
+		return nil, fmt.Errorf("공개 측정값 목록 역직렬화 실패: %v", err)
+	}
+
+	disclosed := make(map[string]*MeterRecord, len(records))
+	for i := range records {
+		record := &records[i]
+		if _, ok := disclosed[record.RecordID]; ok {
+			return nil, fmt.Errorf("공개 측정값이 중복되었습니다: %s", record.RecordID)
+		}
+		message, err := verifyDisclosure(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		if message != "" {
+			return nil, fmt.Errorf("공개 측정값 %s 검증 실패: %s", record.RecordID, message)
+		}
+		disclosed[record.RecordID] = record
+	}
+
+	return disclosed, nil
+}
+
+// putMeterCommitment - 비공개 기록에 대응하는 공개 약정 저장
+func putMeterCommitment(ctx contractapi.TransactionContextInterface, record *MeterRecord, now string) error {
+	hash, err := record.commitment()
+	if err != nil {
+		return err
+	}
+
+	return common.PutState(ctx, record.RecordID, MeterCommitment{
+		RecordID:   record.RecordID,
+		Commitment: hash,
+		RecordedAt: record.RecordedAt,
+		UpdatedAt:  now,
+	}, "측정값 약정")
+}
+
+// migrateLegacyRecord - 이전 형식 측정값을 비공개 컬렉션에 저장하고 집계·이상 인덱스 반영
+//
+// 이미 비공개 컬렉션에 있는 기록이면 아무것도 하지 않고 false를 반환한다.
+// REC 발행은 VALID 측정값만 허용하므로 검증 규칙 도입 전 기록(품질 없음)은 REC 발행에 쓸 수 없다.
+func migrateLegacyRecord(ctx contractapi.TransactionContextInterface, record *MeterRecord, seed string, now string, migrated map[string]bool) (bool, error) {
+	if migrated[record.RecordID] {
+		return false, nil
+	}
+	existing, err := common.GetPrivateState[MeterRecord](ctx, meterCollection, record.RecordID, "미터링")
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+	migrated[record.RecordID] = true
+
+	record.Salt = common.DeriveSalt(seed, record.RecordID)
+	if record.RecordedAt == "" {
+		record.RecordedAt = now
+	}
+	if err := saveMeterRecord(ctx, record, now); err != nil {
+		return false, fmt.Errorf("측정값 %s 이관 실패: %v", record.RecordID, err)
+	}
+
+	if record.Quality == QualitySuspect {
+		if err := putAnomalyIndex(ctx, record); err != nil {
+			return false, err
+		}
+	}
+	if record.Quality != QualityInvalid {
+		if err := updateAggregates(ctx, record, record.Production, record.Consumption, 1, now); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// REC 발행 공개 키 objectType (common.CompositeKey)
+//
+// REC_USE~{recordID} -> RECGenerationUse, REC_CARRY~{userID}~{deviceID}~{발전 연도} -> RECGenerationCarry,
+// REC_CLAIM~{tokenID} -> RECGenerationClaim
+const (
+	recUseIndex   = "REC_USE"
+	recCarryIndex = "REC_CARRY"
+	recClaimIndex = "REC_CLAIM"
+)
+
+// whPerMWh - REC 1개(1 MWh)에 해당하는 발전량(Wh)
+const whPerMWh = 1_000_000
+
+// maxRECAllocations - REC 발행 한 건에 공개할 수 있는 최대 측정값 수
+const maxRECAllocations = 1000
+
+// RECGenerationUse - REC 발행에 사용된 측정값 표시 (측정값 하나는 한 번의 발행에 전부 사용된다)
+type RECGenerationUse struct {
+	RecordID string `json:"recordId"`
+	TokenID  string `json:"tokenId"`
+	UsedAt   string `json:"usedAt"`
+}
+
+// RECGenerationCarry - REC 발행 후 남은 발전량 이월분 (사용자·디바이스·발전 연도별)
+//
+// 측정값은 발행 한 번에 전부 사용하고 1 MWh 단위로 나누고 남은 발전량은 Wh 정수로 이월해 다음 발행에 먼저 쓴다.
+// 이월분은 마지막으로 사용된 측정값들에서 나온 것이므로 그 측정값 ID와 발전 기간을 함께 보관한다.
+type RECGenerationCarry struct {
+	UserID          string   `json:"userId"`
+	DeviceID        string   `json:"deviceId"`
+	Vintage         string   `json:"vintage"`
+	CarryWh         int64    `json:"carryWh"`
+	RecordIDs       []string `json:"recordIds"`
+	GenerationStart string   `json:"generationStart"`
+	GenerationEnd   string   `json:"generationEnd"`
+	UpdatedAt       string   `json:"updatedAt"`
+}
+
+// RECGenerationClaim - REC 발행용 발전량 청구 결과 (rec-token 체인코드가 토큰 출처로 기록)
+//
+// 공개 상태이므로 측정값별 발전량은 남기지 않고 근거 측정값 ID(이월분 출처 포함)와 발전 기간만 기록한다.
+type RECGenerationClaim struct {
+	TokenID         string   `json:"tokenId"`
+	UserID          string   `json:"userId"`
+	DeviceID        string   `json:"deviceId"`
+	EnergySource    string   `json:"energySource"`
+	Location        string   `json:"location"`
+	Vintage         string   `json:"vintage"`
+	QuantityMWh     int64    `json:"quantityMwh"`
+	RecordIDs       []string `json:"recordIds"`
+	GenerationStart string   `json:"generationStart"` // 근거 측정값 중 가장 이른 측정 시각
+	GenerationEnd   string   `json:"generationEnd"`   // 근거 측정값 중 가장 늦은 측정 시각
+	ClaimedAt       string   `json:"claimedAt"`
+}
+
+// recCandidate - REC 발행에 쓸 수 있는 공개 측정값
+type recCandidate struct {
+	record    *MeterRecord
+	timestamp time.Time
+	wh        int64
+}
+
+// GetRECGenerationCarry - 사용자·디바이스·발전 연도의 REC 이월 발전량 조회 (없으면 0)
+func (c *MeteringContract) GetRECGenerationCarry(ctx contractapi.TransactionContextInterface, userID string, deviceID string, vintage string) (*RECGenerationCarry, error) {
+	_, carry, err := getRECGenerationCarry(ctx, userID, deviceID, vintage)
+	return carry, err
+}
+
+// GetRECGenerationClaim - REC 토큰의 발전량 청구 내역 조회
+func (c *MeteringContract) GetRECGenerationClaim(ctx contractapi.TransactionContextInterface, tokenID string) (*RECGenerationClaim, error) {
+	claimKey, err := common.CompositeKey(ctx, recClaimIndex, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	return common.MustGetState[RECGenerationClaim](ctx, claimKey, "REC 발전량 청구", tokenID)
+}
+
+// ClaimRECGeneration - 공개받은 측정값과 이월분에서 quantityMWh 만큼 REC 발행용 발전량 청구 (관리자 전용)
+//
+// rec-token.IssueREC가 같은 트랜잭션에서 호출하며 tokenID는 발행할 REC 토큰 ID다.
+// 측정값은 disclosuresJSON(GetMeterRecord 결과 배열, salt 포함)으로 공개받아 공개 약정과 대조하므로
+// 비공개 컬렉션을 읽지 않는다. 모두 userID 소유 deviceID의 vintage(발전 연도) VALID 측정값이어야 한다.
+// 이월분을 먼저 쓰고 측정 시각순으로 필요한 만큼 측정값을 전부 사용하며, 남은 발전량은 다시 이월한다.
+// 필요하지 않은 측정값은 사용하지 않는다. 사용된 측정값에는 REC_USE 표시를 남겨 이후 정정할 수 없게 한다.
+func (c *MeteringContract) ClaimRECGeneration(ctx contractapi.TransactionContextInterface, userID string, deviceID string, vintage string, quantityMWh int64, tokenID string, disclosuresJSON string) (*RECGenerationClaim, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if userID == "" || deviceID == "" || vintage == "" || tokenID == "" {
+		return nil, fmt.Errorf("사용자 ID, 디바이스 ID, 발전 연도, 토큰 ID는 필수입니다")
+	}
+	if quantityMWh <= 0 || quantityMWh > math.MaxInt64/whPerMWh {
+		return nil, fmt.Errorf("잘못된 REC 수량: %d MWh", quantityMWh)
+	}
+
+	claimKey, err := common.CompositeKey(ctx, recClaimIndex, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := common.Exists(ctx, claimKey)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("이미 발전량이 청구된 REC 토큰입니다: %s", tokenID)
+	}
+
+	disclosed, err := disclosedRecords(ctx, disclosuresJSON)
+	if err != nil {
+		return nil, err
+	}
+	if len(disclosed) > maxRECAllocations {
+		return nil, fmt.Errorf("REC 한 건에 사용할 수 있는 측정값은 최대 %d건입니다. 수량을 줄여 나누어 발행하세요", maxRECAllocations)
+	}
+
+	candidates := make([]recCandidate, 0, len(disclosed))
+	for _, record := range disclosed {
+		candidate, err := checkRECEligible(ctx, record, userID, deviceID, vintage)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, *candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].timestamp.Equal(candidates[j].timestamp) {
+			return candidates[i].timestamp.Before(candidates[j].timestamp)
+		}
+		return candidates[i].record.RecordID < candidates[j].record.RecordID
+	})
+
+	device, err := c.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	carryKey, carry, err := getRECGenerationCarry(ctx, userID, deviceID, vintage)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := &RECGenerationClaim{
+		TokenID:      tokenID,
+		UserID:       userID,
+		DeviceID:     deviceID,
+		EnergySource: device.EnergySource,
+		Location:     device.Location,
+		Vintage:      vintage,
+		QuantityMWh:  quantityMWh,
+		RecordIDs:    []string{},
+		ClaimedAt:    now,
+	}
+	period := generationPeriod{}
+	if carry.CarryWh > 0 {
+		claim.RecordIDs = append(claim.RecordIDs, carry.RecordIDs...)
+		period.extend(carry.GenerationStart)
+		period.extend(carry.GenerationEnd)
+	}
+
+	need := quantityMWh * whPerMWh
+	available := carry.CarryWh
+	used := []recCandidate{}
+	for _, candidate := range candidates {
+		if available >= need {
+			break
+		}
+		if candidate.wh > math.MaxInt64-available {
+			return nil, fmt.Errorf("REC 적격 발전량 범위 초과: %s", candidate.record.RecordID)
+		}
+		available += candidate.wh
+		used = append(used, candidate)
+	}
+	if available < need {
+		return nil, fmt.Errorf("REC 적격 발전량 부족: 요청 %d MWh, 이월분과 공개된 측정값 합계 %d Wh", quantityMWh, available)
+	}
+
+	for _, candidate := range used {
+		recordID := candidate.record.RecordID
+		useKey, err := common.CompositeKey(ctx, recUseIndex, recordID)
+		if err != nil {
+			return nil, err
+		}
+		if err := common.PutState(ctx, useKey, RECGenerationUse{RecordID: recordID, TokenID: tokenID, UsedAt: now}, "REC 발전량 사용 표시"); err != nil {
+			return nil, err
+		}
+		claim.RecordIDs = append(claim.RecordIDs, recordID)
+		period.extend(candidate.timestamp.Format(meterTimeLayout))
+	}
+	claim.GenerationStart = period.start
+	claim.GenerationEnd = period.end
+
+	// 새 측정값을 썼다면 남은 양은 마지막 측정값 하나보다 작으므로 그 측정값이 이월분의 출처가 된다
+	carry.CarryWh = available - need
+	if len(used) > 0 {
+		last := used[len(used)-1]
+		at := last.timestamp.Format(meterTimeLayout)
+		carry.RecordIDs = []string{last.record.RecordID}
+		carry.GenerationStart = at
+		carry.GenerationEnd = at
+	}
+	if carry.CarryWh == 0 {
+		carry.RecordIDs = []string{}
+		carry.GenerationStart = ""
+		carry.GenerationEnd = ""
+	}
+	carry.UpdatedAt = now
+	if err := common.PutState(ctx, carryKey, carry, "REC 이월 발전량"); err != nil {
+		return nil, err
+	}
+	if err := common.PutState(ctx, claimKey, claim, "REC 발전량 청구"); err != nil {
+		return nil, err
+	}
+
+	return claim, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// generationPeriod - 측정 시각(meterTimeLayout 문자열) 범위
+type generationPeriod struct {
+	start string
+	end   string
+}
+
+func (p *generationPeriod) extend(at string) {
+	if at == "" {
+		return
+	}
+	if p.start == "" || at < p.start {
+		p.start = at
+	}
+	if p.end == "" || at > p.end {
+		p.end = at
+	}
+}
+
+// checkRECEligible - 공개받은 측정값이 REC 발행에 쓸 수 있는지 검사
+//
+// 공개 표시 도입 전 비공개 기록으로 REC 발행에 사용된(RECTokenIDs) 측정값도 사용된 것으로 본다.
+func checkRECEligible(ctx contractapi.TransactionContextInterface, record *MeterRecord, userID string, deviceID string, vintage string) (*recCandidate, error) {
+	if record.UserID != userID || record.DeviceID != deviceID {
+		return nil, fmt.Errorf("측정값 %s 는 %s/%s 의 기록이 아닙니다", record.RecordID, userID, deviceID)
+	}
+	ts, err := common.ParseTime(record.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if strconv.Itoa(ts.Year()) != vintage {
+		return nil, fmt.Errorf("측정값 %s 의 발전 연도가 %s 가 아닙니다", record.RecordID, vintage)
+	}
+	if record.Quality != QualityValid {
+		return nil, fmt.Errorf("VALID 측정값만 REC 발행에 사용할 수 있습니다: %s (%s)", record.RecordID, record.Quality)
+	}
+	if len(record.RECTokenIDs) > 0 {
+		return nil, fmt.Errorf("이미 REC 발행에 사용된 측정값입니다: %s (REC: %v)", record.RecordID, record.RECTokenIDs)
+	}
+	wh := toWh(record.Production)
+	if wh <= 0 {
+		return nil, fmt.Errorf("발전량이 없는 측정값입니다: %s", record.RecordID)
+	}
+
+	use, err := getRECGenerationUse(ctx, record.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	if use != nil {
+		return nil, fmt.Errorf("이미 REC 발행에 사용된 측정값입니다: %s (REC: %s)", record.RecordID, use.TokenID)
+	}
+
+	return &recCandidate{record: record, timestamp: ts, wh: wh}, nil
+}
+
+// getRECGenerationUse - 측정값의 REC 사용 표시 조회 (없으면 nil)
+func getRECGenerationUse(ctx contractapi.TransactionContextInterface, recordID string) (*RECGenerationUse, error) {
+	useKey, err := common.CompositeKey(ctx, recUseIndex, recordID)
+	if err != nil {
+		return nil, err
+	}
+	return common.GetState[RECGenerationUse](ctx, useKey, "REC 발전량 사용 표시")
+}
+
+// getRECGenerationCarry - 이월 발전량 키와 값 조회 (없으면 0인 값)
+func getRECGenerationCarry(ctx contractapi.TransactionContextInterface, userID string, deviceID string, vintage string) (string, *RECGenerationCarry, error) {
+	carryKey, err := common.CompositeKey(ctx, recCarryIndex, userID, deviceID, vintage)
+	if err != nil {
+		return "", nil, err
+	}
+	carry, err := common.GetState[RECGenerationCarry](ctx, carryKey, "REC 이월 발전량")
+	if err != nil {
+		return "", nil, err
+	}
+	if carry == nil {
+		carry = &RECGenerationCarry{UserID: userID, DeviceID: deviceID, Vintage: vintage, RecordIDs: []string{}}
+	}
+	return carryKey, carry, nil
+}
+
+// toWh - kWh 측정값을 Wh 정수로 변환 (반올림)
+func toWh(kWh float64) int64 {
+	return int64(math.Round(kWh * 1000))
+}
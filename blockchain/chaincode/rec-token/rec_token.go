@@ -4,11 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
+	"strconv"
 
+	"github.com/etp/chaincode/common"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// meteringChaincode - 발전량 청구 대상 체인코드 이름 (network/scripts/setup-network.sh 기준)
+const meteringChaincode = "metering-cc"
+
+// REC 토큰 상태
+const (
+	RECStatusActive  = "ACTIVE"
+	RECStatusRetired = "RETIRED"
+	RECStatusSplit   = "SPLIT"  // 하위 토큰으로 분할됨 (종료 상태)
+	RECStatusMerged  = "MERGED" // 다른 토큰으로 병합됨 (종료 상태)
+)
+
 // RECTokenContract - REC NFT 토큰 스마트 컨트랙트
 type RECTokenContract struct {
 	contractapi.Contract
@@ -22,15 +34,45 @@ type RECToken struct {
 	IssuerID     string  `json:"issuerId"`
 	OwnerID      string  `json:"ownerId"`
 	EnergySource string  `json:"energySource"`
-	Quantity     float64 `json:"quantity"`
+	Quantity     float64 `json:"quantity"` // MWh
 	Vintage      string  `json:"vintage"`
 	Location     string  `json:"location"`
-	Status       string  `json:"status"` // ACTIVE, TRANSFERRED, RETIRED
+	Status       string  `json:"status"` // ACTIVE, RETIRED, SPLIT, MERGED
 	IssuedAt     string  `json:"issuedAt"`
 	ValidUntil   string  `json:"validUntil"`
 	RetiredAt    string  `json:"retiredAt"`
 	RetiredBy    string  `json:"retiredBy"`
 	MetadataHash string  `json:"metadataHash"`
+	RetirementID string  `json:"retirementId,omitempty" metadata:",optional"` // 소멸 증명서 ID (RetireREC)
+	// 발전량 기반 발행 출처 (metering.ClaimRECGeneration, 이월분 출처 측정값 포함)
+	DeviceID        string   `json:"deviceId,omitempty" metadata:",optional"`
+	MeterRecordIDs  []string `json:"meterRecordIds,omitempty" metadata:",optional"`
+	GenerationStart string   `json:"generationStart,omitempty" metadata:",optional"`
+	GenerationEnd   string   `json:"generationEnd,omitempty" metadata:",optional"`
+	// 분할·병합 계보 (SplitREC, MergeREC)
+	ParentTokenIDs []string `json:"parentTokenIds,omitempty" metadata:",optional"`
+	ChildTokenIDs  []string `json:"childTokenIds,omitempty" metadata:",optional"`
+}
+
+// generationClaim - metering 체인코드 ClaimRECGeneration 결과
+type generationClaim struct {
+	TokenID         string   `json:"tokenId"`
+	UserID          string   `json:"userId"`
+	DeviceID        string   `json:"deviceId"`
+	EnergySource    string   `json:"energySource"`
+	Location        string   `json:"location"`
+	Vintage         string   `json:"vintage"`
+	QuantityMWh     int64    `json:"quantityMwh"`
+	RecordIDs       []string `json:"recordIds"`
+	GenerationStart string   `json:"generationStart"`
+	GenerationEnd   string   `json:"generationEnd"`
+}
+
+// RECTokenPage - REC 토큰 페이지 조회 결과
+type RECTokenPage struct {
+	Records      []RECToken `json:"records"`
+	Bookmark     string     `json:"bookmark"`
+	FetchedCount int32      `json:"fetchedCount"`
 }
 
 // RECTransferRecord - REC 양도 기록
@@ -47,82 +89,115 @@ func (c *RECTokenContract) InitLedger(ctx contractapi.TransactionContextInterfac
 	return ctx.GetStub().PutState("REC_TOKEN_COUNTER", []byte("0"))
 }
 
-// IssueREC - REC 토큰 발행
-func (c *RECTokenContract) IssueREC(ctx contractapi.TransactionContextInterface, tokenID string, certID string, tradeID string, issuerID string, ownerID string, energySource string, quantity float64, vintage string, location string, validUntil string, metadataHash string) error {
+// IssueREC - 검증된 발전량으로 REC 토큰 발행 (관리자 전용)
+//
+// 근거 측정값은 트랜지언트 맵 "meterRecords"(common.MeterDisclosureTransientKey)로 공개받는다.
+// metering.ClaimRECGeneration이 이를 공개 약정(MeterCommitment)과 대조한 뒤 userID 소유 디바이스의
+// vintage(발전 연도) 이월분과 측정값에서 quantityMWh(1 MWh 단위)만큼 같은 트랜잭션에서 사용 표시하므로
+// 같은 발전량으로 두 번 발행할 수 없고, 비공개 컬렉션을 읽지 않아 채널 기본 정책의 보증 피어에서 실행된다.
+// 에너지원과 위치는 디바이스 정보를 따르며, 토큰에는 근거 측정값 ID와 발전 기간을 기록한다.
+// 최초 소유자는 발전 사용자(userID)다.
+func (c *RECTokenContract) IssueREC(ctx contractapi.TransactionContextInterface, tokenID string, certID string, userID string, deviceID string, vintage string, quantityMWh int64, validUntil string, metadataHash string) error {
+	caller, err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if tokenID == "" {
+		return fmt.Errorf("토큰 ID는 필수입니다")
+	}
+
 	// 중복 확인
-	existing, err := ctx.GetStub().GetState("RECT_" + tokenID)
+	exists, err := common.Exists(ctx, "RECT_"+tokenID)
 	if err != nil {
-		return fmt.Errorf("REC 토큰 조회 실패: %v", err)
+		return err
 	}
-	if existing != nil {
+	if exists {
 		return fmt.Errorf("REC 토큰이 이미 존재합니다: %s", tokenID)
 	}
 
-	token := RECToken{
-		TokenID:      tokenID,
-		CertID:       certID,
-		TradeID:      tradeID,
-		IssuerID:     issuerID,
-		OwnerID:      ownerID,
-		EnergySource: energySource,
-		Quantity:     quantity,
-		Vintage:      vintage,
-		Location:     location,
-		Status:       "ACTIVE",
-		IssuedAt:     time.Now().UTC().Format(time.RFC3339),
-		ValidUntil:   validUntil,
-		MetadataHash: metadataHash,
+	disclosures, err := common.GetMeterDisclosures(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := common.InvokeChaincode(ctx, meteringChaincode, "ClaimRECGeneration", userID, deviceID, vintage, strconv.FormatInt(quantityMWh, 10), tokenID, disclosures)
+	if err != nil {
+		return err
+	}
+	var claim generationClaim
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return common.Failed("발전량 청구 역직렬화", err)
+	}
+	if claim.TokenID != tokenID || claim.UserID != userID || claim.DeviceID != deviceID || claim.QuantityMWh != quantityMWh {
+		return fmt.Errorf("발전량 청구 결과가 요청과 일치하지 않습니다")
 	}
 
-	tokenJSON, err := json.Marshal(token)
+	now, err := common.TxTimestamp(ctx)
 	if err != nil {
-		return fmt.Errorf("REC 토큰 직렬화 실패: %v", err)
+		return err
+	}
+
+	token := RECToken{
+		TokenID:         tokenID,
+		CertID:          certID,
+		IssuerID:        caller.ID,
+		OwnerID:         userID,
+		EnergySource:    claim.EnergySource,
+		Quantity:        float64(quantityMWh),
+		Vintage:         claim.Vintage,
+		Location:        claim.Location,
+		Status:          RECStatusActive,
+		IssuedAt:        now,
+		ValidUntil:      validUntil,
+		MetadataHash:    metadataHash,
+		DeviceID:        deviceID,
+		MeterRecordIDs:  claim.RecordIDs,
+		GenerationStart: claim.GenerationStart,
+		GenerationEnd:   claim.GenerationEnd,
 	}
 
 	// 토큰 저장
-	if err := ctx.GetStub().PutState("RECT_"+tokenID, tokenJSON); err != nil {
-		return fmt.Errorf("REC 토큰 저장 실패: %v", err)
+	if err := common.PutState(ctx, "RECT_"+tokenID, token, "REC 토큰"); err != nil {
+		return err
 	}
 
 	// 소유권 인덱스 저장
-	if err := ctx.GetStub().PutState("RECT_OWNER_"+ownerID+"_"+tokenID, []byte(tokenID)); err != nil {
+	if err := ctx.GetStub().PutState("RECT_OWNER_"+userID+"_"+tokenID, []byte(tokenID)); err != nil {
 		return fmt.Errorf("소유권 인덱스 저장 실패: %v", err)
 	}
 
-	ctx.GetStub().SetEvent("RECIssuedEvent", tokenJSON)
-
-	return nil
+	return common.SetEvent(ctx, "RECIssuedEvent", token)
 }
 
-// TransferREC - REC 토큰 양도
-func (c *RECTokenContract) TransferREC(ctx contractapi.TransactionContextInterface, tokenID string, fromID string, toID string) error {
+// TransferREC - REC 토큰 양도 (소유자 본인 또는 관리자, 양도인은 현재 소유자)
+func (c *RECTokenContract) TransferREC(ctx contractapi.TransactionContextInterface, tokenID string, toID string) error {
 	token, err := c.getToken(ctx, tokenID)
 	if err != nil {
 		return err
 	}
 
-	if token.OwnerID != fromID {
-		return fmt.Errorf("소유자가 아닙니다: 현재 소유자 %s, 요청자 %s", token.OwnerID, fromID)
+	if _, err := requireOwnerOrAdmin(ctx, token.OwnerID); err != nil {
+		return err
 	}
+	fromID := token.OwnerID
 
-	if token.Status != "ACTIVE" {
+	if token.Status != RECStatusActive {
 		return fmt.Errorf("이전 가능한 상태가 아닙니다: 현재 상태 %s", token.Status)
 	}
 
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// 기존 소유권 인덱스 삭제
 	ctx.GetStub().DelState("RECT_OWNER_" + fromID + "_" + tokenID)
 
 	// 소유권 변경
 	token.OwnerID = toID
-	token.Status = "ACTIVE"
-
-	tokenJSON, err := json.Marshal(token)
-	if err != nil {
-		return fmt.Errorf("REC 토큰 직렬화 실패: %v", err)
-	}
+	token.Status = RECStatusActive
 
-	if err := ctx.GetStub().PutState("RECT_"+tokenID, tokenJSON); err != nil {
-		return fmt.Errorf("REC 토큰 업데이트 실패: %v", err)
+	if err := common.PutState(ctx, "RECT_"+tokenID, token, "REC 토큰"); err != nil {
+		return err
 	}
 
 	// 새 소유권 인덱스 저장
@@ -137,48 +212,14 @@ func (c *RECTokenContract) TransferREC(ctx contractapi.TransactionContextInterfa
 		TokenID:    tokenID,
 		FromID:     fromID,
 		ToID:       toID,
-		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Timestamp:  now,
 	}
 
-	transferJSON, _ := json.Marshal(transferRecord)
-	ctx.GetStub().PutState("RECT_TXF_"+transferID, transferJSON)
-	ctx.GetStub().SetEvent("RECTransferEvent", transferJSON)
-
-	return nil
-}
-
-// RetireREC - REC 토큰 소멸 (RE100 달성 처리)
-func (c *RECTokenContract) RetireREC(ctx contractapi.TransactionContextInterface, tokenID string, retiredBy string) error {
-	token, err := c.getToken(ctx, tokenID)
-	if err != nil {
+	if err := common.PutState(ctx, "RECT_TXF_"+transferID, transferRecord, "양도 기록"); err != nil {
 		return err
 	}
 
-	if token.OwnerID != retiredBy {
-		return fmt.Errorf("소유자만 소멸할 수 있습니다")
-	}
-
-	if token.Status == "RETIRED" {
-		return fmt.Errorf("이미 소멸된 REC 토큰입니다")
-	}
-
-	now := time.Now().UTC().Format(time.RFC3339)
-	token.Status = "RETIRED"
-	token.RetiredAt = now
-	token.RetiredBy = retiredBy
-
-	tokenJSON, err := json.Marshal(token)
-	if err != nil {
-		return fmt.Errorf("REC 토큰 직렬화 실패: %v", err)
-	}
-
-	if err := ctx.GetStub().PutState("RECT_"+tokenID, tokenJSON); err != nil {
-		return fmt.Errorf("REC 토큰 업데이트 실패: %v", err)
-	}
-
-	ctx.GetStub().SetEvent("RECRetiredEvent", tokenJSON)
-
-	return nil
+	return common.SetEvent(ctx, "RECTransferEvent", transferRecord)
 }
 
 // GetREC - REC 토큰 조회
@@ -188,53 +229,73 @@ func (c *RECTokenContract) GetREC(ctx contractapi.TransactionContextInterface, t
 
 // GetRECsByOwner - 소유자별 REC 토큰 조회
 func (c *RECTokenContract) GetRECsByOwner(ctx contractapi.TransactionContextInterface, ownerID string) ([]RECToken, error) {
-	startKey := "RECT_OWNER_" + ownerID + "_"
-	endKey := "RECT_OWNER_" + ownerID + "_~"
-
-	resultsIter, err := ctx.GetStub().GetStateByRange(startKey, endKey)
-	if err != nil {
-		return nil, fmt.Errorf("소유자 토큰 조회 실패: %v", err)
-	}
-	defer resultsIter.Close()
+	startKey, endKey := common.PrefixRange("RECT_OWNER_" + ownerID + "_")
 
 	var tokens []RECToken
-	for resultsIter.HasNext() {
-		result, err := resultsIter.Next()
+	err := common.ForEachInRange(ctx, startKey, endKey, func(key string, value []byte) error {
+		token, err := c.getToken(ctx, string(value))
 		if err != nil {
-			return nil, fmt.Errorf("순회 실패: %v", err)
+			return nil
 		}
+		tokens = append(tokens, *token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		tokenID := string(result.Value)
-		token, err := c.getToken(ctx, tokenID)
+	return tokens, nil
+}
+
+// GetRECsByOwnerWithPagination - 소유자별 REC 토큰 조회 (북마크 기반 페이지 조회)
+func (c *RECTokenContract) GetRECsByOwnerWithPagination(ctx contractapi.TransactionContextInterface, ownerID string, pageSize int32, bookmark string) (*RECTokenPage, error) {
+	startKey, endKey := common.PrefixRange("RECT_OWNER_" + ownerID + "_")
+
+	page := &RECTokenPage{Records: []RECToken{}}
+	next, fetched, err := common.ForEachInRangePage(ctx, startKey, endKey, pageSize, bookmark, func(key string, value []byte) error {
+		token, err := c.getToken(ctx, string(value))
 		if err != nil {
-			continue
+			return err
 		}
-		tokens = append(tokens, *token)
+		page.Records = append(page.Records, *token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return tokens, nil
+	page.Bookmark = next
+	page.FetchedCount = fetched
+
+	return page, nil
 }
 
-// GetRECHistory - REC 토큰 이력 (provenance chain)
-func (c *RECTokenContract) GetRECHistory(ctx contractapi.TransactionContextInterface, tokenID string) ([]RECToken, error) {
-	historyIter, err := ctx.GetStub().GetHistoryForKey("RECT_" + tokenID)
+// QueryRECs - 소유자/상태/에너지원/발전연도 조건으로 REC 토큰 조회 (CouchDB 전용)
+//
+// 빈 조건은 필터에서 제외된다. 예: QueryRECs("", "ACTIVE", "SOLAR", "2025", 50, "")
+func (c *RECTokenContract) QueryRECs(ctx contractapi.TransactionContextInterface, ownerID string, status string, energySource string, vintage string, pageSize int32, bookmark string) (*RECTokenPage, error) {
+	selector := common.NewSelector().
+		Exists("tokenId").
+		Eq("ownerId", ownerID).
+		Eq("status", status).
+		Eq("energySource", energySource).
+		Eq("vintage", vintage)
+
+	page, err := common.QuerySelectorPage[RECToken](ctx, selector, pageSize, bookmark, "REC 토큰")
 	if err != nil {
-		return nil, fmt.Errorf("REC 이력 조회 실패: %v", err)
+		return nil, err
 	}
-	defer historyIter.Close()
 
-	var history []RECToken
-	for historyIter.HasNext() {
-		result, err := historyIter.Next()
-		if err != nil {
-			return nil, fmt.Errorf("이력 순회 실패: %v", err)
-		}
+	return &RECTokenPage{Records: page.Records, Bookmark: page.Bookmark, FetchedCount: page.FetchedCount}, nil
+}
 
-		var token RECToken
-		if err := json.Unmarshal(result.Value, &token); err != nil {
-			continue
-		}
-		history = append(history, token)
+// GetRECHistory - REC 토큰 이력 (provenance chain)
+//
+// 분할·병합으로 생긴 토큰은 상위 토큰의 이력을 먼저 포함하므로 발행 시점까지 거슬러 올라간다.
+func (c *RECTokenContract) GetRECHistory(ctx contractapi.TransactionContextInterface, tokenID string) ([]RECToken, error) {
+	history := []RECToken{}
+	if err := c.appendHistory(ctx, tokenID, map[string]bool{}, &history); err != nil {
+		return nil, err
 	}
 
 	return history, nil
@@ -242,21 +303,34 @@ func (c *RECTokenContract) GetRECHistory(ctx contractapi.TransactionContextInter
 
 // ========== 내부 헬퍼 ==========
 
-func (c *RECTokenContract) getToken(ctx contractapi.TransactionContextInterface, tokenID string) (*RECToken, error) {
-	tokenJSON, err := ctx.GetStub().GetState("RECT_" + tokenID)
+// appendHistory - 상위 토큰 이력을 먼저 붙인 뒤 tokenID의 이력을 붙인다 (병합으로 공유된 상위 토큰은 한 번만)
+func (c *RECTokenContract) appendHistory(ctx contractapi.TransactionContextInterface, tokenID string, visited map[string]bool, history *[]RECToken) error {
+	if visited[tokenID] {
+		return nil
+	}
+	visited[tokenID] = true
+
+	token, err := c.getToken(ctx, tokenID)
 	if err != nil {
-		return nil, fmt.Errorf("REC 토큰 조회 실패: %v", err)
+		return err
 	}
-	if tokenJSON == nil {
-		return nil, fmt.Errorf("REC 토큰을 찾을 수 없습니다: %s", tokenID)
+	for _, parentID := range token.ParentTokenIDs {
+		if err := c.appendHistory(ctx, parentID, visited, history); err != nil {
+			return err
+		}
 	}
 
-	var token RECToken
-	if err := json.Unmarshal(tokenJSON, &token); err != nil {
-		return nil, fmt.Errorf("REC 토큰 역직렬화 실패: %v", err)
+	records, err := common.GetHistory[RECToken](ctx, "RECT_"+tokenID, "REC")
+	if err != nil {
+		return err
 	}
+	*history = append(*history, records...)
 
-	return &token, nil
+	return nil
+}
+
+func (c *RECTokenContract) getToken(ctx contractapi.TransactionContextInterface, tokenID string) (*RECToken, error) {
+	return common.MustGetState[RECToken](ctx, "RECT_"+tokenID, "REC 토큰", tokenID)
 }
 
 func main() {
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 소멸 청구 목적
+const (
+	PurposeRE100     = "RE100"
+	PurposeGHGScope2 = "GHG_SCOPE2" // GHG Protocol Scope 2 시장 기반 배출량 산정
+	PurposeVoluntary = "VOLUNTARY"
+)
+
+var retirementPurposes = map[string]bool{PurposeRE100: true, PurposeGHGScope2: true, PurposeVoluntary: true}
+
+// RetirementCertificate - REC 소멸 증명서 (RECT_RETIRE_{txID})
+//
+// 감사 기관이 ID로 조회해 수혜자·보고 연도·목적과 소멸된 REC의 출처를 확인한다.
+type RetirementCertificate struct {
+	RetirementID        string  `json:"retirementId"` // 소멸 트랜잭션 ID
+	TokenID             string  `json:"tokenId"`      // 소멸된 토큰 (부분 소멸이면 분할된 하위 토큰)
+	SourceTokenID       string  `json:"sourceTokenId"`
+	RemainderTokenID    string  `json:"remainderTokenId,omitempty" metadata:",optional"` // 부분 소멸 후 남은 수량의 토큰
+	Quantity            float64 `json:"quantity"`                                        // MWh
+	Beneficiary         string  `json:"beneficiary"`                                     // 환경 속성을 주장하는 법인
+	ReportingYear       int     `json:"reportingYear"`
+	Purpose             string  `json:"purpose"`             // RE100, GHG_SCOPE2, VOLUNTARY
+	ConsumptionLocation string  `json:"consumptionLocation"` // 전력 소비(청구) 위치
+	CertID              string  `json:"certId"`
+	EnergySource        string  `json:"energySource"`
+	Vintage             string  `json:"vintage"`
+	GenerationLocation  string  `json:"generationLocation"`
+	DeviceID            string  `json:"deviceId,omitempty" metadata:",optional"`
+	GenerationStart     string  `json:"generationStart,omitempty" metadata:",optional"`
+	GenerationEnd       string  `json:"generationEnd,omitempty" metadata:",optional"`
+	RetiredBy           string  `json:"retiredBy"` // 소멸 시점의 소유자
+	RetiredAt           string  `json:"retiredAt"`
+}
+
+// RetireREC - REC 토큰의 quantityMWh 만큼 소멸하고 소멸 증명서 발급 (소유자 본인 또는 관리자)
+//
+// quantityMWh가 토큰 수량보다 작으면 [quantityMWh, 나머지]로 분할(SplitREC와 같은 규칙)한 뒤
+// 첫 하위 토큰을 소멸하고 나머지 토큰은 ACTIVE로 남긴다. reportingYear는 발전 연도 이후여야 한다.
+func (c *RECTokenContract) RetireREC(ctx contractapi.TransactionContextInterface, tokenID string, quantityMWh int64, beneficiary string, reportingYear int, purpose string, consumptionLocation string) (*RetirementCertificate, error) {
+	if beneficiary == "" || consumptionLocation == "" {
+		return nil, fmt.Errorf("수혜자와 소비 위치는 필수입니다")
+	}
+	if !retirementPurposes[purpose] {
+		return nil, fmt.Errorf("알 수 없는 소멸 목적입니다: %s (RE100, GHG_SCOPE2, VOLUNTARY)", purpose)
+	}
+
+	token, err := c.getToken(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireOwnerOrAdmin(ctx, token.OwnerID); err != nil {
+		return nil, err
+	}
+	if token.Status == RECStatusRetired {
+		return nil, fmt.Errorf("이미 소멸된 REC 토큰입니다")
+	}
+	if token.Status != RECStatusActive {
+		return nil, fmt.Errorf("소멸 가능한 상태가 아닙니다: 현재 상태 %s", token.Status)
+	}
+	if quantityMWh <= 0 || float64(quantityMWh) > token.Quantity {
+		return nil, fmt.Errorf("소멸 수량은 1 MWh 이상, 토큰 수량(%v) 이하여야 합니다: %d", token.Quantity, quantityMWh)
+	}
+	if err := checkReportingYear(reportingYear, token.Vintage); err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	retired := token
+	remainderID := ""
+	if float64(quantityMWh) < token.Quantity {
+		children, err := c.split(ctx, token, []int64{quantityMWh, int64(token.Quantity) - quantityMWh})
+		if err != nil {
+			return nil, err
+		}
+		retired = &children[0]
+		remainderID = children[1].TokenID
+	}
+
+	retirementID := ctx.GetStub().GetTxID()
+	retired.Status = RECStatusRetired
+	retired.RetiredAt = now
+	retired.RetiredBy = retired.OwnerID
+	retired.RetirementID = retirementID
+	if err := common.PutState(ctx, "RECT_"+retired.TokenID, retired, "REC 토큰"); err != nil {
+		return nil, err
+	}
+
+	certificate := &RetirementCertificate{
+		RetirementID:        retirementID,
+		TokenID:             retired.TokenID,
+		SourceTokenID:       tokenID,
+		RemainderTokenID:    remainderID,
+		Quantity:            retired.Quantity,
+		Beneficiary:         beneficiary,
+		ReportingYear:       reportingYear,
+		Purpose:             purpose,
+		ConsumptionLocation: consumptionLocation,
+		CertID:              retired.CertID,
+		EnergySource:        retired.EnergySource,
+		Vintage:             retired.Vintage,
+		GenerationLocation:  retired.Location,
+		DeviceID:            retired.DeviceID,
+		GenerationStart:     retired.GenerationStart,
+		GenerationEnd:       retired.GenerationEnd,
+		RetiredBy:           retired.RetiredBy,
+		RetiredAt:           now,
+	}
+	if err := common.PutState(ctx, "RECT_RETIRE_"+retirementID, certificate, "소멸 증명서"); err != nil {
+		return nil, err
+	}
+
+	if err := common.SetEvent(ctx, "RECRetiredEvent", certificate); err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
+}
+
+// GetRetirementCertificate - 소멸 증명서 조회
+func (c *RECTokenContract) GetRetirementCertificate(ctx contractapi.TransactionContextInterface, retirementID string) (*RetirementCertificate, error) {
+	return common.MustGetState[RetirementCertificate](ctx, "RECT_RETIRE_"+retirementID, "소멸 증명서", retirementID)
+}
+
+// ========== 내부 헬퍼 ==========
+
+// checkReportingYear - 보고 연도가 발전 연도(vintage 앞 4자리) 이후인지 검사
+func checkReportingYear(reportingYear int, vintage string) error {
+	if reportingYear < 2000 || reportingYear > 9999 {
+		return fmt.Errorf("잘못된 보고 연도입니다: %d", reportingYear)
+	}
+	if len(vintage) < 4 {
+		return nil
+	}
+	if year, err := strconv.Atoi(vintage[:4]); err == nil && reportingYear < year {
+		return fmt.Errorf("보고 연도(%d)가 발전 연도(%s)보다 이릅니다", reportingYear, vintage)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxSplitParts - SplitREC 한 번에 만들 수 있는 최대 하위 토큰 수
+const maxSplitParts = 100
+
+// maxMergeTokens - MergeREC 한 번에 병합할 수 있는 최대 토큰 수
+const maxMergeTokens = 100
+
+// RECSplitEvent - REC 토큰 분할 이벤트
+type RECSplitEvent struct {
+	TokenID       string   `json:"tokenId"`
+	OwnerID       string   `json:"ownerId"`
+	ChildTokenIDs []string `json:"childTokenIds"`
+	Quantities    []int64  `json:"quantities"`
+	Timestamp     string   `json:"timestamp"`
+}
+
+// RECMergedEvent - REC 토큰 병합 이벤트
+type RECMergedEvent struct {
+	TokenID        string   `json:"tokenId"`
+	OwnerID        string   `json:"ownerId"`
+	ParentTokenIDs []string `json:"parentTokenIds"`
+	Quantity       float64  `json:"quantity"`
+	Timestamp      string   `json:"timestamp"`
+}
+
+// SplitREC - REC 토큰을 quantitiesJSON(MWh 정수 배열) 수량의 하위 토큰으로 분할 (소유자 본인 또는 관리자)
+//
+// 수량의 합은 토큰 수량과 같아야 하며 하위 토큰 ID는 {tokenID}-{순번(1부터)}이다.
+// 하위 토큰은 인증서·에너지원·발전 연도·위치·발전 출처를 그대로 물려받고 ParentTokenIDs로 상위 토큰을 가리킨다.
+// 상위 토큰은 SPLIT 상태가 되어 더 이상 양도·소멸할 수 없다.
+func (c *RECTokenContract) SplitREC(ctx contractapi.TransactionContextInterface, tokenID string, quantitiesJSON string) ([]RECToken, error) {
+	var quantities []int64
+	if err := json.Unmarshal([]byte(quantitiesJSON), &quantities); err != nil {
+		return nil, fmt.Errorf("분할 수량 역직렬화 실패: %v", err)
+	}
+
+	token, err := c.getToken(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireOwnerOrAdmin(ctx, token.OwnerID); err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := c.split(ctx, token, quantities)
+	if err != nil {
+		return nil, err
+	}
+
+	err = common.SetEvent(ctx, "RECSplitEvent", RECSplitEvent{
+		TokenID:       tokenID,
+		OwnerID:       token.OwnerID,
+		ChildTokenIDs: token.ChildTokenIDs,
+		Quantities:    quantities,
+		Timestamp:     now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return children, nil
+}
+
+// MergeREC - 소유자와 속성이 같은 ACTIVE REC 토큰들을 하나로 병합 (소유자 본인 또는 관리자)
+//
+// 인증서·발행자·에너지원·발전 연도·위치·유효기간·메타데이터 해시·디바이스가 모두 같아야 한다.
+// 병합된 토큰 ID는 트랜잭션 ID이며 근거 측정값은 합집합, 발전 기간은 전체 구간으로 기록한다.
+// 원래 토큰은 MERGED 상태가 되고 ChildTokenIDs로 병합된 토큰을 가리킨다.
+func (c *RECTokenContract) MergeREC(ctx contractapi.TransactionContextInterface, tokenIDsJSON string) (*RECToken, error) {
+	var tokenIDs []string
+	if err := json.Unmarshal([]byte(tokenIDsJSON), &tokenIDs); err != nil {
+		return nil, fmt.Errorf("토큰 ID 목록 역직렬화 실패: %v", err)
+	}
+	if len(tokenIDs) < 2 || len(tokenIDs) > maxMergeTokens {
+		return nil, fmt.Errorf("병합할 토큰은 2~%d개여야 합니다: %d개", maxMergeTokens, len(tokenIDs))
+	}
+
+	tokens := make([]*RECToken, 0, len(tokenIDs))
+	seen := map[string]bool{}
+	for _, tokenID := range tokenIDs {
+		if seen[tokenID] {
+			return nil, fmt.Errorf("토큰 ID가 중복되었습니다: %s", tokenID)
+		}
+		seen[tokenID] = true
+
+		token, err := c.getToken(ctx, tokenID)
+		if err != nil {
+			return nil, err
+		}
+		if token.Status != RECStatusActive {
+			return nil, fmt.Errorf("병합 가능한 상태가 아닙니다: %s (상태: %s)", tokenID, token.Status)
+		}
+		if len(tokens) > 0 && !sameAttributes(tokens[0], token) {
+			return nil, fmt.Errorf("소유자 또는 속성이 다른 토큰은 병합할 수 없습니다: %s, %s", tokens[0].TokenID, tokenID)
+		}
+		tokens = append(tokens, token)
+	}
+	if _, err := requireOwnerOrAdmin(ctx, tokens[0].OwnerID); err != nil {
+		return nil, err
+	}
+
+	mergedID := ctx.GetStub().GetTxID()
+	exists, err := common.Exists(ctx, "RECT_"+mergedID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("REC 토큰이 이미 존재합니다: %s", mergedID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := *tokens[0]
+	merged.TokenID = mergedID
+	merged.Quantity = 0
+	merged.MeterRecordIDs = nil
+	merged.ParentTokenIDs = tokenIDs
+	merged.ChildTokenIDs = nil
+	recorded := map[string]bool{}
+	for _, token := range tokens {
+		merged.Quantity += token.Quantity
+		if token.IssuedAt < merged.IssuedAt {
+			merged.IssuedAt = token.IssuedAt
+		}
+		if token.GenerationStart != "" && (merged.GenerationStart == "" || token.GenerationStart < merged.GenerationStart) {
+			merged.GenerationStart = token.GenerationStart
+		}
+		if token.GenerationEnd > merged.GenerationEnd {
+			merged.GenerationEnd = token.GenerationEnd
+		}
+		for _, recordID := range token.MeterRecordIDs {
+			if !recorded[recordID] {
+				recorded[recordID] = true
+				merged.MeterRecordIDs = append(merged.MeterRecordIDs, recordID)
+			}
+		}
+
+		token.Status = RECStatusMerged
+		token.ChildTokenIDs = []string{mergedID}
+		if err := c.closeToken(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.createToken(ctx, &merged); err != nil {
+		return nil, err
+	}
+
+	err = common.SetEvent(ctx, "RECMergedEvent", RECMergedEvent{
+		TokenID:        mergedID,
+		OwnerID:        merged.OwnerID,
+		ParentTokenIDs: tokenIDs,
+		Quantity:       merged.Quantity,
+		Timestamp:      now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &merged, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// split - ACTIVE 토큰을 하위 토큰으로 분할하고 상위 토큰을 SPLIT으로 닫는다 (권한 검사와 이벤트는 호출자 몫)
+func (c *RECTokenContract) split(ctx contractapi.TransactionContextInterface, token *RECToken, quantities []int64) ([]RECToken, error) {
+	if token.Status != RECStatusActive {
+		return nil, fmt.Errorf("분할 가능한 상태가 아닙니다: 현재 상태 %s", token.Status)
+	}
+	if token.Quantity != math.Trunc(token.Quantity) {
+		return nil, fmt.Errorf("MWh 정수 수량의 토큰만 분할할 수 있습니다: %v", token.Quantity)
+	}
+	if len(quantities) < 2 || len(quantities) > maxSplitParts {
+		return nil, fmt.Errorf("분할 수량은 2~%d개여야 합니다: %d개", maxSplitParts, len(quantities))
+	}
+
+	var total int64
+	for _, quantity := range quantities {
+		if quantity <= 0 {
+			return nil, fmt.Errorf("분할 수량은 1 MWh 이상이어야 합니다: %d", quantity)
+		}
+		total += quantity
+	}
+	if float64(total) != token.Quantity {
+		return nil, fmt.Errorf("분할 수량의 합(%d)이 토큰 수량(%v)과 다릅니다", total, token.Quantity)
+	}
+
+	children := make([]RECToken, 0, len(quantities))
+	for i, quantity := range quantities {
+		child := *token
+		child.TokenID = token.TokenID + "-" + strconv.Itoa(i+1)
+		child.Quantity = float64(quantity)
+		child.ParentTokenIDs = []string{token.TokenID}
+		child.ChildTokenIDs = nil
+
+		exists, err := common.Exists(ctx, "RECT_"+child.TokenID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("REC 토큰이 이미 존재합니다: %s", child.TokenID)
+		}
+		if err := c.createToken(ctx, &child); err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		token.ChildTokenIDs = append(token.ChildTokenIDs, child.TokenID)
+	}
+
+	token.Status = RECStatusSplit
+	if err := c.closeToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return children, nil
+}
+
+// createToken - 토큰과 소유권 인덱스 저장
+func (c *RECTokenContract) createToken(ctx contractapi.TransactionContextInterface, token *RECToken) error {
+	if err := common.PutState(ctx, "RECT_"+token.TokenID, token, "REC 토큰"); err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState("RECT_OWNER_"+token.OwnerID+"_"+token.TokenID, []byte(token.TokenID)); err != nil {
+		return fmt.Errorf("소유권 인덱스 저장 실패: %v", err)
+	}
+	return nil
+}
+
+// closeToken - 분할·병합으로 종료된 토큰 저장 (소유자 목록에서 제외)
+func (c *RECTokenContract) closeToken(ctx contractapi.TransactionContextInterface, token *RECToken) error {
+	if err := common.PutState(ctx, "RECT_"+token.TokenID, token, "REC 토큰"); err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState("RECT_OWNER_" + token.OwnerID + "_" + token.TokenID); err != nil {
+		return fmt.Errorf("소유권 인덱스 삭제 실패: %v", err)
+	}
+	return nil
+}
+
+// sameAttributes - 병합 가능한(소유자와 REC 속성이 같은) 토큰인지 확인
+func sameAttributes(a *RECToken, b *RECToken) bool {
+	return a.OwnerID == b.OwnerID &&
+		a.CertID == b.CertID &&
+		a.TradeID == b.TradeID &&
+		a.IssuerID == b.IssuerID &&
+		a.EnergySource == b.EnergySource &&
+		a.Vintage == b.Vintage &&
+		a.Location == b.Location &&
+		a.ValidUntil == b.ValidUntil &&
+		a.MetadataHash == b.MetadataHash &&
+		a.DeviceID == b.DeviceID
+}
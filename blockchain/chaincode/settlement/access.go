@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// operatorMSP - 정산 운영 기관 MSP
+const operatorMSP = "AdminOrgMSP"
+
+// requireAdmin - 운영 기관 관리자 여부 검사
+func requireAdmin(ctx contractapi.TransactionContextInterface) (*common.CallerIdentity, error) {
+	return requireRole(ctx, "admin")
+}
+
+// requireOperator - 정산 운영자(관리자 또는 settlement 역할) 여부 검사
+func requireOperator(ctx contractapi.TransactionContextInterface) (*common.CallerIdentity, error) {
+	return requireRole(ctx, "admin", "settlement")
+}
+
+func requireRole(ctx contractapi.TransactionContextInterface, roles ...string) (*common.CallerIdentity, error) {
+	caller, err := common.RequireMSP(ctx, operatorMSP)
+	if err != nil {
+		return nil, err
+	}
+	if !common.Contains(roles, caller.Role) {
+		return nil, fmt.Errorf("권한 없음: %v 역할이 필요합니다 (역할: %q)", roles, caller.Role)
+	}
+
+	return caller, nil
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchTrades - 일괄 정산 한 건에 포함할 수 있는 최대 거래 수 (트랜잭션 크기 제한)
+const maxBatchTrades = 500
+
+// unitsPerEPC - EPC 기본 단위 (상계 계산은 정수 기본 단위로 수행)
+var unitsPerEPC = math.Pow10(epcDecimals)
+
+// maxExactUnits - float64 금액이 기본 단위와 정확히 왕복하는 상한 (2^51 기본 단위, 약 22.5억 EPC)
+const maxExactUnits int64 = 1 << 51
+
+// SettlementBatch - 일괄 정산 기록
+type SettlementBatch struct {
+	BatchID            string          `json:"batchId"`
+	WindowStart        string          `json:"windowStart"`
+	WindowEnd          string          `json:"windowEnd"`
+	TradeIDs           []string        `json:"tradeIds"`
+	SkippedTradeIDs    []string        `json:"skippedTradeIds"` // 개별 정산이 진행 중이라 제외한 거래
+	Obligations        []NetObligation `json:"obligations"`
+	FeeCollectorID     string          `json:"feeCollectorId"`
+	FeeScheduleVersion int             `json:"feeScheduleVersion"`
+	GrossAmount        float64         `json:"grossAmount"`       // 거래 대금 합계
+	NetTransferAmount  float64         `json:"netTransferAmount"` // 상계 후 참여자 간 이체 합계
+	TotalFee           float64         `json:"totalFee"`
+	Status             string          `json:"status"` // COMPLETED
+	CreatedBy          string          `json:"createdBy"`
+	CreatedAt          string          `json:"createdAt"`
+	SettledAt          string          `json:"settledAt"`
+}
+
+// NetObligation - 거래 상대방 쌍의 상계 결과
+//
+// PartyA < PartyB 순으로 정렬되며 NetAmount는 PayerID가 PayeeID에게 이체한 순액이다.
+type NetObligation struct {
+	PartyA    string          `json:"partyA"`
+	PartyB    string          `json:"partyB"`
+	PayerID   string          `json:"payerId"` // 순액이 0이면 빈 문자열
+	PayeeID   string          `json:"payeeId"`
+	GrossAToB float64         `json:"grossAToB"` // PartyA가 구매자인 거래 대금 합계
+	GrossBToA float64         `json:"grossBToA"` // PartyB가 구매자인 거래 대금 합계
+	NetAmount float64         `json:"netAmount"`
+	TradeIDs  []string        `json:"tradeIds"`
+	Legs      []ObligationLeg `json:"legs"`
+}
+
+// ObligationLeg - 상계에 포함된 개별 거래
+type ObligationLeg struct {
+	TradeID  string  `json:"tradeId"`
+	BuyerID  string  `json:"buyerId"`
+	SellerID string  `json:"sellerId"`
+	Amount   float64 `json:"amount"`
+	Fee      float64 `json:"fee"` // 판매자 부담
+}
+
+// BatchSettledEvent - 일괄 정산 완료 이벤트
+type BatchSettledEvent struct {
+	BatchID           string  `json:"batchId"`
+	TradeCount        int     `json:"tradeCount"`
+	SkippedCount      int     `json:"skippedCount"`
+	ObligationCount   int     `json:"obligationCount"`
+	GrossAmount       float64 `json:"grossAmount"`
+	NetTransferAmount float64 `json:"netTransferAmount"`
+	TotalFee          float64 `json:"totalFee"`
+	SettledAt         string  `json:"settledAt"`
+}
+
+// batchEscrow, batchTransfer, batchInstruction - epc.SettleBatch 지시 형식
+type batchEscrow struct {
+	RefID         string `json:"refId"`
+	OwnerID       string `json:"ownerId"`
+	BeneficiaryID string `json:"beneficiaryId"`
+	Amount        string `json:"amount"`
+	Fee           string `json:"fee,omitempty"`
+}
+
+type batchTransfer struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+type batchInstruction struct {
+	BatchID      string          `json:"batchId"`
+	FeeAccountID string          `json:"feeAccountId,omitempty"`
+	Escrows      []batchEscrow   `json:"escrows"`
+	Transfers    []batchTransfer `json:"transfers"`
+}
+
+// SettleBatch - 정산 구간의 CONFIRMED 거래를 거래 상대방 쌍별로 상계하여 일괄 정산 (정산 운영자 전용)
+//
+// 각 거래의 구매 주문 에스크로를 해제하고 쌍별 순액과 판매자별 수수료만 이체한 뒤(epc.SettleBatch)
+// 모든 거래를 SETTLED로 변경한다. 하나라도 실패하면 트랜잭션 전체가 무효가 된다.
+// 진행 중인 개별 정산(CreateSettlement)이 있는 거래는 그 정산으로 처리되도록 제외하고 SkippedTradeIDs에 남긴다.
+func (c *SettlementContract) SettleBatch(ctx contractapi.TransactionContextInterface, batchID string, windowStart string, windowEnd string) (*SettlementBatch, error) {
+	caller, err := requireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if batchID == "" {
+		return nil, fmt.Errorf("일괄 정산 ID가 비어 있습니다")
+	}
+
+	existing, err := common.GetState[SettlementBatch](ctx, batchKey(batchID), "일괄 정산")
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("일괄 정산이 이미 존재합니다: %s", batchID)
+	}
+
+	trades, err := getConfirmedTrades(ctx, windowStart, windowEnd)
+	if err != nil {
+		return nil, err
+	}
+	if len(trades) == 0 {
+		return nil, fmt.Errorf("정산 구간에 확정된 거래가 없습니다: %s ~ %s", windowStart, windowEnd)
+	}
+	if len(trades) > maxBatchTrades {
+		return nil, fmt.Errorf("일괄 정산 거래 수 초과: %d건 (최대 %d건), 구간을 나누어 주세요", len(trades), maxBatchTrades)
+	}
+
+	schedule, err := c.GetFeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := SettlementBatch{
+		BatchID:            batchID,
+		WindowStart:        windowStart,
+		WindowEnd:          windowEnd,
+		TradeIDs:           []string{},
+		SkippedTradeIDs:    []string{},
+		Obligations:        []NetObligation{},
+		FeeCollectorID:     schedule.FeeCollectorID,
+		FeeScheduleVersion: schedule.Version,
+		Status:             StatusCompleted,
+		CreatedBy:          caller.ID,
+		CreatedAt:          now,
+		SettledAt:          now,
+	}
+	instruction := batchInstruction{BatchID: batchID, FeeAccountID: schedule.FeeCollectorID, Escrows: []batchEscrow{}, Transfers: []batchTransfer{}}
+
+	// 정수 기본 단위로 상계하여 부동소수점 오차 없이 순액을 맞춘다
+	type pairNet struct {
+		obligation NetObligation
+		aToB, bToA int64
+	}
+	pairs := map[string]*pairNet{}
+	sellerFees := map[string]int64{}
+	var gross, totalFee int64
+
+	for i := range trades {
+		trade := &trades[i]
+		if trade.Status != "CONFIRMED" {
+			return nil, fmt.Errorf("확정되지 않은 거래는 정산할 수 없습니다: %s (%s)", trade.TradeID, trade.Status)
+		}
+		if trade.BuyerID == trade.SellerID {
+			return nil, fmt.Errorf("구매자와 판매자가 같은 거래입니다: %s", trade.TradeID)
+		}
+
+		settlement, err := c.tradeSettlement(ctx, trade.TradeID)
+		if err != nil {
+			return nil, err
+		}
+		if settlement != nil && settlement.Status == StatusCompleted {
+			return nil, fmt.Errorf("이미 정산이 완료된 거래입니다: %s (정산: %s)", trade.TradeID, settlement.SettlementID)
+		}
+		if settlement != nil && isSettlementOpen(settlement) {
+			batch.SkippedTradeIDs = append(batch.SkippedTradeIDs, trade.TradeID)
+			continue
+		}
+
+		amount, err := settleableAmount(trade)
+		if err != nil {
+			return nil, err
+		}
+		feeValue, _ := schedule.feeFor(trade, fromUnits(amount))
+		fee := toUnits(feeValue)
+
+		instruction.Escrows = append(instruction.Escrows, batchEscrow{
+			RefID:         trade.BuyOrderID,
+			OwnerID:       trade.BuyerID,
+			BeneficiaryID: trade.SellerID,
+			Amount:        formatUnits(amount),
+			Fee:           formatUnits(fee),
+		})
+
+		partyA, partyB := trade.BuyerID, trade.SellerID
+		if partyB < partyA {
+			partyA, partyB = partyB, partyA
+		}
+		pairKey := partyA + "\x00" + partyB
+		pair, ok := pairs[pairKey]
+		if !ok {
+			pair = &pairNet{obligation: NetObligation{PartyA: partyA, PartyB: partyB, TradeIDs: []string{}, Legs: []ObligationLeg{}}}
+			pairs[pairKey] = pair
+		}
+		if trade.BuyerID == partyA {
+			pair.aToB += amount
+		} else {
+			pair.bToA += amount
+		}
+		pair.obligation.TradeIDs = append(pair.obligation.TradeIDs, trade.TradeID)
+		pair.obligation.Legs = append(pair.obligation.Legs, ObligationLeg{
+			TradeID:  trade.TradeID,
+			BuyerID:  trade.BuyerID,
+			SellerID: trade.SellerID,
+			Amount:   fromUnits(amount),
+			Fee:      fromUnits(fee),
+		})
+
+		sellerFees[trade.SellerID] += fee
+		gross += amount
+		totalFee += fee
+		batch.TradeIDs = append(batch.TradeIDs, trade.TradeID)
+	}
+
+	if len(batch.TradeIDs) == 0 {
+		return nil, fmt.Errorf("정산 구간의 확정된 거래 %d건 모두 개별 정산이 진행 중입니다", len(batch.SkippedTradeIDs))
+	}
+
+	pairKeys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		pairKeys = append(pairKeys, key)
+	}
+	sort.Strings(pairKeys)
+
+	var netTransfer int64
+	for _, key := range pairKeys {
+		pair := pairs[key]
+		obligation := pair.obligation
+		obligation.GrossAToB = fromUnits(pair.aToB)
+		obligation.GrossBToA = fromUnits(pair.bToA)
+
+		net := pair.aToB - pair.bToA
+		switch {
+		case net > 0:
+			obligation.PayerID, obligation.PayeeID = obligation.PartyA, obligation.PartyB
+		case net < 0:
+			obligation.PayerID, obligation.PayeeID = obligation.PartyB, obligation.PartyA
+			net = -net
+		}
+		obligation.NetAmount = fromUnits(net)
+
+		if net > 0 {
+			instruction.Transfers = append(instruction.Transfers, batchTransfer{From: obligation.PayerID, To: obligation.PayeeID, Amount: formatUnits(net)})
+			netTransfer += net
+		}
+		batch.Obligations = append(batch.Obligations, obligation)
+	}
+
+	sellers := make([]string, 0, len(sellerFees))
+	for sellerID := range sellerFees {
+		sellers = append(sellers, sellerID)
+	}
+	sort.Strings(sellers)
+	for _, sellerID := range sellers {
+		fee := sellerFees[sellerID]
+		if fee == 0 || sellerID == schedule.FeeCollectorID {
+			continue
+		}
+		instruction.Transfers = append(instruction.Transfers, batchTransfer{From: sellerID, To: schedule.FeeCollectorID, Amount: formatUnits(fee)})
+	}
+
+	batch.GrossAmount = fromUnits(gross)
+	batch.NetTransferAmount = fromUnits(netTransfer)
+	batch.TotalFee = fromUnits(totalFee)
+
+	instructionJSON, err := json.Marshal(instruction)
+	if err != nil {
+		return nil, common.Failed("일괄 정산 지시 직렬화", err)
+	}
+	if _, err := common.InvokeChaincode(ctx, epcChaincode, "SettleBatch", string(instructionJSON)); err != nil {
+		return nil, err
+	}
+
+	for _, tradeID := range batch.TradeIDs {
+		if _, err := common.InvokeChaincode(ctx, tradingChaincode, "UpdateTradeStatus", tradeID, "SETTLED"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := common.PutState(ctx, batchKey(batchID), batch, "일괄 정산"); err != nil {
+		return nil, err
+	}
+
+	err = common.SetEvent(ctx, "BatchSettled", BatchSettledEvent{
+		BatchID:           batchID,
+		TradeCount:        len(batch.TradeIDs),
+		SkippedCount:      len(batch.SkippedTradeIDs),
+		ObligationCount:   len(batch.Obligations),
+		GrossAmount:       batch.GrossAmount,
+		NetTransferAmount: batch.NetTransferAmount,
+		TotalFee:          batch.TotalFee,
+		SettledAt:         now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+// GetSettlementBatch - 일괄 정산 기록 조회
+func (c *SettlementContract) GetSettlementBatch(ctx contractapi.TransactionContextInterface, batchID string) (*SettlementBatch, error) {
+	return common.MustGetState[SettlementBatch](ctx, batchKey(batchID), "일괄 정산", batchID)
+}
+
+// ExplainNetObligation - 일괄 정산에서 두 참여자 간 순액이 어떤 거래로 구성되었는지 조회
+func (c *SettlementContract) ExplainNetObligation(ctx contractapi.TransactionContextInterface, batchID string, partyA string, partyB string) (*NetObligation, error) {
+	batch, err := c.GetSettlementBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if partyB < partyA {
+		partyA, partyB = partyB, partyA
+	}
+	for i := range batch.Obligations {
+		if batch.Obligations[i].PartyA == partyA && batch.Obligations[i].PartyB == partyB {
+			return &batch.Obligations[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("일괄 정산 %s 에 %s ↔ %s 간 거래가 없습니다", batchID, partyA, partyB)
+}
+
+// ========== 내부 헬퍼 ==========
+
+func batchKey(batchID string) string {
+	return "BATCH_" + batchID
+}
+
+// getConfirmedTrades - trading 체인코드에서 정산 구간의 CONFIRMED 거래 조회
+func getConfirmedTrades(ctx contractapi.TransactionContextInterface, windowStart string, windowEnd string) ([]tradeView, error) {
+	payload, err := common.InvokeChaincode(ctx, tradingChaincode, "GetConfirmedTrades", windowStart, windowEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := []tradeView{}
+	if len(payload) == 0 {
+		return trades, nil
+	}
+	if err := json.Unmarshal(payload, &trades); err != nil {
+		return nil, common.Failed("거래 목록 역직렬화", err)
+	}
+
+	return trades, nil
+}
+
+// toUnits - float64 EPC 금액을 기본 단위로 변환 (maxExactUnits 이하에서 fromUnits와 정확히 왕복)
+func toUnits(value float64) int64 {
+	return int64(math.Round(value * unitsPerEPC))
+}
+
+func fromUnits(units int64) float64 {
+	return float64(units) / unitsPerEPC
+}
+
+// parseUnits - EPC 체인코드 금액 문자열(소수 최대 epcDecimals자리)을 기본 단위로 변환
+func parseUnits(value string) (int64, error) {
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || len(fraction) > epcDecimals {
+		return 0, fmt.Errorf("잘못된 EPC 금액: %q", value)
+	}
+	fraction += strings.Repeat("0", epcDecimals-len(fraction))
+
+	wholeUnits, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || wholeUnits < 0 || strings.HasPrefix(whole, "+") {
+		return 0, fmt.Errorf("잘못된 EPC 금액: %q", value)
+	}
+	fractionUnits, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil || strings.ContainsAny(fraction, "+-") {
+		return 0, fmt.Errorf("잘못된 EPC 금액: %q", value)
+	}
+	if wholeUnits > (math.MaxInt64-fractionUnits)/int64(unitsPerEPC) {
+		return 0, fmt.Errorf("EPC 금액이 너무 큽니다: %q", value)
+	}
+
+	return wholeUnits*int64(unitsPerEPC) + fractionUnits, nil
+}
+
+// formatUnits - 기본 단위 금액을 EPC 체인코드 금액 문자열로 변환
+func formatUnits(units int64) string {
+	return fmt.Sprintf("%d.%0*d", units/int64(unitsPerEPC), epcDecimals, units%int64(unitsPerEPC))
+}
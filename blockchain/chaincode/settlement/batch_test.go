@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func confirmedTradesJSON(trades ...tradeView) string {
+	for i := range trades {
+		trades[i].EnergySource = "SOLAR"
+		trades[i].Status = "CONFIRMED"
+	}
+	payload, _ := json.Marshal(trades)
+	return string(payload)
+}
+
+func (env *settlementTestEnv) settleBatch(batchID string) *SettlementBatch {
+	env.t.Helper()
+	var batch SettlementBatch
+	if err := json.Unmarshal(env.mustInvoke("SettleBatch", batchID, "2026-01-01T00:00:00Z", "2026-01-02T00:00:00Z"), &batch); err != nil {
+		env.t.Fatalf("일괄 정산 역직렬화 실패: %v", err)
+	}
+	return &batch
+}
+
+// batchInstructionOf - epc.SettleBatch에 전달된 지시
+func (env *settlementTestEnv) batchInstructionOf() *batchInstruction {
+	env.t.Helper()
+	calls := env.epc.called("SettleBatch")
+	if len(calls) != 1 {
+		env.t.Fatalf("epc SettleBatch 호출 %d회, want 1", len(calls))
+	}
+	var instruction batchInstruction
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(calls[0], "SettleBatch ")), &instruction); err != nil {
+		env.t.Fatalf("일괄 정산 지시 역직렬화 실패: %v", err)
+	}
+	return &instruction
+}
+
+// TestSettleBatchNetting - 같은 거래 상대방 쌍의 양방향 거래를 상계해 순액만 이체한다
+func TestSettleBatchNetting(t *testing.T) {
+	env := newSettlementTestEnv(t)
+	env.trading.responses["GetConfirmedTrades"] = confirmedTradesJSON(
+		tradeView{TradeID: "T1", BuyOrderID: "O1", BuyerID: "alice", SellerID: "bob", TotalAmount: 10},
+		tradeView{TradeID: "T2", BuyOrderID: "O2", BuyerID: "bob", SellerID: "alice", TotalAmount: 4},
+		tradeView{TradeID: "T3", BuyOrderID: "O3", BuyerID: "alice", SellerID: "bob", TotalAmount: 0.5},
+		tradeView{TradeID: "T4", BuyOrderID: "O4", BuyerID: "carol", SellerID: "bob", TotalAmount: 3},
+	)
+
+	batch := env.settleBatch("B1")
+
+	if len(batch.Obligations) != 2 {
+		t.Fatalf("상계 쌍 %d개, want 2: %+v", len(batch.Obligations), batch.Obligations)
+	}
+	aliceBob := batch.Obligations[0]
+	if aliceBob.PartyA != "alice" || aliceBob.PartyB != "bob" ||
+		aliceBob.GrossAToB != 10.5 || aliceBob.GrossBToA != 4 ||
+		aliceBob.PayerID != "alice" || aliceBob.PayeeID != "bob" || aliceBob.NetAmount != 6.5 {
+		t.Errorf("alice ↔ bob 상계 = %+v, want alice → bob 6.5 (10.5 - 4)", aliceBob)
+	}
+	if got, want := aliceBob.TradeIDs, []string{"T1", "T2", "T3"}; !equalStrings(got, want) {
+		t.Errorf("alice ↔ bob 거래 = %q, want %q", got, want)
+	}
+	bobCarol := batch.Obligations[1]
+	if bobCarol.PartyA != "bob" || bobCarol.PartyB != "carol" ||
+		bobCarol.PayerID != "carol" || bobCarol.PayeeID != "bob" || bobCarol.NetAmount != 3 {
+		t.Errorf("bob ↔ carol 상계 = %+v, want carol → bob 3", bobCarol)
+	}
+	if batch.GrossAmount != 17.5 || batch.NetTransferAmount != 9.5 || batch.TotalFee != 0.35 {
+		t.Errorf("합계 = 대금 %v, 순이체 %v, 수수료 %v, want 17.5, 9.5, 0.35", batch.GrossAmount, batch.NetTransferAmount, batch.TotalFee)
+	}
+
+	instruction := env.batchInstructionOf()
+	if len(instruction.Escrows) != 4 {
+		t.Errorf("에스크로 해제 %d건, want 4", len(instruction.Escrows))
+	}
+	wantTransfers := []batchTransfer{
+		{From: "alice", To: "bob", Amount: "6.500000"},
+		{From: "carol", To: "bob", Amount: "3.000000"},
+		{From: "alice", To: defaultFeeCollectorID, Amount: "0.080000"},
+		{From: "bob", To: defaultFeeCollectorID, Amount: "0.270000"},
+	}
+	if len(instruction.Transfers) != len(wantTransfers) {
+		t.Fatalf("이체 = %+v, want %+v", instruction.Transfers, wantTransfers)
+	}
+	for i := range wantTransfers {
+		if instruction.Transfers[i] != wantTransfers[i] {
+			t.Errorf("이체[%d] = %+v, want %+v", i, instruction.Transfers[i], wantTransfers[i])
+		}
+	}
+
+	if got := len(env.trading.called("UpdateTradeStatus")); got != 4 {
+		t.Errorf("UpdateTradeStatus 호출 %d회, want 4", got)
+	}
+}
+
+// TestSettleBatchNettingReversed - 순액 방향은 쌍의 정렬 순서가 아니라 대금이 큰 쪽을 따른다
+func TestSettleBatchNettingReversed(t *testing.T) {
+	tests := []struct {
+		name      string
+		trades    []tradeView
+		wantPayer string
+		wantNet   float64
+		wantMoves int // 순액 이체 건수
+	}{
+		{
+			name: "PartyB가 더 많이 구매",
+			trades: []tradeView{
+				{TradeID: "T1", BuyOrderID: "O1", BuyerID: "alice", SellerID: "bob", TotalAmount: 2},
+				{TradeID: "T2", BuyOrderID: "O2", BuyerID: "bob", SellerID: "alice", TotalAmount: 7.25},
+			},
+			wantPayer: "bob",
+			wantNet:   5.25,
+			wantMoves: 1,
+		},
+		{
+			name: "양방향 대금이 같음",
+			trades: []tradeView{
+				{TradeID: "T1", BuyOrderID: "O1", BuyerID: "alice", SellerID: "bob", TotalAmount: 3},
+				{TradeID: "T2", BuyOrderID: "O2", BuyerID: "bob", SellerID: "alice", TotalAmount: 3},
+			},
+			wantPayer: "",
+			wantNet:   0,
+			wantMoves: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newSettlementTestEnv(t)
+			env.trading.responses["GetConfirmedTrades"] = confirmedTradesJSON(tt.trades...)
+
+			batch := env.settleBatch("B1")
+			obligation := batch.Obligations[0]
+			if obligation.PayerID != tt.wantPayer || obligation.NetAmount != tt.wantNet {
+				t.Errorf("상계 = %s → %s %v, want %s 지급 %v", obligation.PayerID, obligation.PayeeID, obligation.NetAmount, tt.wantPayer, tt.wantNet)
+			}
+
+			moves := 0
+			for _, transfer := range env.batchInstructionOf().Transfers {
+				if transfer.To != defaultFeeCollectorID {
+					moves++
+				}
+			}
+			if moves != tt.wantMoves {
+				t.Errorf("순액 이체 %d건, want %d", moves, tt.wantMoves)
+			}
+		})
+	}
+}
+
+// TestSettleBatchSkipsOpenSettlements - 개별 정산이 진행 중인 거래는 일괄 정산에서 제외한다
+func TestSettleBatchSkipsOpenSettlements(t *testing.T) {
+	env := newSettlementTestEnv(t)
+	env.trading.responses["GetTrade"] = tradeJSON("T1", "O1", "alice", "bob", 10)
+	env.mustInvoke("CreateSettlement", "S1", "T1", "alice", "bob", "10")
+	env.mustInvoke("StartProcessing", "S1")
+	env.trading.responses["GetConfirmedTrades"] = confirmedTradesJSON(
+		tradeView{TradeID: "T1", BuyOrderID: "O1", BuyerID: "alice", SellerID: "bob", TotalAmount: 10},
+		tradeView{TradeID: "T2", BuyOrderID: "O2", BuyerID: "bob", SellerID: "alice", TotalAmount: 4},
+	)
+
+	batch := env.settleBatch("B1")
+
+	if got, want := batch.TradeIDs, []string{"T2"}; !equalStrings(got, want) {
+		t.Errorf("정산 거래 = %q, want %q", got, want)
+	}
+	if got, want := batch.SkippedTradeIDs, []string{"T1"}; !equalStrings(got, want) {
+		t.Errorf("제외 거래 = %q, want %q", got, want)
+	}
+	if got, want := env.trading.called("UpdateTradeStatus"), []string{"UpdateTradeStatus T2 SETTLED"}; !equalStrings(got, want) {
+		t.Errorf("UpdateTradeStatus 호출 = %q, want %q", got, want)
+	}
+	for _, escrow := range env.batchInstructionOf().Escrows {
+		if escrow.RefID == "O1" {
+			t.Errorf("진행 중인 정산의 에스크로가 일괄 정산에 포함되었습니다: %+v", escrow)
+		}
+	}
+	if record := env.settlement("S1"); record.Status != StatusProcessing {
+		t.Errorf("개별 정산 상태 = %s, want PROCESSING", record.Status)
+	}
+
+	// 모든 거래에 진행 중인 정산이 있으면 일괄 정산할 거래가 없다
+	env.trading.responses["GetConfirmedTrades"] = confirmedTradesJSON(
+		tradeView{TradeID: "T1", BuyOrderID: "O1", BuyerID: "alice", SellerID: "bob", TotalAmount: 10},
+	)
+	response := env.invoke(operatorIdentity, "SettleBatch", "B2", "2026-01-01T00:00:00Z", "2026-01-02T00:00:00Z")
+	if response.Status == shim.OK || !strings.Contains(response.Message, "개별 정산이 진행 중입니다") {
+		t.Errorf("SettleBatch = %d %q, want 진행 중인 정산 오류", response.Status, response.Message)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const feeScheduleKey = "FEE_SCHEDULE"
+
+// defaultFeeCollectorID - 수수료 일정이 저장되지 않았을 때의 수수료 수취 EPC 계정
+const defaultFeeCollectorID = "ETP_FEE_ACCOUNT"
+
+// FeeRule - 수수료율 규칙
+//
+// 수수료는 판매자 수령액에서 차감된다. 판매자 주문이 호가창에 먼저 있던 경우(메이커)
+// MakerRate, 그 외(테이커 또는 장외 체결)에는 TakerRate를 적용한다.
+type FeeRule struct {
+	MakerRate  float64 `json:"makerRate"`  // 0.01 = 1%
+	TakerRate  float64 `json:"takerRate"`  // 0.01 = 1%
+	MinimumFee float64 `json:"minimumFee"` // 최소 수수료 (EPC)
+}
+
+// FeeSchedule - 온체인 수수료 일정
+//
+// 적용 우선순위: 판매자 MSP 재정의 > 에너지원 재정의 > 기본 규칙
+type FeeSchedule struct {
+	Default        FeeRule            `json:"default"`
+	EnergySources  map[string]FeeRule `json:"energySources"`
+	Orgs           map[string]FeeRule `json:"orgs"`
+	FeeCollectorID string             `json:"feeCollectorId"`
+	Version        int                `json:"version"`
+	UpdatedAt      string             `json:"updatedAt"`
+	UpdatedBy      string             `json:"updatedBy"`
+}
+
+// defaultFeeSchedule - 백엔드 PLATFORM_FEE_RATE(2%)와 같은 기본 일정
+func defaultFeeSchedule() *FeeSchedule {
+	return &FeeSchedule{
+		Default:        FeeRule{MakerRate: 0.02, TakerRate: 0.02},
+		EnergySources:  map[string]FeeRule{},
+		Orgs:           map[string]FeeRule{},
+		FeeCollectorID: defaultFeeCollectorID,
+	}
+}
+
+// GetFeeSchedule - 현재 수수료 일정 조회
+func (c *SettlementContract) GetFeeSchedule(ctx contractapi.TransactionContextInterface) (*FeeSchedule, error) {
+	schedule, err := common.GetState[FeeSchedule](ctx, feeScheduleKey, "수수료 일정")
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return defaultFeeSchedule(), nil
+	}
+
+	return schedule, nil
+}
+
+// SetFeeSchedule - 수수료 일정 변경 (관리자 전용)
+func (c *SettlementContract) SetFeeSchedule(ctx contractapi.TransactionContextInterface, scheduleJSON string) error {
+	caller, err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	var schedule FeeSchedule
+	if err := json.Unmarshal([]byte(scheduleJSON), &schedule); err != nil {
+		return fmt.Errorf("수수료 일정 역직렬화 실패: %v", err)
+	}
+	if schedule.FeeCollectorID == "" {
+		return fmt.Errorf("feeCollectorId는 필수입니다")
+	}
+	if err := schedule.Default.validate("default"); err != nil {
+		return err
+	}
+	if schedule.EnergySources == nil {
+		schedule.EnergySources = map[string]FeeRule{}
+	}
+	if schedule.Orgs == nil {
+		schedule.Orgs = map[string]FeeRule{}
+	}
+	for source, rule := range schedule.EnergySources {
+		if err := rule.validate("energySources." + source); err != nil {
+			return err
+		}
+	}
+	for org, rule := range schedule.Orgs {
+		if err := rule.validate("orgs." + org); err != nil {
+			return err
+		}
+	}
+
+	current, err := c.GetFeeSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	schedule.Version = current.Version + 1
+	schedule.UpdatedAt = now
+	schedule.UpdatedBy = caller.ID
+
+	if err := common.PutState(ctx, feeScheduleKey, schedule, "수수료 일정"); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "FeeScheduleUpdated", schedule)
+}
+
+// GetFeeScheduleHistory - 수수료 일정 변경 이력
+func (c *SettlementContract) GetFeeScheduleHistory(ctx contractapi.TransactionContextInterface) ([]FeeSchedule, error) {
+	return common.GetHistory[FeeSchedule](ctx, feeScheduleKey, "수수료 일정")
+}
+
+// ========== 내부 헬퍼 ==========
+
+// ruleFor - 거래에 적용할 수수료 규칙
+func (s *FeeSchedule) ruleFor(trade *tradeView) FeeRule {
+	if rule, ok := s.Orgs[trade.SellerMSPID]; ok && trade.SellerMSPID != "" {
+		return rule
+	}
+	if rule, ok := s.EnergySources[trade.EnergySource]; ok {
+		return rule
+	}
+	return s.Default
+}
+
+// feeFor - 정산 금액에 대한 수수료 (EPC 소수 자릿수로 반올림, 정산 금액 초과 불가)
+func (s *FeeSchedule) feeFor(trade *tradeView, amount float64) (float64, float64) {
+	rule := s.ruleFor(trade)
+
+	rate := rule.TakerRate
+	if trade.TakerSide == "BUY" {
+		// 매수 주문이 테이커이면 판매자는 메이커
+		rate = rule.MakerRate
+	}
+
+	fee := math.Max(amount*rate, rule.MinimumFee)
+	fee = math.Min(fee, amount)
+
+	scale := math.Pow10(epcDecimals)
+	return math.Round(fee*scale) / scale, rate
+}
+
+func (r FeeRule) validate(label string) error {
+	for _, v := range []float64{r.MakerRate, r.TakerRate} {
+		if math.IsNaN(v) || v < 0 || v >= 1 {
+			return fmt.Errorf("%s: 수수료율은 0 이상 1 미만이어야 합니다: %v", label, v)
+		}
+	}
+	if math.IsNaN(r.MinimumFee) || r.MinimumFee < 0 {
+		return fmt.Errorf("%s: 최소 수수료는 0 이상이어야 합니다: %v", label, r.MinimumFee)
+	}
+	return nil
+}
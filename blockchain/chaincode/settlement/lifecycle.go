@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 정산 상태
+const (
+	StatusPending    = "PENDING"
+	StatusProcessing = "PROCESSING"
+	StatusCompleted  = "COMPLETED"
+	StatusFailed     = "FAILED"
+)
+
+// maxSettlementAttempts - 재시도를 포함한 최대 처리 시도 횟수
+const maxSettlementAttempts = 5
+
+// settlementTransitions - 현재 상태 → 허용되는 다음 상태
+//
+// COMPLETED는 종료 상태다. FAILED는 RetrySettlement로만 PENDING에 돌아갈 수 있다.
+var settlementTransitions = map[string][]string{
+	StatusPending:    {StatusProcessing, StatusCompleted, StatusFailed},
+	StatusProcessing: {StatusCompleted, StatusFailed},
+	StatusFailed:     {StatusPending},
+	StatusCompleted:  {},
+}
+
+// SettlementStatusChangedEvent - 정산 상태 변경 이벤트 (재시도 큐 구성용)
+type SettlementStatusChangedEvent struct {
+	SettlementID  string `json:"settlementId"`
+	TradeID       string `json:"tradeId"`
+	OldStatus     string `json:"oldStatus"`
+	NewStatus     string `json:"newStatus"`
+	Attempts      int    `json:"attempts"`
+	FailureCode   string `json:"failureCode"`
+	FailureReason string `json:"failureReason"`
+	ActorID       string `json:"actorId"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// StartProcessing - 정산 처리 시작 (PENDING → PROCESSING, 시도 횟수 증가)
+func (c *SettlementContract) StartProcessing(ctx contractapi.TransactionContextInterface, settlementID string) error {
+	record, err := c.GetSettlement(ctx, settlementID)
+	if err != nil {
+		return err
+	}
+
+	return c.changeSettlementStatus(ctx, record, StatusProcessing, func(r *SettlementRecord, now string) error {
+		if r.Attempts >= maxSettlementAttempts {
+			return fmt.Errorf("최대 시도 횟수(%d)를 초과했습니다: %s", maxSettlementAttempts, r.SettlementID)
+		}
+		r.Attempts++
+		r.LastAttemptAt = now
+		return nil
+	})
+}
+
+// RetrySettlement - 실패한 정산을 재시도 대기로 되돌림 (FAILED → PENDING)
+//
+// 실패 사유는 다음 실패 또는 완료 시까지 남겨 두어 재시도 근거를 추적할 수 있게 한다.
+func (c *SettlementContract) RetrySettlement(ctx contractapi.TransactionContextInterface, settlementID string) error {
+	record, err := c.GetSettlement(ctx, settlementID)
+	if err != nil {
+		return err
+	}
+
+	return c.changeSettlementStatus(ctx, record, StatusPending, func(r *SettlementRecord, now string) error {
+		if r.Attempts >= maxSettlementAttempts {
+			return fmt.Errorf("최대 시도 횟수(%d)를 초과하여 재시도할 수 없습니다: %s", maxSettlementAttempts, r.SettlementID)
+		}
+		return nil
+	})
+}
+
+// ========== 내부 헬퍼 ==========
+
+// changeSettlementStatus - 전이 규칙과 운영자 권한 검사 후 상태 변경 및 SettlementStatusChanged 이벤트 발생
+//
+// apply는 상태 변경 전에 추가 검사와 필드 갱신을 수행한다.
+func (c *SettlementContract) changeSettlementStatus(ctx contractapi.TransactionContextInterface, record *SettlementRecord, newStatus string, apply func(r *SettlementRecord, now string) error) error {
+	caller, err := requireOperator(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSettlementTransition(record, newStatus); err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldStatus := record.Status
+	if apply != nil {
+		if err := apply(record, now); err != nil {
+			return err
+		}
+	}
+	record.Status = newStatus
+	record.UpdatedAt = now
+
+	if err := common.PutState(ctx, record.SettlementID, record, "정산"); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "SettlementStatusChanged", SettlementStatusChangedEvent{
+		SettlementID:  record.SettlementID,
+		TradeID:       record.TradeID,
+		OldStatus:     oldStatus,
+		NewStatus:     newStatus,
+		Attempts:      record.Attempts,
+		FailureCode:   record.FailureCode,
+		FailureReason: record.FailureReason,
+		ActorID:       caller.ID,
+		Timestamp:     now,
+	})
+}
+
+func checkSettlementTransition(record *SettlementRecord, newStatus string) error {
+	next, ok := settlementTransitions[record.Status]
+	if !ok {
+		return fmt.Errorf("정산 %s 의 현재 상태를 알 수 없습니다: %s", record.SettlementID, record.Status)
+	}
+	if !common.Contains(next, newStatus) {
+		return fmt.Errorf("허용되지 않은 상태 전이: %s → %s (정산: %s)", record.Status, newStatus, record.SettlementID)
+	}
+	return nil
+}
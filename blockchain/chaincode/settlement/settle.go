@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 연동 체인코드 이름 (network/scripts/setup-network.sh, backend .env 기준)
+const (
+	tradingChaincode = "trading-cc"
+	epcChaincode     = "epc-cc"
+)
+
+// epcDecimals - EPC 금액 문자열 소수 자릿수 (epc 체인코드 EPCDecimals)
+const epcDecimals = 6
+
+// tradeView - 정산 검증에 필요한 trading 체인코드 거래 필드
+type tradeView struct {
+	TradeID      string  `json:"tradeId"`
+	BuyOrderID   string  `json:"buyOrderId"` // 구매 주문 ID = 구매자 EPC 에스크로 refID
+	BuyerID      string  `json:"buyerId"`
+	SellerID     string  `json:"sellerId"`
+	SellerMSPID  string  `json:"sellerMspId"`
+	EnergySource string  `json:"energySource"`
+	TotalAmount  float64 `json:"totalAmount"`
+	Status       string  `json:"status"`
+	TakerSide    string  `json:"takerSide"` // 온체인 매칭 거래만 기록됨
+}
+
+// escrowView - 정산 검증에 필요한 epc 체인코드 에스크로 필드
+type escrowView struct {
+	RefID     string `json:"refId"`
+	OwnerID   string `json:"ownerId"`
+	Remaining string `json:"remaining"` // EPC 금액 문자열
+	Status    string `json:"status"`
+}
+
+// TradeSettledEvent - 정산 완료 이벤트
+type TradeSettledEvent struct {
+	SettlementID string  `json:"settlementId"`
+	TradeID      string  `json:"tradeId"`
+	BuyerID      string  `json:"buyerId"`
+	SellerID     string  `json:"sellerId"`
+	Amount       float64 `json:"amount"`
+	Fee          float64 `json:"fee"`
+	NetAmount    float64 `json:"netAmount"`
+	FeeAccountID string  `json:"feeAccountId"`
+	SettledAt    string  `json:"settledAt"`
+}
+
+// SettleTrade - 대금 지급과 거래 확정을 하나의 트랜잭션으로 처리 (DvP)
+//
+// 구매 주문 에스크로의 잠금 EPC에서 판매자에게 순액, 수수료 계정에 수수료를 지급하고(epc.SettleLocked)
+// 거래를 SETTLED로(trading.UpdateTradeStatus), 정산을 COMPLETED로 변경한다.
+// 지급액은 CONFIRMED 거래의 TotalAmount이며 정산 기록 금액과 다르면 거부한다.
+// 지급 전에 구매 주문 에스크로가 구매자 소유의 OPEN 에스크로이고 잔여 잠금액이
+// 지급 총액(판매자 순액 + 수수료 = 거래 금액) 이상인지 확인한다. 정산 운영자 전용이며,
+// 호출되는 체인코드도 원 제출자 신원으로 권한을 다시 검사한다.
+func (c *SettlementContract) SettleTrade(ctx contractapi.TransactionContextInterface, settlementID string) error {
+	if _, err := requireOperator(ctx); err != nil {
+		return err
+	}
+
+	record, err := c.GetSettlement(ctx, settlementID)
+	if err != nil {
+		return err
+	}
+	if err := checkSettlementTransition(record, StatusCompleted); err != nil {
+		return err
+	}
+
+	trade, amount, err := settleableTrade(ctx, record)
+	if err != nil {
+		return err
+	}
+	fee := toUnits(record.Fee)
+	if fee < 0 || fee > amount {
+		return fmt.Errorf("정산 수수료가 올바르지 않습니다: %v", record.Fee)
+	}
+
+	if err := checkEscrow(ctx, trade.BuyOrderID, record.BuyerID, amount); err != nil {
+		return err
+	}
+
+	feeCollectorID := record.FeeCollectorID
+	if feeCollectorID == "" {
+		feeCollectorID = defaultFeeCollectorID
+	}
+
+	_, err = common.InvokeChaincode(ctx, epcChaincode, "SettleLocked",
+		record.BuyerID, record.SellerID, formatUnits(amount), formatUnits(fee), feeCollectorID, trade.BuyOrderID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := common.InvokeChaincode(ctx, tradingChaincode, "UpdateTradeStatus", record.TradeID, "SETTLED"); err != nil {
+		return err
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	record.Status = StatusCompleted
+	record.UpdatedAt = now
+	record.SettledAt = now
+	if err := common.PutState(ctx, settlementID, record, "정산"); err != nil {
+		return err
+	}
+
+	// 호출된 체인코드의 이벤트는 전달되지 않으므로 정산 이벤트 하나로 결과를 알린다
+	return common.SetEvent(ctx, "TradeSettled", TradeSettledEvent{
+		SettlementID: record.SettlementID,
+		TradeID:      record.TradeID,
+		BuyerID:      record.BuyerID,
+		SellerID:     record.SellerID,
+		Amount:       record.Amount,
+		Fee:          record.Fee,
+		NetAmount:    record.NetAmount,
+		FeeAccountID: feeCollectorID,
+		SettledAt:    now,
+	})
+}
+
+// getTrade - trading 체인코드에서 거래 조회
+func getTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*tradeView, error) {
+	payload, err := common.InvokeChaincode(ctx, tradingChaincode, "GetTrade", tradeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var trade tradeView
+	if err := json.Unmarshal(payload, &trade); err != nil {
+		return nil, common.Failed("거래 역직렬화", err)
+	}
+
+	return &trade, nil
+}
+
+// checkEscrow - refID 에스크로가 ownerID 소유의 OPEN 에스크로이고 잔여 잠금액이 required(기본 단위) 이상인지 검사
+func checkEscrow(ctx contractapi.TransactionContextInterface, refID string, ownerID string, required int64) error {
+	if refID == "" {
+		return fmt.Errorf("거래에 구매 주문 에스크로가 없습니다")
+	}
+
+	payload, err := common.InvokeChaincode(ctx, epcChaincode, "GetEscrow", refID)
+	if err != nil {
+		return err
+	}
+	var escrow escrowView
+	if err := json.Unmarshal(payload, &escrow); err != nil {
+		return common.Failed("에스크로 역직렬화", err)
+	}
+
+	if escrow.OwnerID != ownerID {
+		return fmt.Errorf("에스크로 소유자가 구매자와 일치하지 않습니다: %s 의 소유자는 %s 입니다 (구매자: %s)", refID, escrow.OwnerID, ownerID)
+	}
+	if escrow.Status != "OPEN" {
+		return fmt.Errorf("잠금 중인 에스크로가 아닙니다: %s (%s)", refID, escrow.Status)
+	}
+	remaining, err := parseUnits(escrow.Remaining)
+	if err != nil {
+		return common.Failed("에스크로 잔여 잠금액 해석", err)
+	}
+	if remaining < required {
+		return fmt.Errorf("에스크로 잔여 잠금액이 부족합니다: %s 잔여 %s, 필요 %s", refID, formatUnits(remaining), formatUnits(required))
+	}
+	return nil
+}
+
+// settleableTrade - 정산 기록의 거래를 조회해 참여자와 금액이 정산 기록과 일치하는지 검사하고 금액을 EPC 기본 단위로 반환
+func settleableTrade(ctx contractapi.TransactionContextInterface, record *SettlementRecord) (*tradeView, int64, error) {
+	trade, err := getTrade(ctx, record.TradeID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if trade.BuyerID != record.BuyerID || trade.SellerID != record.SellerID {
+		return nil, 0, fmt.Errorf("정산 참여자가 거래와 일치하지 않습니다: 거래 %s/%s, 정산 %s/%s", trade.BuyerID, trade.SellerID, record.BuyerID, record.SellerID)
+	}
+	amount, err := settleableAmount(trade)
+	if err != nil {
+		return nil, 0, err
+	}
+	if toUnits(record.Amount) != amount {
+		return nil, 0, fmt.Errorf("정산 금액이 거래 금액과 일치하지 않습니다: 거래 %s, 정산 %v", formatUnits(amount), record.Amount)
+	}
+
+	return trade, amount, nil
+}
+
+// settleableAmount - 정산 가능한(CONFIRMED) 거래의 금액을 EPC 기본 단위로 반환
+func settleableAmount(trade *tradeView) (int64, error) {
+	if trade.Status != "CONFIRMED" {
+		return 0, fmt.Errorf("확정되지 않은 거래는 정산할 수 없습니다: %s (%s)", trade.TradeID, trade.Status)
+	}
+	if trade.TotalAmount > fromUnits(maxExactUnits) {
+		return 0, fmt.Errorf("거래 금액이 너무 큽니다: %s (%v)", trade.TradeID, trade.TotalAmount)
+	}
+	amount := toUnits(trade.TotalAmount)
+	if amount <= 0 {
+		return 0, fmt.Errorf("거래 금액이 올바르지 않습니다: %s (%v)", trade.TradeID, trade.TotalAmount)
+	}
+	return amount, nil
+}
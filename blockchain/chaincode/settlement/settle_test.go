@@ -0,0 +1,419 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// fakeChaincode - 연동 체인코드(epc-cc, trading-cc) 대역
+//
+// 함수별 응답과 오류를 지정하고 호출 내역을 기록한다.
+type fakeChaincode struct {
+	responses map[string]string // 함수 -> 응답 payload
+	failures  map[string]string // 함수 -> 오류 메시지
+	calls     []string          // "함수 인자1 인자2 ..."
+}
+
+func newFakeChaincode() *fakeChaincode {
+	return &fakeChaincode{responses: map[string]string{}, failures: map[string]string{}}
+}
+
+func (f *fakeChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (f *fakeChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	f.calls = append(f.calls, strings.Join(append([]string{function}, args...), " "))
+	if message, ok := f.failures[function]; ok {
+		return shim.Error(message)
+	}
+	return shim.Success([]byte(f.responses[function]))
+}
+
+// called - function으로 시작하는 호출 내역
+func (f *fakeChaincode) called(function string) []string {
+	calls := []string{}
+	for _, call := range f.calls {
+		if call == function || strings.HasPrefix(call, function+" ") {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// settlementTestEnv - 정산 체인코드와 연동 체인코드 대역
+type settlementTestEnv struct {
+	t       *testing.T
+	stub    *shimtest.MockStub
+	epc     *fakeChaincode
+	trading *fakeChaincode
+	txSeq   int
+}
+
+var (
+	operatorIdentity []byte
+	buyerIdentity    []byte
+)
+
+func newSettlementTestEnv(t *testing.T) *settlementTestEnv {
+	t.Helper()
+
+	chaincode, err := contractapi.NewChaincode(&SettlementContract{})
+	if err != nil {
+		t.Fatalf("체인코드 생성 실패: %v", err)
+	}
+
+	env := &settlementTestEnv{
+		t:       t,
+		stub:    shimtest.NewMockStub("settlement-cc", chaincode),
+		epc:     newFakeChaincode(),
+		trading: newFakeChaincode(),
+	}
+	env.stub.MockPeerChaincode(epcChaincode, shimtest.NewMockStub(epcChaincode, env.epc), "")
+	env.stub.MockPeerChaincode(tradingChaincode, shimtest.NewMockStub(tradingChaincode, env.trading), "")
+
+	if operatorIdentity == nil {
+		operatorIdentity = newIdentity(t, "AdminOrgMSP", "operator", "settlement")
+		buyerIdentity = newIdentity(t, "ConsumerOrgMSP", "buyer", "user")
+	}
+	return env
+}
+
+// invoke - creator 신원으로 트랜잭션 실행
+func (env *settlementTestEnv) invoke(creator []byte, function string, args ...string) pb.Response {
+	env.txSeq++
+	env.stub.Creator = creator
+	input := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+	return env.stub.MockInvoke(fmt.Sprintf("tx%d", env.txSeq), input)
+}
+
+// mustInvoke - 운영자 신원으로 실행하고 실패하면 테스트 중단
+func (env *settlementTestEnv) mustInvoke(function string, args ...string) []byte {
+	env.t.Helper()
+	response := env.invoke(operatorIdentity, function, args...)
+	if response.Status != shim.OK {
+		env.t.Fatalf("%s 실패: %s", function, response.Message)
+	}
+	return response.Payload
+}
+
+func (env *settlementTestEnv) settlement(settlementID string) *SettlementRecord {
+	env.t.Helper()
+	var record SettlementRecord
+	if err := json.Unmarshal(env.mustInvoke("GetSettlement", settlementID), &record); err != nil {
+		env.t.Fatalf("정산 역직렬화 실패: %v", err)
+	}
+	return &record
+}
+
+// events - 지금까지 발생한 이벤트 이름 (채널을 비운다)
+func (env *settlementTestEnv) events() []string {
+	names := []string{}
+	for {
+		select {
+		case event := <-env.stub.ChaincodeEventsChannel:
+			names = append(names, event.EventName)
+		default:
+			return names
+		}
+	}
+}
+
+// newIdentity - userId, role 속성을 가진 자체 서명 인증서 신원
+func newIdentity(t *testing.T, mspID string, userID string, role string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("키 생성 실패: %v", err)
+	}
+	attrs, err := json.Marshal(map[string]interface{}{"attrs": map[string]string{"userId": userID, "role": role}})
+	if err != nil {
+		t.Fatalf("속성 직렬화 실패: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: userID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		// Fabric CA 속성 확장 (1.2.3.4.5.6.7.8.1)
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrs}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("인증서 생성 실패: %v", err)
+	}
+
+	identity, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatalf("신원 직렬화 실패: %v", err)
+	}
+	return identity
+}
+
+func tradeJSON(tradeID string, buyOrderID string, buyerID string, sellerID string, totalAmount float64) string {
+	trade, _ := json.Marshal(tradeView{
+		TradeID:      tradeID,
+		BuyOrderID:   buyOrderID,
+		BuyerID:      buyerID,
+		SellerID:     sellerID,
+		EnergySource: "SOLAR",
+		TotalAmount:  totalAmount,
+		Status:       "CONFIRMED",
+	})
+	return string(trade)
+}
+
+func escrowJSON(refID string, ownerID string, remaining string, status string) string {
+	escrow, _ := json.Marshal(escrowView{RefID: refID, OwnerID: ownerID, Remaining: remaining, Status: status})
+	return string(escrow)
+}
+
+// newPendingSettlement - 거래 T1(구매 주문 O1, 10 EPC)의 PENDING 정산 S1 생성
+func newPendingSettlement(t *testing.T) *settlementTestEnv {
+	env := newSettlementTestEnv(t)
+	env.trading.responses["GetTrade"] = tradeJSON("T1", "O1", "buyer", "seller", 10)
+	env.epc.responses["GetEscrow"] = escrowJSON("O1", "buyer", "10.000000", "OPEN")
+	env.mustInvoke("CreateSettlement", "S1", "T1", "buyer", "seller", "10")
+	env.events()
+	return env
+}
+
+func TestSettleTrade(t *testing.T) {
+	env := newPendingSettlement(t)
+
+	env.mustInvoke("SettleTrade", "S1")
+
+	if got, want := env.epc.called("SettleLocked"), []string{"SettleLocked buyer seller 10.000000 0.200000 ETP_FEE_ACCOUNT O1"}; !equalStrings(got, want) {
+		t.Errorf("SettleLocked 호출 = %q, want %q", got, want)
+	}
+	if got, want := env.trading.called("UpdateTradeStatus"), []string{"UpdateTradeStatus T1 SETTLED"}; !equalStrings(got, want) {
+		t.Errorf("UpdateTradeStatus 호출 = %q, want %q", got, want)
+	}
+	if got, want := env.events(), []string{"TradeSettled"}; !equalStrings(got, want) {
+		t.Errorf("이벤트 = %q, want %q", got, want)
+	}
+
+	record := env.settlement("S1")
+	if record.Status != StatusCompleted || record.SettledAt == "" {
+		t.Errorf("정산 상태 = %s (정산 시각 %q), want COMPLETED", record.Status, record.SettledAt)
+	}
+
+	// 완료된 정산은 다시 지급하지 않는다
+	if response := env.invoke(operatorIdentity, "SettleTrade", "S1"); response.Status == shim.OK {
+		t.Errorf("완료된 정산 재실행이 성공했습니다")
+	}
+	if got := len(env.epc.called("SettleLocked")); got != 1 {
+		t.Errorf("SettleLocked 호출 %d회, want 1", got)
+	}
+}
+
+func TestSettleTradeRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		creator func() []byte
+		escrow  string
+		wantErr string
+	}{
+		{
+			name:    "정산 운영자가 아님",
+			creator: func() []byte { return buyerIdentity },
+			escrow:  escrowJSON("O1", "buyer", "10.000000", "OPEN"),
+			wantErr: "권한 없음",
+		},
+		{
+			name:    "에스크로 소유자 불일치",
+			creator: func() []byte { return operatorIdentity },
+			escrow:  escrowJSON("O1", "someone", "10.000000", "OPEN"),
+			wantErr: "에스크로 소유자가 구매자와 일치하지 않습니다",
+		},
+		{
+			name:    "에스크로 잔여 잠금액 부족",
+			creator: func() []byte { return operatorIdentity },
+			escrow:  escrowJSON("O1", "buyer", "9.999999", "OPEN"),
+			wantErr: "에스크로 잔여 잠금액이 부족합니다",
+		},
+		{
+			name:    "종료된 에스크로",
+			creator: func() []byte { return operatorIdentity },
+			escrow:  escrowJSON("O1", "buyer", "10.000000", "RECLAIMED"),
+			wantErr: "잠금 중인 에스크로가 아닙니다",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newPendingSettlement(t)
+			env.epc.responses["GetEscrow"] = tt.escrow
+
+			response := env.invoke(tt.creator(), "SettleTrade", "S1")
+			if response.Status == shim.OK || !strings.Contains(response.Message, tt.wantErr) {
+				t.Fatalf("SettleTrade = %d %q, want 오류 %q", response.Status, response.Message, tt.wantErr)
+			}
+			if calls := append(env.epc.called("SettleLocked"), env.trading.called("UpdateTradeStatus")...); len(calls) != 0 {
+				t.Errorf("거부된 정산에서 지급 또는 거래 변경이 호출되었습니다: %q", calls)
+			}
+			if record := env.settlement("S1"); record.Status != StatusPending {
+				t.Errorf("정산 상태 = %s, want PENDING", record.Status)
+			}
+		})
+	}
+}
+
+// TestSettleTradeRollback - 지급이나 거래 변경이 실패하면 오류를 반환해 트랜잭션 전체가 무효가 되고
+// 정산은 PENDING으로 남아 같은 정산으로 다시 처리할 수 있다
+func TestSettleTradeRollback(t *testing.T) {
+	tests := []struct {
+		name          string
+		failEPC       string
+		failTrading   string
+		wantTradeCall bool
+	}{
+		{name: "에스크로 지급 실패", failEPC: "SettleLocked"},
+		{name: "거래 상태 변경 실패", failTrading: "UpdateTradeStatus", wantTradeCall: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newPendingSettlement(t)
+			if tt.failEPC != "" {
+				env.epc.failures[tt.failEPC] = "endorsement failure"
+			}
+			if tt.failTrading != "" {
+				env.trading.failures[tt.failTrading] = "endorsement failure"
+			}
+
+			response := env.invoke(operatorIdentity, "SettleTrade", "S1")
+			if response.Status == shim.OK || !strings.Contains(response.Message, "endorsement failure") {
+				t.Fatalf("SettleTrade = %d %q, want 연동 체인코드 오류", response.Status, response.Message)
+			}
+			if got := len(env.trading.called("UpdateTradeStatus")) > 0; got != tt.wantTradeCall {
+				t.Errorf("UpdateTradeStatus 호출 여부 = %v, want %v", got, tt.wantTradeCall)
+			}
+			if events := env.events(); len(events) != 0 {
+				t.Errorf("실패한 정산에서 이벤트가 발생했습니다: %q", events)
+			}
+			if record := env.settlement("S1"); record.Status != StatusPending || record.SettledAt != "" {
+				t.Fatalf("정산 상태 = %s (정산 시각 %q), want PENDING", record.Status, record.SettledAt)
+			}
+
+			delete(env.epc.failures, tt.failEPC)
+			delete(env.trading.failures, tt.failTrading)
+			env.mustInvoke("SettleTrade", "S1")
+			if record := env.settlement("S1"); record.Status != StatusCompleted {
+				t.Errorf("재처리 후 정산 상태 = %s, want COMPLETED", record.Status)
+			}
+		})
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "10.000000", want: 10_000_000},
+		{value: "0.000001", want: 1},
+		{value: "12.5", want: 12_500_000},
+		{value: "7", want: 7_000_000},
+		{value: "9223372036854.775807", want: 9223372036854775807},
+		{value: "", wantErr: true},
+		{value: ".5", wantErr: true},
+		{value: "0.0000001", wantErr: true},
+		{value: "-1.000000", wantErr: true},
+		{value: "+1.000000", wantErr: true},
+		{value: "1.-00000", wantErr: true},
+		{value: "9223372036854.775808", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseUnits(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseUnits(%q) = %d, %v, want %d (오류 %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestUnitsRoundTrip - 6자리 소수 EPC 금액은 maxExactUnits 이하에서 float64를 거쳐도 기본 단위가 그대로 보존된다
+//
+// 거래 체인코드가 JSON float64로 넘기는 거래 금액과 epc 체인코드의 금액 문자열이 같은 값을 가리키는지 확인한다.
+func TestUnitsRoundTrip(t *testing.T) {
+	samples := []int64{0, 1, 9, 10, 999_999, 1_000_000, 1_000_001, 123_456_789, maxExactUnits - 1, maxExactUnits}
+	for scale := int64(10); scale <= maxExactUnits; scale *= 10 {
+		samples = append(samples, scale-1, scale+1, maxExactUnits-scale)
+	}
+	// 모든 자릿수의 값이 고르게 나오도록 홀수 간격으로 약 10만 개 표본 추출
+	for units := int64(7); units <= maxExactUnits; units += 22_517_998_137 {
+		samples = append(samples, units)
+	}
+
+	for _, units := range samples {
+		value := fromUnits(units)
+		if got := toUnits(value); got != units {
+			t.Fatalf("toUnits(fromUnits(%d)) = %d", units, got)
+		}
+
+		text := formatUnits(units)
+		if got, err := parseUnits(text); err != nil || got != units {
+			t.Fatalf("parseUnits(%q) = %d, %v, want %d", text, got, err, units)
+		}
+		if parsed, err := strconv.ParseFloat(text, 64); err != nil || parsed != value {
+			t.Fatalf("금액 문자열 %q = %v, want fromUnits(%d) = %v", text, parsed, units, value)
+		}
+
+		payload, _ := json.Marshal(value)
+		var decoded float64
+		if err := json.Unmarshal(payload, &decoded); err != nil || toUnits(decoded) != units {
+			t.Fatalf("JSON %s 왕복 = %v, %v, want %d 기본 단위", payload, decoded, err, units)
+		}
+	}
+}
+
+func TestCreateSettlementRejectsInexactAmount(t *testing.T) {
+	env := newSettlementTestEnv(t)
+	env.trading.responses["GetTrade"] = tradeJSON("T1", "O1", "alice", "bob", fromUnits(maxExactUnits)*2)
+
+	response := env.invoke(operatorIdentity, "CreateSettlement", "S1", "T1", "alice", "bob", formatUnits(maxExactUnits*2))
+	if response.Status == shim.OK || !strings.Contains(response.Message, "거래 금액이 너무 큽니다") {
+		t.Errorf("CreateSettlement = %d %q, want 금액 상한 오류", response.Status, response.Message)
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"time"
 
+	"github.com/etp/chaincode/common"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -15,94 +14,217 @@ type SettlementContract struct {
 
 // SettlementRecord - 정산 기록
 type SettlementRecord struct {
-	SettlementID string  `json:"settlementId"`
-	TradeID      string  `json:"tradeId"`
-	BuyerID      string  `json:"buyerId"`
-	SellerID     string  `json:"sellerId"`
-	Amount       float64 `json:"amount"`
-	Fee          float64 `json:"fee"`
-	NetAmount    float64 `json:"netAmount"`
-	Status       string  `json:"status"` // PENDING, PROCESSING, COMPLETED, FAILED
-	CreatedAt    string  `json:"createdAt"`
-	SettledAt    string  `json:"settledAt"`
+	SettlementID       string  `json:"settlementId"`
+	TradeID            string  `json:"tradeId"`
+	BuyerID            string  `json:"buyerId"`
+	SellerID           string  `json:"sellerId"`
+	Amount             float64 `json:"amount"`
+	Fee                float64 `json:"fee"`
+	NetAmount          float64 `json:"netAmount"`
+	FeeRate            float64 `json:"feeRate,omitempty" metadata:",optional"`
+	FeeCollectorID     string  `json:"feeCollectorId,omitempty" metadata:",optional"`
+	FeeScheduleVersion int     `json:"feeScheduleVersion,omitempty" metadata:",optional"` // 0: 기본 수수료 일정
+	Status             string  `json:"status"`                                            // PENDING, PROCESSING, COMPLETED, FAILED
+	Attempts           int     `json:"attempts,omitempty" metadata:",optional"`           // StartProcessing 횟수
+	LastAttemptAt      string  `json:"lastAttemptAt,omitempty" metadata:",optional"`
+	FailureCode        string  `json:"failureCode,omitempty" metadata:",optional"`
+	FailureReason      string  `json:"failureReason,omitempty" metadata:",optional"`
+	FailedAt           string  `json:"failedAt,omitempty" metadata:",optional"`
+	CreatedAt          string  `json:"createdAt"`
+	UpdatedAt          string  `json:"updatedAt,omitempty" metadata:",optional"`
+	SettledAt          string  `json:"settledAt"`
 }
 
-// CreateSettlement - 정산 기록 생성
-func (c *SettlementContract) CreateSettlement(ctx contractapi.TransactionContextInterface, settlementID string, tradeID string, buyerID string, sellerID string, amount float64, fee float64) error {
-	now := time.Now().UTC().Format(time.RFC3339)
+// SettlementRecordPage - 정산 기록 페이지 조회 결과
+type SettlementRecordPage struct {
+	Records      []SettlementRecord `json:"records"`
+	Bookmark     string             `json:"bookmark"`
+	FetchedCount int32              `json:"fetchedCount"`
+}
 
-	record := SettlementRecord{
-		SettlementID: settlementID,
-		TradeID:      tradeID,
-		BuyerID:      buyerID,
-		SellerID:     sellerID,
-		Amount:       amount,
-		Fee:          fee,
-		NetAmount:    amount - fee,
-		Status:       "PENDING",
-		CreatedAt:    now,
-		SettledAt:    "",
-	}
-
-	recordJSON, err := json.Marshal(record)
+// CreateSettlement - 정산 기록 생성 (정산 운영자 전용)
+//
+// 거래당 진행 중인(종료되지 않은) 정산은 하나만 둘 수 있고(SETTLEMENT_TRADE_{tradeID} 인덱스),
+// 정산이 완료된 거래는 다시 정산할 수 없다.
+// 정산 금액은 CONFIRMED 거래의 TotalAmount(EPC 소수 자릿수)이며 amount가 이와 다르면 거부한다.
+// 수수료는 호출자가 지정하지 않고 현재 수수료 일정(GetFeeSchedule)을 거래에 적용해 계산한다.
+func (c *SettlementContract) CreateSettlement(ctx contractapi.TransactionContextInterface, settlementID string, tradeID string, buyerID string, sellerID string, amount float64) error {
+	if _, err := requireOperator(ctx); err != nil {
+		return err
+	}
+	if settlementID == "" {
+		return fmt.Errorf("정산 ID가 비어 있습니다")
+	}
+
+	exists, err := common.Exists(ctx, settlementID)
 	if err != nil {
-		return fmt.Errorf("정산 직렬화 실패: %v", err)
+		return err
+	}
+	if exists {
+		return fmt.Errorf("정산이 이미 존재합니다: %s", settlementID)
 	}
 
-	return ctx.GetStub().PutState(settlementID, recordJSON)
-}
+	latest, err := c.tradeSettlement(ctx, tradeID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && latest.Status == StatusCompleted {
+		return fmt.Errorf("이미 정산이 완료된 거래입니다: %s (정산: %s)", tradeID, latest.SettlementID)
+	}
+	if latest != nil && isSettlementOpen(latest) {
+		return fmt.Errorf("거래 %s 에 진행 중인 정산이 있습니다: %s", tradeID, latest.SettlementID)
+	}
 
-// GetSettlement - 정산 기록 조회
-func (c *SettlementContract) GetSettlement(ctx contractapi.TransactionContextInterface, settlementID string) (*SettlementRecord, error) {
-	recordJSON, err := ctx.GetStub().GetState(settlementID)
+	trade, err := getTrade(ctx, tradeID)
 	if err != nil {
-		return nil, fmt.Errorf("정산 조회 실패: %v", err)
+		return err
+	}
+	if trade.BuyerID != buyerID || trade.SellerID != sellerID {
+		return fmt.Errorf("정산 참여자가 거래와 일치하지 않습니다: 거래 %s/%s, 정산 %s/%s", trade.BuyerID, trade.SellerID, buyerID, sellerID)
+	}
+	units, err := settleableAmount(trade)
+	if err != nil {
+		return err
 	}
-	if recordJSON == nil {
-		return nil, fmt.Errorf("정산을 찾을 수 없습니다: %s", settlementID)
+	if toUnits(amount) != units {
+		return fmt.Errorf("정산 금액이 거래 금액과 일치하지 않습니다: 거래 %s, 정산 %v", formatUnits(units), amount)
 	}
+	amount = fromUnits(units)
 
-	var record SettlementRecord
-	if err := json.Unmarshal(recordJSON, &record); err != nil {
-		return nil, fmt.Errorf("정산 역직렬화 실패: %v", err)
+	schedule, err := c.GetFeeSchedule(ctx)
+	if err != nil {
+		return err
 	}
+	fee, rate := schedule.feeFor(trade, amount)
 
-	return &record, nil
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	record := SettlementRecord{
+		SettlementID:       settlementID,
+		TradeID:            tradeID,
+		BuyerID:            buyerID,
+		SellerID:           sellerID,
+		Amount:             amount,
+		Fee:                fee,
+		NetAmount:          amount - fee,
+		FeeRate:            rate,
+		FeeCollectorID:     schedule.FeeCollectorID,
+		FeeScheduleVersion: schedule.Version,
+		Status:             StatusPending,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		SettledAt:          "",
+	}
+
+	if err := common.PutState(ctx, settlementID, record, "정산"); err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(tradeSettlementKey(tradeID), []byte(settlementID)); err != nil {
+		return fmt.Errorf("거래 정산 인덱스 저장 실패: %v", err)
+	}
+	return nil
+}
+
+// GetSettlement - 정산 기록 조회
+func (c *SettlementContract) GetSettlement(ctx contractapi.TransactionContextInterface, settlementID string) (*SettlementRecord, error) {
+	return common.MustGetState[SettlementRecord](ctx, settlementID, "정산", settlementID)
 }
 
-// ConfirmPayment - 정산 완료 처리
+// ConfirmPayment - 정산 완료 처리 (장외 결제 확인, 정산 운영자 전용)
+//
+// EPC를 이동하지 않는 것 외에는 SettleTrade와 같이 거래와 정산 금액을 검증하고
+// 같은 트랜잭션에서 거래를 SETTLED로 변경하므로 완료된 정산의 거래가 다시 정산되지 않는다.
 func (c *SettlementContract) ConfirmPayment(ctx contractapi.TransactionContextInterface, settlementID string) error {
 	record, err := c.GetSettlement(ctx, settlementID)
 	if err != nil {
 		return err
 	}
 
-	record.Status = "COMPLETED"
-	record.SettledAt = time.Now().UTC().Format(time.RFC3339)
+	return c.changeSettlementStatus(ctx, record, StatusCompleted, func(r *SettlementRecord, now string) error {
+		if _, _, err := settleableTrade(ctx, r); err != nil {
+			return err
+		}
+		if _, err := common.InvokeChaincode(ctx, tradingChaincode, "UpdateTradeStatus", r.TradeID, "SETTLED"); err != nil {
+			return err
+		}
+		r.SettledAt = now
+		return nil
+	})
+}
 
-	recordJSON, err := json.Marshal(record)
+// FailSettlement - 정산 실패 처리 (정산 운영자 전용)
+//
+// code는 재시도 큐 분류용 코드(예: INSUFFICIENT_FUNDS, ESCROW_EXPIRED), reason은 상세 사유다.
+func (c *SettlementContract) FailSettlement(ctx contractapi.TransactionContextInterface, settlementID string, code string, reason string) error {
+	if code == "" {
+		return fmt.Errorf("실패 코드가 비어 있습니다")
+	}
+
+	record, err := c.GetSettlement(ctx, settlementID)
 	if err != nil {
-		return fmt.Errorf("정산 직렬화 실패: %v", err)
+		return err
 	}
 
-	return ctx.GetStub().PutState(settlementID, recordJSON)
+	return c.changeSettlementStatus(ctx, record, StatusFailed, func(r *SettlementRecord, now string) error {
+		r.FailureCode = code
+		r.FailureReason = reason
+		r.FailedAt = now
+		return nil
+	})
 }
 
-// FailSettlement - 정산 실패 처리
-func (c *SettlementContract) FailSettlement(ctx contractapi.TransactionContextInterface, settlementID string) error {
-	record, err := c.GetSettlement(ctx, settlementID)
+// QuerySettlementsByStatus - 상태별 정산 조회 (CouchDB 전용)
+//
+// participantID가 주어지면 구매자 또는 판매자로 참여한 정산만 조회한다.
+func (c *SettlementContract) QuerySettlementsByStatus(ctx contractapi.TransactionContextInterface, status string, participantID string, pageSize int32, bookmark string) (*SettlementRecordPage, error) {
+	if status == "" {
+		return nil, fmt.Errorf("정산 상태가 비어 있습니다")
+	}
+
+	selector := common.NewSelector().Exists("settlementId").Eq("status", status)
+	if participantID != "" {
+		selector.Or(
+			common.NewSelector().Eq("buyerId", participantID),
+			common.NewSelector().Eq("sellerId", participantID),
+		)
+	}
+
+	page, err := common.QuerySelectorPage[SettlementRecord](ctx, selector, pageSize, bookmark, "정산")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	record.Status = "FAILED"
+	return &SettlementRecordPage{Records: page.Records, Bookmark: page.Bookmark, FetchedCount: page.FetchedCount}, nil
+}
 
-	recordJSON, err := json.Marshal(record)
+// ========== 내부 헬퍼 ==========
+
+// tradeSettlementKey - 거래별 최근 정산 ID 인덱스 키
+func tradeSettlementKey(tradeID string) string {
+	return "SETTLEMENT_TRADE_" + tradeID
+}
+
+// tradeSettlement - 거래의 최근 정산 기록 (없으면 nil)
+func (c *SettlementContract) tradeSettlement(ctx contractapi.TransactionContextInterface, tradeID string) (*SettlementRecord, error) {
+	settlementID, err := ctx.GetStub().GetState(tradeSettlementKey(tradeID))
 	if err != nil {
-		return fmt.Errorf("정산 직렬화 실패: %v", err)
+		return nil, common.Failed("거래 정산 인덱스 조회", err)
 	}
+	if settlementID == nil {
+		return nil, nil
+	}
+
+	return common.GetState[SettlementRecord](ctx, string(settlementID), "정산")
+}
 
-	return ctx.GetStub().PutState(settlementID, recordJSON)
+// isSettlementOpen - 진행 중인(종료되지 않은) 정산 여부
+//
+// 완료되었거나 최대 시도 횟수를 넘겨 실패한 정산은 종료된 것으로 본다.
+func isSettlementOpen(record *SettlementRecord) bool {
+	return record.Status != StatusCompleted && !(record.Status == StatusFailed && record.Attempts >= maxSettlementAttempts)
 }
 
 func main() {
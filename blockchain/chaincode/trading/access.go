@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// requireMatchingOperator - 오프체인 매칭 운영자(관리자 또는 matching 역할) 여부 검사
+func requireMatchingOperator(ctx contractapi.TransactionContextInterface) (*common.CallerIdentity, error) {
+	caller, err := common.RequireMSP(ctx, operatorMSP)
+	if err != nil {
+		return nil, err
+	}
+	roles := []string{"admin", "matching"}
+	if !common.Contains(roles, caller.Role) {
+		return nil, fmt.Errorf("권한 없음: %v 역할이 필요합니다 (역할: %q)", roles, caller.Role)
+	}
+
+	return caller, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// epcChaincode - 매수 주문 대금을 잠그는 체인코드 이름 (network/scripts/setup-network.sh 기준)
+const epcChaincode = "epc-cc"
+
+const (
+	// epcDecimals - EPC 금액 문자열 소수 자릿수 (epc 체인코드 EPCDecimals)
+	epcDecimals = 6
+	// unitsPerEPC - EPC 기본 단위 (주문 에스크로 금액은 정수 기본 단위로 계산)
+	unitsPerEPC int64 = 1_000_000
+	// maxExactUnits - float64 금액이 기본 단위와 정확히 왕복하는 상한 (2^51 기본 단위, 약 22.5억 EPC)
+	maxExactUnits int64 = 1 << 51
+
+	// orderEscrowGrace - 주문 만료 후에도 체결된 거래를 정산할 수 있도록 에스크로 만료를 늦추는 기간
+	orderEscrowGrace = 7 * 24 * time.Hour
+	// orderEscrowNoExpiry - 만료 없는 매수 주문의 에스크로 만료 시각
+	orderEscrowNoExpiry = "9999-12-31T23:59:59Z"
+)
+
+// ========== 내부 헬퍼 ==========
+
+// lockOrder - 매수 주문 대금을 주문 ID를 refID로 하는 EPC 에스크로에 잠금 (epc.Lock)
+//
+// 잠금액은 이 트랜잭션에서 체결된 거래 대금과 미체결 잔량 × 지정가의 합이다. 더 좋은 가격에 체결된
+// 차액은 처음부터 잠그지 않으므로 별도로 해제할 필요가 없다. 잠금은 주문자 본인 잔액에 대한 작업이므로
+// 주문자가 제출한 트랜잭션에서 epc 체인코드가 그대로 허용한다.
+func lockOrder(ctx contractapi.TransactionContextInterface, order *Order, trades []TradeRecord) error {
+	locked, err := lockedUnits(order)
+	if err != nil {
+		return err
+	}
+	total := locked
+	for i := range trades {
+		total += toUnits(trades[i].TotalAmount)
+	}
+	if total <= 0 {
+		return fmt.Errorf("주문 금액이 EPC 최소 단위보다 작습니다: %v × %v", order.Quantity, order.LimitPrice)
+	}
+
+	expiresAt := orderEscrowNoExpiry
+	if order.ExpiresAt != "" {
+		expiry, err := common.ParseTime(order.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		expiresAt = common.FormatTime(expiry.Add(orderEscrowGrace))
+	}
+
+	_, err = common.InvokeChaincode(ctx, epcChaincode, "Lock", order.TraderID, formatUnits(total), order.OrderID, "", expiresAt)
+	return err
+}
+
+// releaseOrder - 매수 주문의 미체결 잔량 잠금액을 해제 (epc.Unlock, 취소·만료 시)
+//
+// 체결된 거래 대금은 정산 시 같은 에스크로에서 지급되므로 남겨 둔다.
+func releaseOrder(ctx contractapi.TransactionContextInterface, order *Order) error {
+	locked, err := lockedUnits(order)
+	if err != nil {
+		return err
+	}
+	if locked == 0 {
+		return nil
+	}
+
+	if _, err := common.InvokeChaincode(ctx, epcChaincode, "Unlock", order.TraderID, formatUnits(locked), order.OrderID); err != nil {
+		return err
+	}
+	order.LockedAmount = ""
+	return nil
+}
+
+// fillUnits - 체결 수량의 거래 대금 (기본 단위)
+//
+// 대기 매수 주문은 체결 가격이 자신의 지정가이므로 잠금액에서 대금을 차감하고, 잔량을 모두 체결하면
+// 남은 잠금액 전부를 대금으로 써서 반올림 차이 없이 잠금액이 거래 대금으로 나뉘게 한다.
+func fillUnits(resting *Order, fill float64) (int64, error) {
+	units := toUnits(fill * resting.LimitPrice)
+	if resting.Side != SideBuy || resting.LockedAmount == "" {
+		return units, nil
+	}
+
+	locked, err := lockedUnits(resting)
+	if err != nil {
+		return 0, err
+	}
+	if fill >= resting.RemainingQuantity || units > locked {
+		units = locked
+	}
+	resting.LockedAmount = formatUnits(locked - units)
+	return units, nil
+}
+
+// lockedUnits - 주문의 미체결 잔량 잠금액 (기본 단위, 에스크로 도입 전 주문은 0)
+func lockedUnits(order *Order) (int64, error) {
+	if order.LockedAmount == "" {
+		return 0, nil
+	}
+
+	units, err := parseUnits(order.LockedAmount)
+	if err != nil {
+		return 0, common.Failed("주문 잠금액 해석", err)
+	}
+	return units, nil
+}
+
+// toUnits - float64 EPC 금액을 기본 단위로 변환 (maxExactUnits 이하에서 fromUnits와 정확히 왕복)
+func toUnits(value float64) int64 {
+	return int64(math.Round(value * float64(unitsPerEPC)))
+}
+
+func fromUnits(units int64) float64 {
+	return float64(units) / float64(unitsPerEPC)
+}
+
+// formatUnits - 기본 단위 금액을 EPC 체인코드 금액 문자열로 변환
+func formatUnits(units int64) string {
+	return fmt.Sprintf("%d.%0*d", units/unitsPerEPC, epcDecimals, units%unitsPerEPC)
+}
+
+// parseUnits - EPC 금액 문자열(소수 최대 epcDecimals자리)을 기본 단위로 변환
+func parseUnits(value string) (int64, error) {
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || len(fraction) > epcDecimals {
+		return 0, fmt.Errorf("잘못된 EPC 금액: %q", value)
+	}
+	fraction += strings.Repeat("0", epcDecimals-len(fraction))
+
+	wholeUnits, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || wholeUnits < 0 || strings.HasPrefix(whole, "+") {
+		return 0, fmt.Errorf("잘못된 EPC 금액: %q", value)
+	}
+	fractionUnits, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil || strings.ContainsAny(fraction, "+-") {
+		return 0, fmt.Errorf("잘못된 EPC 금액: %q", value)
+	}
+	if wholeUnits > (math.MaxInt64-fractionUnits)/unitsPerEPC {
+		return 0, fmt.Errorf("EPC 금액이 너무 큽니다: %q", value)
+	}
+
+	return wholeUnits*unitsPerEPC + fractionUnits, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "1960.000000", want: 1_960_000_000},
+		{value: "0.000001", want: 1},
+		{value: "12.5", want: 12_500_000},
+		{value: "7", want: 7_000_000},
+		{value: "9223372036854.775807", want: 9223372036854775807},
+		{value: "", wantErr: true},
+		{value: ".5", wantErr: true},
+		{value: "0.0000001", wantErr: true},
+		{value: "-1.000000", wantErr: true},
+		{value: "+1.000000", wantErr: true},
+		{value: "1.-00000", wantErr: true},
+		{value: "9223372036854.775808", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseUnits(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseUnits(%q) = %d, %v, want %d (오류 %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestUnitsRoundTrip - 6자리 소수 EPC 금액은 maxExactUnits 이하에서 float64를 거쳐도 기본 단위가 그대로 보존된다
+//
+// 거래 기록의 float64 대금(JSON)과 epc 체인코드에 넘기는 금액 문자열이 같은 값을 가리키는지 확인한다.
+func TestUnitsRoundTrip(t *testing.T) {
+	samples := []int64{0, 1, 9, 10, 999_999, 1_000_000, 1_000_001, 123_456_789, maxExactUnits - 1, maxExactUnits}
+	for scale := int64(10); scale <= maxExactUnits; scale *= 10 {
+		samples = append(samples, scale-1, scale+1, maxExactUnits-scale)
+	}
+	// 모든 자릿수의 값이 고르게 나오도록 홀수 간격으로 약 10만 개 표본 추출
+	for units := int64(7); units <= maxExactUnits; units += 22_517_998_137 {
+		samples = append(samples, units)
+	}
+
+	for _, units := range samples {
+		value := fromUnits(units)
+		if got := toUnits(value); got != units {
+			t.Fatalf("toUnits(fromUnits(%d)) = %d", units, got)
+		}
+
+		text := formatUnits(units)
+		if got, err := parseUnits(text); err != nil || got != units {
+			t.Fatalf("parseUnits(%q) = %d, %v, want %d", text, got, err, units)
+		}
+		if parsed, err := strconv.ParseFloat(text, 64); err != nil || parsed != value {
+			t.Fatalf("금액 문자열 %q = %v, want fromUnits(%d) = %v", text, parsed, units, value)
+		}
+
+		payload, _ := json.Marshal(value)
+		var decoded float64
+		if err := json.Unmarshal(payload, &decoded); err != nil || toUnits(decoded) != units {
+			t.Fatalf("JSON %s 왕복 = %v, %v, want %d 기본 단위", payload, decoded, err, units)
+		}
+	}
+}
+
+// TestFillUnits - 대기 매수 주문의 체결 대금은 잠금액에서 차감되고 마지막 체결이 남은 잠금액을 모두 가져간다
+func TestFillUnits(t *testing.T) {
+	tests := []struct {
+		name       string
+		order      Order
+		fill       float64
+		want       int64
+		wantLocked string
+	}{
+		{
+			name:       "매도 주문",
+			order:      Order{Side: SideSell, RemainingQuantity: 3, LimitPrice: 0.33},
+			fill:       1,
+			want:       330_000,
+			wantLocked: "",
+		},
+		{
+			name:       "잠금액 없는 매수 주문",
+			order:      Order{Side: SideBuy, RemainingQuantity: 3, LimitPrice: 0.33},
+			fill:       1,
+			want:       330_000,
+			wantLocked: "",
+		},
+		{
+			name:       "부분 체결",
+			order:      Order{Side: SideBuy, RemainingQuantity: 3, LimitPrice: 0.33, LockedAmount: "0.990000"},
+			fill:       1,
+			want:       330_000,
+			wantLocked: "0.660000",
+		},
+		{
+			name:       "잔량 전부 체결",
+			order:      Order{Side: SideBuy, RemainingQuantity: 0.000002, LimitPrice: 0.33, LockedAmount: "0.000001"},
+			fill:       0.000002,
+			want:       1,
+			wantLocked: "0.000000",
+		},
+		{
+			name:       "반올림 대금이 잠금액 초과",
+			order:      Order{Side: SideBuy, RemainingQuantity: 0.000003, LimitPrice: 0.9, LockedAmount: "0.000001"},
+			fill:       0.000002,
+			want:       1,
+			wantLocked: "0.000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fillUnits(&tt.order, tt.fill)
+			if err != nil || got != tt.want || tt.order.LockedAmount != tt.wantLocked {
+				t.Errorf("fillUnits = %d, %v (잠금 잔량 %q), want %d (%q)", got, err, tt.order.LockedAmount, tt.want, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 거래 상태
+const (
+	StatusMatched   = "MATCHED"
+	StatusConfirmed = "CONFIRMED"
+	StatusSettled   = "SETTLED"
+	StatusCancelled = "CANCELLED"
+	StatusDisputed  = "DISPUTED"
+)
+
+// 상태 전이 주체
+const (
+	actorParticipant = "PARTICIPANT" // 거래의 구매자 또는 판매자
+	actorOperator    = "OPERATOR"    // 운영 기관 관리자
+	actorSettlement  = "SETTLEMENT"  // 정산 운영자
+)
+
+// operatorMSP - 운영자/정산 역할을 인정하는 MSP
+const operatorMSP = "AdminOrgMSP"
+
+// confirmedIndexPrefix - CONFIRMED 거래 인덱스 키 접두사 (일괄 정산 대상 조회용)
+const confirmedIndexPrefix = "TRADE_CONFIRMED_"
+
+// tradeTransitions - 현재 상태 → 다음 상태 → 허용 주체
+//
+// SETTLED, CANCELLED는 종료 상태이며 DISPUTED는 운영자만 해소할 수 있다.
+var tradeTransitions = map[string]map[string][]string{
+	StatusMatched: {
+		StatusConfirmed: {actorParticipant, actorOperator},
+		StatusCancelled: {actorParticipant, actorOperator},
+		StatusDisputed:  {actorParticipant},
+	},
+	StatusConfirmed: {
+		StatusSettled:   {actorSettlement},
+		StatusCancelled: {actorParticipant, actorOperator},
+		StatusDisputed:  {actorParticipant},
+	},
+	StatusDisputed: {
+		StatusConfirmed: {actorOperator},
+		StatusCancelled: {actorOperator},
+	},
+	StatusSettled:   {},
+	StatusCancelled: {},
+}
+
+// TradeStatusChangedEvent - 거래 상태 변경 이벤트
+type TradeStatusChangedEvent struct {
+	TradeID     string `json:"tradeId"`
+	OldStatus   string `json:"oldStatus"`
+	NewStatus   string `json:"newStatus"`
+	ActorID     string `json:"actorId"`
+	ActorMSPID  string `json:"actorMspId"`
+	ActorUserID string `json:"actorUserId"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// changeTradeStatus - 전이 규칙과 호출자 권한을 검사한 뒤 상태 변경 및 TradeStatusChanged 이벤트 발생
+func (c *TradingContract) changeTradeStatus(ctx contractapi.TransactionContextInterface, record *TradeRecord, newStatus string) error {
+	if _, ok := tradeTransitions[newStatus]; !ok {
+		return fmt.Errorf("알 수 없는 거래 상태: %s", newStatus)
+	}
+
+	next, ok := tradeTransitions[record.Status]
+	if !ok {
+		return fmt.Errorf("거래 %s 의 현재 상태를 알 수 없습니다: %s", record.TradeID, record.Status)
+	}
+	actors, ok := next[newStatus]
+	if !ok {
+		return fmt.Errorf("허용되지 않은 상태 전이: %s → %s (거래: %s)", record.Status, newStatus, record.TradeID)
+	}
+
+	caller, err := common.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	if !isTransitionActor(record, caller, actors) {
+		return fmt.Errorf("권한 없음: %s → %s 전이는 %v 만 가능합니다 (MSP: %s, 역할: %q, 사용자: %q)", record.Status, newStatus, actors, caller.MSPID, caller.Role, caller.UserID)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	event := TradeStatusChangedEvent{
+		TradeID:     record.TradeID,
+		OldStatus:   record.Status,
+		NewStatus:   newStatus,
+		ActorID:     caller.ID,
+		ActorMSPID:  caller.MSPID,
+		ActorUserID: caller.UserID,
+		Timestamp:   now,
+	}
+
+	record.Status = newStatus
+	record.UpdatedAt = now
+	record.UpdatedBy = caller.ID
+
+	if err := common.PutState(ctx, record.TradeID, record, "거래"); err != nil {
+		return err
+	}
+	if err := updateConfirmedIndex(ctx, record, event.OldStatus); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "TradeStatusChanged", event)
+}
+
+// updateConfirmedIndex - CONFIRMED 진입/이탈 시 인덱스 추가/삭제
+func updateConfirmedIndex(ctx contractapi.TransactionContextInterface, record *TradeRecord, oldStatus string) error {
+	key := confirmedIndexKey(record)
+	switch {
+	case record.Status == StatusConfirmed && oldStatus != StatusConfirmed:
+		if err := ctx.GetStub().PutState(key, []byte(record.TradeID)); err != nil {
+			return common.Failed("확정 거래 인덱스 저장", err)
+		}
+	case record.Status != StatusConfirmed && oldStatus == StatusConfirmed:
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return common.Failed("확정 거래 인덱스 삭제", err)
+		}
+	}
+	return nil
+}
+
+// confirmedIndexKey - TRADE_CONFIRMED_{체결시각}_{거래ID}
+//
+// 범위 조회는 단순 키만 허용하므로 복합키 대신 RFC3339(UTC) 체결 시각 접두사 키를 사용한다.
+func confirmedIndexKey(record *TradeRecord) string {
+	return confirmedIndexPrefix + record.CreatedAt + "_" + record.TradeID
+}
+
+func isTransitionActor(record *TradeRecord, caller *common.CallerIdentity, actors []string) bool {
+	for _, actor := range actors {
+		switch actor {
+		case actorParticipant:
+			if caller.UserID != "" && (caller.UserID == record.BuyerID || caller.UserID == record.SellerID) {
+				return true
+			}
+		case actorOperator:
+			if caller.MSPID == operatorMSP && caller.Role == "admin" {
+				return true
+			}
+		case actorSettlement:
+			if caller.MSPID == operatorMSP && common.Contains([]string{"admin", "settlement"}, caller.Role) {
+				return true
+			}
+		}
+	}
+	return false
+}
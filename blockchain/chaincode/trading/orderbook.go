@@ -0,0 +1,483 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/etp/chaincode/common"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 주문 방향
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+)
+
+// 주문 상태
+const (
+	OrderOpen            = "OPEN"
+	OrderPartiallyFilled = "PARTIALLY_FILLED"
+	OrderFilled          = "FILLED"
+	OrderCancelled       = "CANCELLED"
+	OrderExpired         = "EXPIRED"
+)
+
+const (
+	orderBookObjectType = "ORDERBOOK"
+
+	// quantityScale - 수량 반올림 단위 (1e-6 kWh), 부분 체결 누적 오차 방지
+	quantityScale = 1e6
+	// priceScale - 호가 정렬 키의 가격 단위 (0.01)
+	priceScale = 100
+	// priceTickTolerance - 호가 단위 판정 시 허용하는 상대 오차
+	priceTickTolerance = 1e-9
+	// maxPriceUnits - 정렬 키로 표현 가능한 최대 가격 (priceScale 단위)
+	maxPriceUnits int64 = 999_999_999_999_999
+)
+
+// Order - 온체인 호가창 주문
+type Order struct {
+	OrderID           string  `json:"orderId"`
+	TraderID          string  `json:"traderId"`
+	TraderMSPID       string  `json:"traderMspId"`
+	Side              string  `json:"side"` // BUY, SELL
+	EnergySource      string  `json:"energySource"`
+	Quantity          float64 `json:"quantity"`
+	RemainingQuantity float64 `json:"remainingQuantity"`
+	LimitPrice        float64 `json:"limitPrice"`
+	Status            string  `json:"status"`   // OPEN, PARTIALLY_FILLED, FILLED, CANCELLED, EXPIRED
+	Sequence          string  `json:"sequence"` // 시간 우선순위 키 (트랜잭션 타임스탬프 나노초)
+	ExpiresAt         string  `json:"expiresAt"`
+	CreatedAt         string  `json:"createdAt"`
+	UpdatedAt         string  `json:"updatedAt"`
+	// 매수 주문 미체결 잔량의 EPC 잠금액 (EPC 금액 문자열, 체결·취소·만료 시 차감)
+	LockedAmount string `json:"lockedAmount,omitempty" metadata:",optional"`
+}
+
+// OrderResult - 주문 접수 결과 (접수 주문 및 즉시 체결된 거래)
+type OrderResult struct {
+	Order  Order         `json:"order"`
+	Trades []TradeRecord `json:"trades"`
+}
+
+// PlaceOrder - 지정가 주문 접수 및 가격-시간 우선 매칭
+//
+// 호출자 인증서의 userId가 주문자가 된다. 반대 방향 주문 중 가격 조건을 만족하는 주문과
+// 가격 우선, 같은 가격이면 먼저 접수된 순으로 체결하며 체결 가격은 대기 주문의 지정가다.
+// 잔량은 호가창에 남는다. expiresAt(RFC3339)이 비어 있으면 만료되지 않는다.
+// 지정가는 호가 단위(0.01)의 배수여야 하며 호가 단위를 벗어난 가격은 거부한다.
+// 매수 주문은 같은 트랜잭션에서 체결 대금과 잔량 × 지정가만큼 EPC를 주문 ID로 잠그며(epc.Lock),
+// 가용 잔액이 부족하면 주문 전체가 거부된다. 잠금액은 정산 시 거래 대금으로 지급되고
+// 미체결분은 취소·만료 시 해제된다.
+func (c *TradingContract) PlaceOrder(ctx contractapi.TransactionContextInterface, orderID string, side string, energySource string, quantity float64, limitPrice float64, expiresAt string) (*OrderResult, error) {
+	caller, err := common.GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID == "" {
+		return nil, fmt.Errorf("호출자 인증서에 %s 속성이 없습니다", common.DefaultUserIDAttribute)
+	}
+
+	if orderID == "" {
+		return nil, fmt.Errorf("주문 ID가 비어 있습니다")
+	}
+	if side != SideBuy && side != SideSell {
+		return nil, fmt.Errorf("잘못된 주문 방향: %s (BUY, SELL 중 하나)", side)
+	}
+	if energySource == "" {
+		return nil, fmt.Errorf("에너지원이 비어 있습니다")
+	}
+	quantity = roundQuantity(quantity)
+	if !(quantity > 0) {
+		return nil, fmt.Errorf("주문 수량은 0보다 커야 합니다: %v", quantity)
+	}
+	if !(limitPrice > 0) || priceUnits(limitPrice) > maxPriceUnits {
+		return nil, fmt.Errorf("잘못된 지정가: %v", limitPrice)
+	}
+	if !onTick(limitPrice) {
+		return nil, fmt.Errorf("지정가는 호가 단위(%v)의 배수여야 합니다: %v", 1.0/priceScale, limitPrice)
+	}
+	// 정렬 키와 체결 가격이 같은 값을 쓰도록 호가 단위로 정규화
+	limitPrice = float64(priceUnits(limitPrice)) / priceScale
+	if quantity*limitPrice > fromUnits(maxExactUnits) {
+		return nil, fmt.Errorf("주문 금액이 너무 큽니다: %v × %v", quantity, limitPrice)
+	}
+
+	existing, err := common.GetState[Order](ctx, orderKey(orderID), "주문")
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("주문이 이미 존재합니다: %s", orderID)
+	}
+
+	txTime, err := common.TxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := common.FormatTime(txTime)
+
+	if expiresAt != "" {
+		expiry, err := common.ParseTime(expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if !expiry.After(txTime) {
+			return nil, fmt.Errorf("만료 시각이 이미 지났습니다: %s", expiresAt)
+		}
+		expiresAt = common.FormatTime(expiry)
+	}
+
+	order := &Order{
+		OrderID:           orderID,
+		TraderID:          caller.UserID,
+		TraderMSPID:       caller.MSPID,
+		Side:              side,
+		EnergySource:      energySource,
+		Quantity:          quantity,
+		RemainingQuantity: quantity,
+		LimitPrice:        limitPrice,
+		Status:            OrderOpen,
+		Sequence:          fmt.Sprintf("%019d", txTime.UnixNano()),
+		ExpiresAt:         expiresAt,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	trades, err := c.matchOrder(ctx, order, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.RemainingQuantity > 0 {
+		if err := c.addToBook(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+	if order.Side == SideBuy {
+		if order.RemainingQuantity > 0 {
+			order.LockedAmount = formatUnits(toUnits(order.RemainingQuantity * order.LimitPrice))
+		}
+		if err := lockOrder(ctx, order, trades); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.saveOrder(ctx, order); err != nil {
+		return nil, err
+	}
+
+	result := &OrderResult{Order: *order, Trades: trades}
+	if err := common.SetEvent(ctx, "OrderPlaced", result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CancelOrder - 미체결 주문 취소 (주문자 또는 운영자)
+//
+// 매수 주문은 미체결 잔량의 EPC 잠금을 같은 트랜잭션에서 해제한다.
+func (c *TradingContract) CancelOrder(ctx contractapi.TransactionContextInterface, orderID string) error {
+	order, err := c.GetOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := common.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	isOwner := caller.UserID != "" && caller.UserID == order.TraderID
+	if !isOwner && !(caller.MSPID == operatorMSP && caller.Role == "admin") {
+		return fmt.Errorf("권한 없음: 주문 %s 은(는) 주문자 또는 운영자만 취소할 수 있습니다 (호출자: %q)", orderID, caller.UserID)
+	}
+
+	if !isResting(order) {
+		return fmt.Errorf("취소할 수 없는 주문 상태입니다: %s (%s)", orderID, order.Status)
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.removeFromBook(ctx, order); err != nil {
+		return err
+	}
+	if err := releaseOrder(ctx, order); err != nil {
+		return err
+	}
+
+	order.Status = OrderCancelled
+	order.UpdatedAt = now
+	if err := c.saveOrder(ctx, order); err != nil {
+		return err
+	}
+
+	return common.SetEvent(ctx, "OrderCancelled", order)
+}
+
+// GetOrder - 주문 조회
+func (c *TradingContract) GetOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	return common.MustGetState[Order](ctx, orderKey(orderID), "주문", orderID)
+}
+
+// GetOrderBook - 에너지원별 한쪽 호가창 조회 (체결 우선순위 순, 만료 주문 제외)
+func (c *TradingContract) GetOrderBook(ctx contractapi.TransactionContextInterface, side string, energySource string) ([]Order, error) {
+	if side != SideBuy && side != SideSell {
+		return nil, fmt.Errorf("잘못된 주문 방향: %s (BUY, SELL 중 하나)", side)
+	}
+	if energySource == "" {
+		return nil, fmt.Errorf("에너지원이 비어 있습니다")
+	}
+
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []Order{}
+	err = common.ForEachByPartialKey(ctx, orderBookObjectType, []string{side, energySource}, func(key string, value []byte) error {
+		order, err := c.GetOrder(ctx, string(value))
+		if err != nil {
+			return err
+		}
+		expired, err := isExpired(order, now)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			orders = append(orders, *order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// ========== 내부 헬퍼 ==========
+
+// matchOrder - 반대 방향 호가창을 우선순위 순으로 순회하며 체결
+//
+// 만료된 대기 주문은 호가창에서 빼고 매수 주문이면 잠금을 해제한다. 한 트랜잭션에서 같은 사용자의
+// EPC 잔액을 두 번 갱신하면 나중 쓰기만 남으므로, 이미 잠금을 해제한 주문자의 다른 만료 주문은
+// 다음 트랜잭션(또는 CancelOrder)에서 처리하도록 남겨 두고 건너뛴다.
+func (c *TradingContract) matchOrder(ctx contractapi.TransactionContextInterface, incoming *Order, now string) ([]TradeRecord, error) {
+	opposite := SideSell
+	if incoming.Side == SideSell {
+		opposite = SideBuy
+	}
+
+	trades := []TradeRecord{}
+	released := map[string]bool{}
+	err := common.ForEachByPartialKey(ctx, orderBookObjectType, []string{opposite, incoming.EnergySource}, func(key string, value []byte) error {
+		if incoming.RemainingQuantity <= 0 {
+			return common.ErrStop
+		}
+
+		resting, err := c.GetOrder(ctx, string(value))
+		if err != nil {
+			return err
+		}
+		if !crosses(incoming, resting) {
+			return common.ErrStop
+		}
+
+		expired, err := isExpired(resting, now)
+		if err != nil {
+			return err
+		}
+		if expired {
+			if resting.LockedAmount != "" {
+				if released[resting.TraderID] {
+					return nil
+				}
+				released[resting.TraderID] = true
+			}
+			return c.expireOrder(ctx, resting, now)
+		}
+		if resting.TraderID == incoming.TraderID {
+			// 자전거래 방지: 같은 주문자의 대기 주문은 건너뛴다
+			return nil
+		}
+
+		fill := math.Min(incoming.RemainingQuantity, resting.RemainingQuantity)
+		trade, err := c.recordFill(ctx, incoming, resting, fill, len(trades), now)
+		if err != nil {
+			return err
+		}
+		trades = append(trades, *trade)
+
+		fillOrder(incoming, fill, now)
+		fillOrder(resting, fill, now)
+		if resting.Status == OrderFilled {
+			if err := c.removeFromBook(ctx, resting); err != nil {
+				return err
+			}
+		}
+		return c.saveOrder(ctx, resting)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+// recordFill - 체결 1건을 MATCHED 상태의 TradeRecord로 기록 (체결 가격은 대기 주문 지정가)
+//
+// 거래 대금은 EPC 기본 단위로 반올림하며 대기 매수 주문은 잠금액에서 차감한다(fillUnits).
+func (c *TradingContract) recordFill(ctx contractapi.TransactionContextInterface, incoming *Order, resting *Order, fill float64, index int, now string) (*TradeRecord, error) {
+	buy, sell := incoming, resting
+	if incoming.Side == SideSell {
+		buy, sell = resting, incoming
+	}
+
+	units, err := fillUnits(resting, fill)
+	if err != nil {
+		return nil, err
+	}
+
+	trade := &TradeRecord{
+		TradeID:      fmt.Sprintf("%s_%d", ctx.GetStub().GetTxID(), index),
+		BuyOrderID:   buy.OrderID,
+		SellOrderID:  sell.OrderID,
+		BuyerID:      buy.TraderID,
+		SellerID:     sell.TraderID,
+		BuyerMSPID:   buy.TraderMSPID,
+		SellerMSPID:  sell.TraderMSPID,
+		TakerSide:    incoming.Side,
+		EnergySource: incoming.EnergySource,
+		Quantity:     fill,
+		Price:        resting.LimitPrice,
+		TotalAmount:  fromUnits(units),
+		Status:       StatusMatched,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := common.PutState(ctx, trade.TradeID, trade, "거래"); err != nil {
+		return nil, err
+	}
+
+	return trade, nil
+}
+
+// expireOrder - 만료된 대기 주문을 호가창에서 빼고 매수 주문 잠금 해제
+func (c *TradingContract) expireOrder(ctx contractapi.TransactionContextInterface, order *Order, now string) error {
+	if err := c.removeFromBook(ctx, order); err != nil {
+		return err
+	}
+	if err := releaseOrder(ctx, order); err != nil {
+		return err
+	}
+
+	order.Status = OrderExpired
+	order.UpdatedAt = now
+
+	return c.saveOrder(ctx, order)
+}
+
+func (c *TradingContract) saveOrder(ctx contractapi.TransactionContextInterface, order *Order) error {
+	return common.PutState(ctx, orderKey(order.OrderID), order, "주문")
+}
+
+func (c *TradingContract) addToBook(ctx contractapi.TransactionContextInterface, order *Order) error {
+	key, err := bookKey(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, []byte(order.OrderID)); err != nil {
+		return common.Failed("호가창 저장", err)
+	}
+
+	return nil
+}
+
+func (c *TradingContract) removeFromBook(ctx contractapi.TransactionContextInterface, order *Order) error {
+	key, err := bookKey(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return common.Failed("호가창 삭제", err)
+	}
+
+	return nil
+}
+
+func orderKey(orderID string) string {
+	return "ORDER_" + orderID
+}
+
+// bookKey - ORDERBOOK~방향~에너지원~가격키~시퀀스~주문ID
+//
+// 매도는 낮은 가격, 매수는 높은 가격이 먼저 오도록 가격키를 인코딩하므로
+// 부분 복합키 조회 순서가 곧 체결 우선순위가 된다.
+func bookKey(ctx contractapi.TransactionContextInterface, order *Order) (string, error) {
+	units := priceUnits(order.LimitPrice)
+	if order.Side == SideBuy {
+		units = maxPriceUnits - units
+	}
+
+	return common.CompositeKey(ctx, orderBookObjectType, order.Side, order.EnergySource, fmt.Sprintf("%015d", units), order.Sequence, order.OrderID)
+}
+
+func priceUnits(price float64) int64 {
+	return int64(math.Round(price * priceScale))
+}
+
+// onTick - 가격이 호가 단위(1/priceScale)의 배수인지 (부동소수점 표현 오차는 허용)
+func onTick(price float64) bool {
+	scaled := price * priceScale
+	return math.Abs(scaled-math.Round(scaled)) <= priceTickTolerance*math.Max(1, math.Abs(scaled))
+}
+
+func roundQuantity(quantity float64) float64 {
+	return math.Round(quantity*quantityScale) / quantityScale
+}
+
+func crosses(incoming *Order, resting *Order) bool {
+	if incoming.Side == SideBuy {
+		return priceUnits(incoming.LimitPrice) >= priceUnits(resting.LimitPrice)
+	}
+	return priceUnits(incoming.LimitPrice) <= priceUnits(resting.LimitPrice)
+}
+
+func fillOrder(order *Order, fill float64, now string) {
+	order.RemainingQuantity = roundQuantity(order.RemainingQuantity - fill)
+	if order.RemainingQuantity <= 0 {
+		order.RemainingQuantity = 0
+		order.Status = OrderFilled
+	} else {
+		order.Status = OrderPartiallyFilled
+	}
+	order.UpdatedAt = now
+}
+
+func isResting(order *Order) bool {
+	return order.Status == OrderOpen || order.Status == OrderPartiallyFilled
+}
+
+func isExpired(order *Order, now string) (bool, error) {
+	if order.ExpiresAt == "" {
+		return false, nil
+	}
+
+	expiry, err := common.ParseTime(order.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	current, err := common.ParseTime(now)
+	if err != nil {
+		return false, err
+	}
+
+	return !expiry.After(current), nil
+}
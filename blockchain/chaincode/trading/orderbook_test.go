@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// fakeEPC - epc 체인코드 대역 (호출 내역 기록, 함수별 오류 지정)
+type fakeEPC struct {
+	failures map[string]string
+	calls    []string // "함수 인자1 인자2 ..."
+}
+
+func (f *fakeEPC) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (f *fakeEPC) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	f.calls = append(f.calls, strings.Join(append([]string{function}, args...), " "))
+	if message, ok := f.failures[function]; ok {
+		return shim.Error(message)
+	}
+	return shim.Success(nil)
+}
+
+// take - 지금까지의 호출 내역을 반환하고 비운다
+func (f *fakeEPC) take() []string {
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+// tradingTestEnv - 거래 체인코드와 epc 체인코드 대역, 사용자별 신원
+type tradingTestEnv struct {
+	t          *testing.T
+	contract   *TradingContract
+	stub       *shimtest.MockStub
+	epc        *fakeEPC
+	identities map[string][]byte
+	txSeq      int
+}
+
+func newTradingTestEnv(t *testing.T) *tradingTestEnv {
+	t.Helper()
+
+	contract := &TradingContract{}
+	chaincode, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		t.Fatalf("체인코드 생성 실패: %v", err)
+	}
+
+	env := &tradingTestEnv{
+		t:          t,
+		contract:   contract,
+		stub:       shimtest.NewMockStub("trading-cc", chaincode),
+		epc:        &fakeEPC{failures: map[string]string{}},
+		identities: map[string][]byte{},
+	}
+	env.stub.MockPeerChaincode(epcChaincode, shimtest.NewMockStub(epcChaincode, env.epc), "")
+	return env
+}
+
+// as - userID 사용자(SupplierOrgMSP, user 역할) 신원
+func (env *tradingTestEnv) as(userID string) []byte {
+	if identity, ok := env.identities[userID]; ok {
+		return identity
+	}
+	identity := newIdentity(env.t, "SupplierOrgMSP", userID, "user")
+	env.identities[userID] = identity
+	return identity
+}
+
+func (env *tradingTestEnv) invoke(creator []byte, function string, args ...string) pb.Response {
+	env.txSeq++
+	env.stub.Creator = creator
+	input := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+	return env.stub.MockInvoke(fmt.Sprintf("tx%d", env.txSeq), input)
+}
+
+// placeOrder - userID 사용자의 주문 접수 (실패하면 테스트 중단)
+func (env *tradingTestEnv) placeOrder(userID string, orderID string, side string, quantity string, price string) *OrderResult {
+	env.t.Helper()
+	response := env.invoke(env.as(userID), "PlaceOrder", orderID, side, "SOLAR", quantity, price, "")
+	if response.Status != shim.OK {
+		env.t.Fatalf("PlaceOrder %s 실패: %s", orderID, response.Message)
+	}
+	var result OrderResult
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		env.t.Fatalf("주문 결과 역직렬화 실패: %v", err)
+	}
+	return &result
+}
+
+func (env *tradingTestEnv) order(orderID string) *Order {
+	env.t.Helper()
+	response := env.invoke(env.as("viewer"), "GetOrder", orderID)
+	if response.Status != shim.OK {
+		env.t.Fatalf("GetOrder %s 실패: %s", orderID, response.Message)
+	}
+	var order Order
+	if err := json.Unmarshal(response.Payload, &order); err != nil {
+		env.t.Fatalf("주문 역직렬화 실패: %v", err)
+	}
+	return &order
+}
+
+// bookIDs - 한쪽 호가창의 주문 ID (체결 우선순위 순)
+func (env *tradingTestEnv) bookIDs(side string) []string {
+	env.t.Helper()
+	response := env.invoke(env.as("viewer"), "GetOrderBook", side, "SOLAR")
+	if response.Status != shim.OK {
+		env.t.Fatalf("GetOrderBook 실패: %s", response.Message)
+	}
+	var orders []Order
+	if err := json.Unmarshal(response.Payload, &orders); err != nil {
+		env.t.Fatalf("호가창 역직렬화 실패: %v", err)
+	}
+	ids := []string{}
+	for _, order := range orders {
+		ids = append(ids, order.OrderID)
+	}
+	return ids
+}
+
+// seedExpiredOrder - 이미 만료된 대기 주문을 호가창에 직접 기록 (트랜잭션 시각을 조작할 수 없으므로)
+func (env *tradingTestEnv) seedExpiredOrder(order Order) {
+	env.t.Helper()
+	env.txSeq++
+	env.stub.MockTransactionStart(fmt.Sprintf("seed%d", env.txSeq))
+	defer env.stub.MockTransactionEnd("")
+
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(env.stub)
+	order.EnergySource = "SOLAR"
+	order.RemainingQuantity = order.Quantity
+	order.Status = OrderOpen
+	order.Sequence = fmt.Sprintf("%019d", env.txSeq)
+	order.ExpiresAt = "2020-01-01T00:00:00Z"
+	if err := env.contract.addToBook(ctx, &order); err != nil {
+		env.t.Fatalf("호가창 기록 실패: %v", err)
+	}
+	if err := env.contract.saveOrder(ctx, &order); err != nil {
+		env.t.Fatalf("주문 기록 실패: %v", err)
+	}
+}
+
+// newIdentity - userId, role 속성을 가진 자체 서명 인증서 신원
+func newIdentity(t *testing.T, mspID string, userID string, role string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("키 생성 실패: %v", err)
+	}
+	attrs, err := json.Marshal(map[string]interface{}{"attrs": map[string]string{"userId": userID, "role": role}})
+	if err != nil {
+		t.Fatalf("속성 직렬화 실패: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: userID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		// Fabric CA 속성 확장 (1.2.3.4.5.6.7.8.1)
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrs}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("인증서 생성 실패: %v", err)
+	}
+
+	identity, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatalf("신원 직렬화 실패: %v", err)
+	}
+	return identity
+}
+
+// fills - 거래를 "매도주문→매수주문 수량@가격=대금" 형식으로 요약
+func fills(trades []TradeRecord) []string {
+	summary := []string{}
+	for _, trade := range trades {
+		summary = append(summary, fmt.Sprintf("%s→%s %v@%v=%v", trade.SellOrderID, trade.BuyOrderID, trade.Quantity, trade.Price, trade.TotalAmount))
+	}
+	return summary
+}
+
+func assertStrings(t *testing.T, label string, got []string, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("%s = %q, want %q", label, got, want)
+	}
+}
+
+// TestPlaceOrderPriceTimePriority - 낮은 매도가 우선, 같은 가격은 먼저 접수된 주문 우선, 체결가는 대기 주문 지정가
+func TestPlaceOrderPriceTimePriority(t *testing.T) {
+	env := newTradingTestEnv(t)
+	env.placeOrder("alice", "S1", SideSell, "10", "100")
+	env.placeOrder("bob", "S2", SideSell, "5", "99")
+	env.placeOrder("alice", "S3", SideSell, "5", "100")
+	env.placeOrder("bob", "S4", SideSell, "5", "101")
+	assertStrings(t, "매도 호가창", env.bookIDs(SideSell), []string{"S2", "S1", "S3", "S4"})
+	assertStrings(t, "매도 주문 epc 호출", env.epc.take(), nil)
+
+	result := env.placeOrder("carol", "B1", SideBuy, "12.5", "100")
+
+	assertStrings(t, "체결", fills(result.Trades), []string{"S2→B1 5@99=495", "S1→B1 7.5@100=750"})
+	if result.Order.Status != OrderFilled || result.Order.LockedAmount != "" {
+		t.Errorf("매수 주문 = %s (잠금 잔량 %q), want FILLED", result.Order.Status, result.Order.LockedAmount)
+	}
+	// 지정가보다 싸게 체결된 차액(5 × 1)은 잠그지 않는다
+	assertStrings(t, "epc 호출", env.epc.take(), []string{"Lock carol 1245.000000 B1  " + orderEscrowNoExpiry})
+
+	s1 := env.order("S1")
+	if s1.Status != OrderPartiallyFilled || s1.RemainingQuantity != 2.5 {
+		t.Errorf("S1 = %s 잔량 %v, want PARTIALLY_FILLED 2.5", s1.Status, s1.RemainingQuantity)
+	}
+	assertStrings(t, "매도 호가창", env.bookIDs(SideSell), []string{"S1", "S3", "S4"})
+}
+
+// TestPlaceOrderPartialFills - 대기 매수 주문은 지정가로 나누어 체결되고 잠금액이 거래 대금으로 차감된다
+func TestPlaceOrderPartialFills(t *testing.T) {
+	env := newTradingTestEnv(t)
+	result := env.placeOrder("carol", "B1", SideBuy, "20", "98")
+	if len(result.Trades) != 0 || result.Order.LockedAmount != "1960.000000" {
+		t.Fatalf("매수 주문 = 체결 %d건, 잠금 잔량 %q, want 체결 없음, 1960.000000", len(result.Trades), result.Order.LockedAmount)
+	}
+	assertStrings(t, "epc 호출", env.epc.take(), []string{"Lock carol 1960.000000 B1  " + orderEscrowNoExpiry})
+
+	result = env.placeOrder("alice", "S1", SideSell, "5", "97")
+	assertStrings(t, "체결", fills(result.Trades), []string{"S1→B1 5@98=490"})
+	b1 := env.order("B1")
+	if b1.Status != OrderPartiallyFilled || b1.RemainingQuantity != 15 || b1.LockedAmount != "1470.000000" {
+		t.Errorf("B1 = %s 잔량 %v 잠금 %q, want PARTIALLY_FILLED 15 1470.000000", b1.Status, b1.RemainingQuantity, b1.LockedAmount)
+	}
+
+	// 매도 주문이 남으면 매수 주문을 모두 체결하고 잔량은 호가창에 남는다
+	result = env.placeOrder("bob", "S2", SideSell, "16", "98")
+	assertStrings(t, "체결", fills(result.Trades), []string{"S2→B1 15@98=1470"})
+	if result.Order.Status != OrderPartiallyFilled || result.Order.RemainingQuantity != 1 {
+		t.Errorf("S2 = %s 잔량 %v, want PARTIALLY_FILLED 1", result.Order.Status, result.Order.RemainingQuantity)
+	}
+	if b1 := env.order("B1"); b1.Status != OrderFilled || b1.LockedAmount != "0.000000" {
+		t.Errorf("B1 = %s 잠금 %q, want FILLED 0.000000", b1.Status, b1.LockedAmount)
+	}
+	assertStrings(t, "매도 체결 epc 호출", env.epc.take(), nil)
+	assertStrings(t, "매수 호가창", env.bookIDs(SideBuy), []string{})
+	assertStrings(t, "매도 호가창", env.bookIDs(SideSell), []string{"S2"})
+}
+
+// TestPlaceOrderLockSplitsExactly - 부분 체결 대금의 반올림과 관계없이 잠금액 합계가 거래 대금 합계와 같다
+func TestPlaceOrderLockSplitsExactly(t *testing.T) {
+	env := newTradingTestEnv(t)
+	env.placeOrder("carol", "B1", SideBuy, "0.000003", "0.33")
+	assertStrings(t, "epc 호출", env.epc.take(), []string{"Lock carol 0.000001 B1  " + orderEscrowNoExpiry})
+
+	var total int64
+	for i := 1; i <= 3; i++ {
+		result := env.placeOrder("alice", fmt.Sprintf("S%d", i), SideSell, "0.000001", "0.33")
+		for _, trade := range result.Trades {
+			total += toUnits(trade.TotalAmount)
+		}
+	}
+	if total != 1 {
+		t.Errorf("거래 대금 합계 = %d 기본 단위, want 1 (잠금액)", total)
+	}
+	if b1 := env.order("B1"); b1.Status != OrderFilled || b1.LockedAmount != "0.000000" {
+		t.Errorf("B1 = %s 잠금 %q, want FILLED 0.000000", b1.Status, b1.LockedAmount)
+	}
+}
+
+// TestPlaceOrderSkipsSelfTrade - 같은 주문자의 대기 주문은 건너뛰고 다음 우선순위 주문과 체결한다
+func TestPlaceOrderSkipsSelfTrade(t *testing.T) {
+	env := newTradingTestEnv(t)
+	env.placeOrder("alice", "S1", SideSell, "5", "100")
+	env.placeOrder("bob", "S2", SideSell, "5", "101")
+
+	result := env.placeOrder("alice", "B1", SideBuy, "8", "101")
+
+	assertStrings(t, "체결", fills(result.Trades), []string{"S2→B1 5@101=505"})
+	if result.Order.RemainingQuantity != 3 || result.Order.LockedAmount != "303.000000" {
+		t.Errorf("B1 잔량 %v 잠금 %q, want 3 303.000000", result.Order.RemainingQuantity, result.Order.LockedAmount)
+	}
+	if s1 := env.order("S1"); s1.Status != OrderOpen || s1.RemainingQuantity != 5 {
+		t.Errorf("S1 = %s 잔량 %v, want OPEN 5", s1.Status, s1.RemainingQuantity)
+	}
+	assertStrings(t, "매도 호가창", env.bookIDs(SideSell), []string{"S1"})
+	assertStrings(t, "매수 호가창", env.bookIDs(SideBuy), []string{"B1"})
+}
+
+// TestPlaceOrderExpiresRestingOrders - 매칭 중 만난 만료 주문은 호가창에서 빼고 매수 잠금을 해제한다
+func TestPlaceOrderExpiresRestingOrders(t *testing.T) {
+	env := newTradingTestEnv(t)
+	env.seedExpiredOrder(Order{OrderID: "C1", TraderID: "carol", Side: SideBuy, Quantity: 5, LimitPrice: 100, LockedAmount: "500.000000"})
+	env.seedExpiredOrder(Order{OrderID: "C2", TraderID: "carol", Side: SideBuy, Quantity: 3, LimitPrice: 99, LockedAmount: "297.000000"})
+	env.seedExpiredOrder(Order{OrderID: "D1", TraderID: "dave", Side: SideBuy, Quantity: 2, LimitPrice: 98, LockedAmount: "196.000000"})
+	env.placeOrder("erin", "E1", SideBuy, "4", "97")
+	env.epc.take()
+
+	result := env.placeOrder("alice", "S1", SideSell, "1", "90")
+
+	assertStrings(t, "체결", fills(result.Trades), []string{"S1→E1 1@97=97"})
+	// 같은 트랜잭션에서 carol 잔액을 두 번 갱신하지 않도록 C2는 다음 트랜잭션으로 미룬다
+	assertStrings(t, "epc 호출", env.epc.take(), []string{"Unlock carol 500.000000 C1", "Unlock dave 196.000000 D1"})
+	for orderID, want := range map[string]string{"C1": OrderExpired, "C2": OrderOpen, "D1": OrderExpired} {
+		if order := env.order(orderID); order.Status != want {
+			t.Errorf("%s 상태 = %s, want %s", orderID, order.Status, want)
+		}
+	}
+	if c1 := env.order("C1"); c1.LockedAmount != "" {
+		t.Errorf("C1 잠금 잔량 = %q, want 해제", c1.LockedAmount)
+	}
+	assertStrings(t, "매수 호가창 (만료 제외)", env.bookIDs(SideBuy), []string{"E1"})
+
+	// 남은 만료 주문은 주문자가 취소해 잠금을 해제할 수 있다
+	if response := env.invoke(env.as("carol"), "CancelOrder", "C2"); response.Status != shim.OK {
+		t.Fatalf("CancelOrder C2 실패: %s", response.Message)
+	}
+	assertStrings(t, "취소 epc 호출", env.epc.take(), []string{"Unlock carol 297.000000 C2"})
+}
+
+func TestCancelOrderReleasesLock(t *testing.T) {
+	env := newTradingTestEnv(t)
+	env.placeOrder("carol", "B1", SideBuy, "10", "50")
+	env.placeOrder("alice", "S1", SideSell, "4", "50")
+	env.placeOrder("alice", "S2", SideSell, "3", "60")
+	env.epc.take()
+
+	if response := env.invoke(env.as("bob"), "CancelOrder", "B1"); response.Status == shim.OK {
+		t.Fatalf("다른 사용자의 주문 취소가 성공했습니다")
+	}
+	if response := env.invoke(env.as("carol"), "CancelOrder", "B1"); response.Status != shim.OK {
+		t.Fatalf("CancelOrder B1 실패: %s", response.Message)
+	}
+	// 체결된 4 × 50은 정산 때 지급되므로 미체결 6 × 50만 해제한다
+	assertStrings(t, "매수 취소 epc 호출", env.epc.take(), []string{"Unlock carol 300.000000 B1"})
+	if b1 := env.order("B1"); b1.Status != OrderCancelled || b1.LockedAmount != "" {
+		t.Errorf("B1 = %s 잠금 %q, want CANCELLED 해제", b1.Status, b1.LockedAmount)
+	}
+
+	if response := env.invoke(env.as("alice"), "CancelOrder", "S2"); response.Status != shim.OK {
+		t.Fatalf("CancelOrder S2 실패: %s", response.Message)
+	}
+	assertStrings(t, "매도 취소 epc 호출", env.epc.take(), nil)
+}
+
+func TestPlaceOrderRejectedWithoutBalance(t *testing.T) {
+	env := newTradingTestEnv(t)
+	env.epc.failures["Lock"] = "가용 잔액 부족"
+
+	response := env.invoke(env.as("carol"), "PlaceOrder", "B1", SideBuy, "SOLAR", "10", "50", "")
+	if response.Status == shim.OK || !strings.Contains(response.Message, "가용 잔액 부족") {
+		t.Errorf("PlaceOrder = %d %q, want 잠금 실패", response.Status, response.Message)
+	}
+}
+
+func TestPlaceOrderRejectsInexactAmount(t *testing.T) {
+	env := newTradingTestEnv(t)
+
+	// 주문 금액이 float64로 정확히 표현되는 기본 단위 상한을 넘으면 접수하지 않는다
+	response := env.invoke(env.as("alice"), "PlaceOrder", "S1", SideSell, "SOLAR", "1000000000", "3", "")
+	if response.Status == shim.OK || !strings.Contains(response.Message, "주문 금액이 너무 큽니다") {
+		t.Errorf("PlaceOrder = %d %q, want 금액 상한 오류", response.Status, response.Message)
+	}
+	env.placeOrder("alice", "S2", SideSell, "1000000000", "2")
+}
+
+// TestOrderEscrowExpiry - 주문 만료 후에도 체결 거래를 정산할 수 있도록 에스크로는 유예 기간 뒤에 만료된다
+func TestOrderEscrowExpiry(t *testing.T) {
+	env := newTradingTestEnv(t)
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+
+	response := env.invoke(env.as("carol"), "PlaceOrder", "B1", SideBuy, "SOLAR", "1", "10", expiresAt.Format(time.RFC3339))
+	if response.Status != shim.OK {
+		t.Fatalf("PlaceOrder 실패: %s", response.Message)
+	}
+
+	calls := env.epc.take()
+	want := "Lock carol 10.000000 B1  " + expiresAt.Add(orderEscrowGrace).Format("2006-01-02T15:04:05")
+	if len(calls) != 1 || !strings.HasPrefix(calls[0], want) {
+		t.Errorf("epc 호출 = %q, want %q...", calls, want)
+	}
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"time"
 
+	"github.com/etp/chaincode/common"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -24,9 +23,21 @@ type TradeRecord struct {
 	Quantity     float64 `json:"quantity"`
 	Price        float64 `json:"price"`
 	TotalAmount  float64 `json:"totalAmount"`
-	Status       string  `json:"status"` // MATCHED, CONFIRMED, SETTLED, CANCELLED
+	Status       string  `json:"status"` // MATCHED, CONFIRMED, SETTLED, CANCELLED, DISPUTED
 	CreatedAt    string  `json:"createdAt"`
 	UpdatedAt    string  `json:"updatedAt"`
+	UpdatedBy    string  `json:"updatedBy,omitempty" metadata:",optional"`
+	// 온체인 매칭(PlaceOrder)으로 생성된 거래에만 기록 (정산 수수료 산정용)
+	BuyerMSPID  string `json:"buyerMspId,omitempty" metadata:",optional"`
+	SellerMSPID string `json:"sellerMspId,omitempty" metadata:",optional"`
+	TakerSide   string `json:"takerSide,omitempty" metadata:",optional"` // BUY, SELL
+}
+
+// TradeRecordPage - 거래 기록 페이지 조회 결과
+type TradeRecordPage struct {
+	Records      []TradeRecord `json:"records"`
+	Bookmark     string        `json:"bookmark"`
+	FetchedCount int32         `json:"fetchedCount"`
 }
 
 // RECCertRecord - REC 인증서 블록체인 기록
@@ -42,17 +53,26 @@ type RECCertRecord struct {
 	Status       string  `json:"status"`
 }
 
-// CreateTrade - 거래 기록 생성
+// CreateTrade - 오프체인 매칭 결과 거래 기록 생성 (매칭 운영자 전용)
+//
+// 온체인 호가창 체결(PlaceOrder)과 달리 주문 당사자의 서명이 없으므로 운영 기관만 기록할 수 있다.
 func (c *TradingContract) CreateTrade(ctx contractapi.TransactionContextInterface, tradeID string, buyOrderID string, sellOrderID string, buyerID string, sellerID string, energySource string, quantity float64, price float64) error {
-	existing, err := ctx.GetStub().GetState(tradeID)
+	if _, err := requireMatchingOperator(ctx); err != nil {
+		return err
+	}
+
+	existing, err := common.GetState[TradeRecord](ctx, tradeID, "거래")
 	if err != nil {
-		return fmt.Errorf("거래 조회 실패: %v", err)
+		return err
 	}
 	if existing != nil {
 		return fmt.Errorf("거래가 이미 존재합니다: %s", tradeID)
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
 
 	record := TradeRecord{
 		TradeID:      tradeID,
@@ -64,58 +84,38 @@ func (c *TradingContract) CreateTrade(ctx contractapi.TransactionContextInterfac
 		Quantity:     quantity,
 		Price:        price,
 		TotalAmount:  quantity * price,
-		Status:       "MATCHED",
+		Status:       StatusMatched,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
 
-	recordJSON, err := json.Marshal(record)
-	if err != nil {
-		return fmt.Errorf("거래 직렬화 실패: %v", err)
-	}
-
-	return ctx.GetStub().PutState(tradeID, recordJSON)
+	return common.PutState(ctx, tradeID, record, "거래")
 }
 
 // GetTrade - 거래 기록 조회
 func (c *TradingContract) GetTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*TradeRecord, error) {
-	recordJSON, err := ctx.GetStub().GetState(tradeID)
-	if err != nil {
-		return nil, fmt.Errorf("거래 조회 실패: %v", err)
-	}
-	if recordJSON == nil {
-		return nil, fmt.Errorf("거래를 찾을 수 없습니다: %s", tradeID)
-	}
-
-	var record TradeRecord
-	if err := json.Unmarshal(recordJSON, &record); err != nil {
-		return nil, fmt.Errorf("거래 역직렬화 실패: %v", err)
-	}
-
-	return &record, nil
+	return common.MustGetState[TradeRecord](ctx, tradeID, "거래", tradeID)
 }
 
 // UpdateTradeStatus - 거래 상태 변경
+//
+// 허용 전이: MATCHED → CONFIRMED → SETTLED, MATCHED/CONFIRMED → CANCELLED,
+// MATCHED/CONFIRMED → DISPUTED → CONFIRMED/CANCELLED (전이별 권한은 tradeTransitions 참조)
 func (c *TradingContract) UpdateTradeStatus(ctx contractapi.TransactionContextInterface, tradeID string, newStatus string) error {
 	record, err := c.GetTrade(ctx, tradeID)
 	if err != nil {
 		return err
 	}
 
-	record.Status = newStatus
-	record.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-
-	recordJSON, err := json.Marshal(record)
-	if err != nil {
-		return fmt.Errorf("거래 직렬화 실패: %v", err)
-	}
-
-	return ctx.GetStub().PutState(tradeID, recordJSON)
+	return c.changeTradeStatus(ctx, record, newStatus)
 }
 
 // IssueREC - REC 인증서 발급
 func (c *TradingContract) IssueREC(ctx contractapi.TransactionContextInterface, certID string, tradeID string, supplierID string, consumerID string, energySource string, quantity float64, validUntil string) error {
-	now := time.Now().UTC().Format(time.RFC3339)
+	now, err := common.TxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
 
 	cert := RECCertRecord{
 		CertID:       certID,
@@ -129,37 +129,85 @@ func (c *TradingContract) IssueREC(ctx contractapi.TransactionContextInterface,
 		Status:       "VALID",
 	}
 
-	certJSON, err := json.Marshal(cert)
+	return common.PutState(ctx, "REC_"+certID, cert, "REC")
+}
+
+// GetTradeHistory - 거래 이력 조회
+func (c *TradingContract) GetTradeHistory(ctx contractapi.TransactionContextInterface, tradeID string) ([]TradeRecord, error) {
+	return common.GetHistory[TradeRecord](ctx, tradeID, "거래")
+}
+
+// GetTradeHistoryWithPagination - 거래 이력 조회 (북마크 기반 페이지 조회)
+func (c *TradingContract) GetTradeHistoryWithPagination(ctx contractapi.TransactionContextInterface, tradeID string, pageSize int32, bookmark string) (*TradeRecordPage, error) {
+	page, err := common.GetHistoryPage[TradeRecord](ctx, tradeID, pageSize, bookmark, "거래")
 	if err != nil {
-		return fmt.Errorf("REC 직렬화 실패: %v", err)
+		return nil, err
 	}
 
-	return ctx.GetStub().PutState("REC_"+certID, certJSON)
+	return &TradeRecordPage{Records: page.Records, Bookmark: page.Bookmark, FetchedCount: page.FetchedCount}, nil
 }
 
-// GetTradeHistory - 거래 이력 조회 (키 범위 조회)
-func (c *TradingContract) GetTradeHistory(ctx contractapi.TransactionContextInterface, tradeID string) ([]TradeRecord, error) {
-	historyIterator, err := ctx.GetStub().GetHistoryForKey(tradeID)
+// GetConfirmedTrades - 체결 시각이 [windowStart, windowEnd) 구간인 CONFIRMED 거래 조회 (일괄 정산용)
+func (c *TradingContract) GetConfirmedTrades(ctx contractapi.TransactionContextInterface, windowStart string, windowEnd string) ([]TradeRecord, error) {
+	start, err := common.ParseTime(windowStart)
+	if err != nil {
+		return nil, err
+	}
+	end, err := common.ParseTime(windowEnd)
 	if err != nil {
-		return nil, fmt.Errorf("거래 이력 조회 실패: %v", err)
+		return nil, err
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("정산 구간이 올바르지 않습니다: %s ~ %s", windowStart, windowEnd)
 	}
-	defer historyIterator.Close()
 
-	var records []TradeRecord
-	for historyIterator.HasNext() {
-		modification, err := historyIterator.Next()
+	trades := []TradeRecord{}
+	startKey := confirmedIndexPrefix + common.FormatTime(start)
+	endKey := confirmedIndexPrefix + common.FormatTime(end)
+	err = common.ForEachInRange(ctx, startKey, endKey, func(key string, value []byte) error {
+		trade, err := c.GetTrade(ctx, string(value))
 		if err != nil {
-			return nil, fmt.Errorf("이력 반복 실패: %v", err)
+			return err
 		}
+		trades = append(trades, *trade)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		var record TradeRecord
-		if err := json.Unmarshal(modification.Value, &record); err != nil {
-			continue
-		}
-		records = append(records, record)
+	return trades, nil
+}
+
+// QueryTradesByParticipant - 참여자별 거래 조회 (CouchDB 전용)
+//
+// role은 BUYER, SELLER 또는 빈 문자열(양쪽 모두)이며 status가 비어 있으면 상태 필터를 적용하지 않는다.
+func (c *TradingContract) QueryTradesByParticipant(ctx contractapi.TransactionContextInterface, participantID string, role string, status string, pageSize int32, bookmark string) (*TradeRecordPage, error) {
+	if participantID == "" {
+		return nil, fmt.Errorf("참여자 ID가 비어 있습니다")
+	}
+
+	selector := common.NewSelector().Exists("tradeId").Eq("status", status)
+	switch role {
+	case "BUYER":
+		selector.Eq("buyerId", participantID)
+	case "SELLER":
+		selector.Eq("sellerId", participantID)
+	case "":
+		selector.Or(
+			common.NewSelector().Eq("buyerId", participantID),
+			common.NewSelector().Eq("sellerId", participantID),
+		)
+	default:
+		return nil, fmt.Errorf("잘못된 역할: %s (BUYER, SELLER 중 하나)", role)
+	}
+
+	page, err := common.QuerySelectorPage[TradeRecord](ctx, selector, pageSize, bookmark, "거래")
+	if err != nil {
+		return nil, err
 	}
 
-	return records, nil
+	return &TradeRecordPage{Records: page.Records, Bookmark: page.Bookmark, FetchedCount: page.FetchedCount}, nil
 }
 
 func main() {
@@ -8,6 +8,22 @@ CHAINCODE_DID="did-cc"
 CHAINCODE_TRADING="trading-cc"
 CHAINCODE_SETTLEMENT="settlement-cc"
 CHAINCODE_METERING="metering-cc"
+CHAINCODE_EPC="epc-cc"
+CHAINCODE_REC_TOKEN="rec-token-cc"
+CC_VERSION="1.0"
+CC_SEQUENCE=1
+
+PEER_BASE=/opt/gopath/src/github.com/hyperledger/fabric/peer
+CC_SRC=/opt/gopath/src/github.com/chaincode
+ORDERER_CA=${PEER_BASE}/crypto/ordererOrganizations/etp.com/orderers/orderer.etp.com/msp/tlscacerts/tlsca.etp.com-cert.pem
+
+# 비공개 데이터 컬렉션(did, metering)은 AdminOrg 피어만 보관하고, 컬렉션 쓰기는 collections_config.json의
+# endorsementPolicy로 AdminOrg 피어 보증을 요구한다. did-cc, metering-cc의 자체 트랜잭션은 컬렉션을 읽어
+# 컬렉션 구성원 피어만 시뮬레이션할 수 있으므로 AdminOrg 피어 보증으로 배포한다.
+# 잔액·REC를 바꾸는 epc-cc, rec-token-cc는 채널 기본 정책(MAJORITY)을 유지한다. 이들이 호출하는
+# metering 청구 함수는 컬렉션 대신 공개 약정(MeterCommitment)만 확인하므로 다른 조직 피어도 실행할 수 있고,
+# metering-cc 공개 상태(청구 표시)에도 쓰므로 AdminOrg 피어 보증이 추가로 필요하다.
+PRIVATE_DATA_ENDORSEMENT="OR('AdminOrgMSP.peer')"
 
 echo "=========================================="
 echo "  ETP Fabric Network Setup"
@@ -43,7 +59,7 @@ docker exec etp-cli peer channel create \
   -c ${CHANNEL_NAME} \
   -f ./channel-artifacts/${CHANNEL_NAME}.tx \
   --tls \
-  --cafile /opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/ordererOrganizations/etp.com/orderers/orderer.etp.com/msp/tlscacerts/tlsca.etp.com-cert.pem
+  --cafile ${ORDERER_CA}
 
 echo ">> Joining SupplierOrg peers..."
 docker exec etp-cli peer channel join -b ${CHANNEL_NAME}.block
@@ -62,8 +78,86 @@ docker exec -e CORE_PEER_LOCALMSPID=AdminOrgMSP \
   -e CORE_PEER_MSPCONFIGPATH=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/peerOrganizations/admin.etp.com/users/Admin@admin.etp.com/msp \
   etp-cli peer channel join -b ${CHANNEL_NAME}.block
 
+# 5. 체인코드 배포
+# peer_exec <supplier|consumer|admin> <명령...> - 해당 조직 peer0의 Admin 신원으로 CLI 명령 실행
+peer_exec() {
+  local org=$1
+  shift
+  local msp port
+  case ${org} in
+    supplier) msp=SupplierOrgMSP; port=7051 ;;
+    consumer) msp=ConsumerOrgMSP; port=9051 ;;
+    admin) msp=AdminOrgMSP; port=11051 ;;
+  esac
+
+  docker exec -e CORE_PEER_LOCALMSPID=${msp} \
+    -e CORE_PEER_ADDRESS=peer0.${org}.etp.com:${port} \
+    -e CORE_PEER_TLS_ROOTCERT_FILE=${PEER_BASE}/crypto/peerOrganizations/${org}.etp.com/peers/peer0.${org}.etp.com/tls/ca.crt \
+    -e CORE_PEER_MSPCONFIGPATH=${PEER_BASE}/crypto/peerOrganizations/${org}.etp.com/users/Admin@${org}.etp.com/msp \
+    etp-cli "$@"
+}
+
+# deploy_chaincode <이름> <소스 디렉터리> <보증 정책|""> <컬렉션 설정 사용 여부(yes|no)>
+# 패키징 → 세 조직 설치 → 조직별 승인 → 커밋. 승인과 커밋에는 같은 정책·컬렉션 설정을 넘겨야 한다.
+deploy_chaincode() {
+  local name=$1 dir=$2 policy=$3 collections=$4
+  local definition=(--channelID ${CHANNEL_NAME} --name ${name} --version ${CC_VERSION} --sequence ${CC_SEQUENCE})
+  if [ -n "${policy}" ]; then
+    definition+=(--signature-policy "${policy}")
+  fi
+  if [ "${collections}" = "yes" ]; then
+    definition+=(--collections-config ${CC_SRC}/${dir}/collections_config.json)
+  fi
+
+  # 각 모듈은 replace => ../common 으로 공통 패키지를 참조하는데, 패키지에는 --path 디렉터리만
+  # 담기므로 피어 빌드 시 ../common 을 찾을 수 없다. 패키징 전에 vendor 로 포함시킨다.
+  # 재현 가능한 빌드를 위해 커밋된 go.mod/go.sum 그대로 vendor 하며 tidy 는 하지 않는다.
+  echo ">> Vendoring ${name} dependencies..."
+  peer_exec admin sh -c "cd ${CC_SRC}/${dir} && GO111MODULE=on go mod vendor"
+
+  echo ">> Packaging ${name}..."
+  peer_exec admin peer lifecycle chaincode package ${name}.tar.gz \
+    --path ${CC_SRC}/${dir} --lang golang --label ${name}_${CC_VERSION}
+
+  local org
+  for org in supplier consumer admin; do
+    echo ">> Installing ${name} on ${org}..."
+    peer_exec ${org} peer lifecycle chaincode install ${name}.tar.gz
+  done
+
+  local package_id
+  package_id=$(peer_exec admin peer lifecycle chaincode calculatepackageid ${name}.tar.gz)
+
+  for org in supplier consumer admin; do
+    echo ">> Approving ${name} for ${org}..."
+    peer_exec ${org} peer lifecycle chaincode approveformyorg \
+      -o orderer.etp.com:7050 --tls --cafile ${ORDERER_CA} \
+      --package-id ${package_id} "${definition[@]}"
+  done
+
+  echo ">> Committing ${name}..."
+  peer_exec admin peer lifecycle chaincode commit \
+    -o orderer.etp.com:7050 --tls --cafile ${ORDERER_CA} \
+    --peerAddresses peer0.supplier.etp.com:7051 \
+    --tlsRootCertFiles ${PEER_BASE}/crypto/peerOrganizations/supplier.etp.com/peers/peer0.supplier.etp.com/tls/ca.crt \
+    --peerAddresses peer0.consumer.etp.com:9051 \
+    --tlsRootCertFiles ${PEER_BASE}/crypto/peerOrganizations/consumer.etp.com/peers/peer0.consumer.etp.com/tls/ca.crt \
+    --peerAddresses peer0.admin.etp.com:11051 \
+    --tlsRootCertFiles ${PEER_BASE}/crypto/peerOrganizations/admin.etp.com/peers/peer0.admin.etp.com/tls/ca.crt \
+    "${definition[@]}"
+}
+
+echo ">> 5. Deploying chaincode..."
+deploy_chaincode ${CHAINCODE_DID} did "${PRIVATE_DATA_ENDORSEMENT}" yes
+deploy_chaincode ${CHAINCODE_METERING} metering "${PRIVATE_DATA_ENDORSEMENT}" yes
+deploy_chaincode ${CHAINCODE_EPC} epc "" no
+deploy_chaincode ${CHAINCODE_REC_TOKEN} rec-token "" no
+deploy_chaincode ${CHAINCODE_TRADING} trading "" no
+deploy_chaincode ${CHAINCODE_SETTLEMENT} settlement "" no
+
 echo "=========================================="
 echo "  Network setup complete!"
 echo "  Channel: ${CHANNEL_NAME}"
 echo "  Orgs: SupplierOrg, ConsumerOrg, AdminOrg"
+echo "  Chaincode: ${CHAINCODE_DID}, ${CHAINCODE_METERING}, ${CHAINCODE_EPC}, ${CHAINCODE_REC_TOKEN}, ${CHAINCODE_TRADING}, ${CHAINCODE_SETTLEMENT}"
 echo "=========================================="
@@ -1,5 +1,5 @@
 import { useEffect, useState } from 'react';
-import { recTokenService } from '../services/rec-token.service';
+import { recTokenService, RetireClaim } from '../services/rec-token.service';
 import { Card, Badge, Button, StatCard } from '../components/ui';
 import { useToast } from '../components/ui/Toast';
 import Modal from '../components/ui/Modal';
@@ -21,6 +21,10 @@ interface RECToken {
 
 const SOURCE_LABELS: Record<string, string> = { SOLAR: '태양광', WIND: '풍력', HYDRO: '수력', BIOMASS: '바이오매스', GEOTHERMAL: '지열' };
 const SOURCE_ICONS: Record<string, string> = { SOLAR: '☀️', WIND: '🌬️', HYDRO: '💧', BIOMASS: '🌿', GEOTHERMAL: '🌋' };
+const PURPOSE_LABELS: Record<RetireClaim['purpose'], string> = { RE100: 'RE100', GHG_SCOPE2: 'GHG Scope 2', VOLUNTARY: '자발적 주장' };
+const emptyClaim = (): RetireClaim => ({ beneficiary: '', reportingYear: new Date().getFullYear(), purpose: 'RE100', consumptionLocation: '' });
+const inputClass = "w-full px-3.5 py-2.5 border rounded-lg text-sm focus:ring-2 focus:ring-primary-500 focus:border-primary-500 outline-none";
+
 const STATUS_MAP: Record<string, { text: string; variant: 'success' | 'info' | 'neutral' }> = {
   ACTIVE: { text: '활성', variant: 'success' },
   TRANSFERRED: { text: '양도됨', variant: 'info' },
@@ -32,6 +36,7 @@ export default function RECMarketplace() {
   const [tokens, setTokens] = useState<RECToken[]>([]);
   const [isLoading, setIsLoading] = useState(true);
   const [retireTarget, setRetireTarget] = useState<string | null>(null);
+  const [claim, setClaim] = useState<RetireClaim>(emptyClaim);
   const { toast } = useToast();
 
   useEffect(() => { loadTokens(); }, [tab]);
@@ -52,9 +57,10 @@ export default function RECMarketplace() {
 
   const handleRetire = async (tokenId: string) => {
     try {
-      await recTokenService.retire(tokenId);
+      await recTokenService.retire(tokenId, claim);
       toast('success', 'REC 토큰이 소멸 처리되었습니다. RE100 달성에 반영됩니다.');
       setRetireTarget(null);
+      setClaim(emptyClaim());
       loadTokens();
     } catch (err: any) {
       toast('error', err.response?.data?.message || '소멸 실패');
@@ -195,7 +201,7 @@ export default function RECMarketplace() {
         footer={
           <div className="flex gap-2 justify-end">
             <Button variant="secondary" onClick={() => setRetireTarget(null)}>취소</Button>
-            <Button variant="danger" onClick={() => retireTarget && handleRetire(retireTarget)}>소멸 처리</Button>
+            <Button variant="danger" disabled={!claim.beneficiary || !claim.consumptionLocation} onClick={() => retireTarget && handleRetire(retireTarget)}>소멸 처리</Button>
           </div>
         }
       >
@@ -203,6 +209,28 @@ export default function RECMarketplace() {
           이 REC 토큰을 소멸 처리하시겠습니까?<br />
           소멸된 토큰은 RE100 달성 실적에 반영되며, 이 작업은 되돌릴 수 없습니다.
         </p>
+        <div className="mt-4 space-y-3">
+          <div>
+            <label className="block text-sm font-medium text-gray-700 mb-1.5">수혜자 (법인명)</label>
+            <input type="text" value={claim.beneficiary} onChange={(e) => setClaim((c) => ({ ...c, beneficiary: e.target.value }))} className={inputClass} required />
+          </div>
+          <div className="grid grid-cols-2 gap-3">
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-1.5">보고 연도</label>
+              <input type="number" min="2000" max="9999" value={claim.reportingYear} onChange={(e) => setClaim((c) => ({ ...c, reportingYear: Number(e.target.value) }))} className={inputClass} required />
+            </div>
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-1.5">목적</label>
+              <select value={claim.purpose} onChange={(e) => setClaim((c) => ({ ...c, purpose: e.target.value as RetireClaim['purpose'] }))} className={inputClass}>
+                {Object.entries(PURPOSE_LABELS).map(([value, label]) => <option key={value} value={value}>{label}</option>)}
+              </select>
+            </div>
+          </div>
+          <div>
+            <label className="block text-sm font-medium text-gray-700 mb-1.5">전력 소비 위치</label>
+            <input type="text" value={claim.consumptionLocation} onChange={(e) => setClaim((c) => ({ ...c, consumptionLocation: e.target.value }))} placeholder="서울특별시 강남구" className={inputClass} required />
+          </div>
+        </div>
       </Modal>
     </div>
   );
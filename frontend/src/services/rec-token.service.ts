@@ -1,5 +1,12 @@
 import api from './api';
 
+export interface RetireClaim {
+  beneficiary: string;
+  reportingYear: number;
+  purpose: 'RE100' | 'GHG_SCOPE2' | 'VOLUNTARY';
+  consumptionLocation: string;
+}
+
 export const recTokenService = {
   getMyTokens: (status?: string) =>
     api.get('/rec-token', { params: { status } }).then((r) => r.data),
@@ -12,7 +19,8 @@ export const recTokenService = {
   transfer: (id: string, toUserId: string) =>
     api.post(`/rec-token/${id}/transfer`, { toUserId }).then((r) => r.data),
 
-  retire: (id: string) => api.post(`/rec-token/${id}/retire`).then((r) => r.data),
+  retire: (id: string, claim: RetireClaim) =>
+    api.post(`/rec-token/${id}/retire`, claim).then((r) => r.data),
 
   issueFromCert: (certId: string) =>
     api.post(`/rec-token/issue/${certId}`).then((r) => r.data),